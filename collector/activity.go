@@ -0,0 +1,141 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  会话活动抓取器：根据pg_stat_activity统计运行时间最长的查询时长、各状态的会话数，以及处于idle in transaction
+ *  超过GPDB_IDLE_IN_TRANSACTION_SECONDS(默认300秒)的会话数，这类会话通常持有锁并阻止vacuum回收。均不含exporter自身连接
+ */
+
+const (
+	idleInTransactionSecondsEnv = "GPDB_IDLE_IN_TRANSACTION_SECONDS"
+
+	activeQueryMaxDurationSql_V6 = `select coalesce(max(extract(epoch from now() - query_start)), 0) from pg_stat_activity
+                                    where pid <> pg_backend_pid() and state = 'active';`
+	activeQueryMaxDurationSql_V5 = `select coalesce(max(extract(epoch from now() - query_start)), 0) from pg_stat_activity
+                                    where procpid <> pg_backend_pid() and current_query not like '<IDLE>%';`
+	// Greenplum 7 的后台进程state为NULL，不计入
+	sessionsByStateSql_V6 = `select state, count(*) from pg_stat_activity
+                             where pid <> pg_backend_pid() and state is not null group by 1;`
+	// Greenplum 5 没有state字段，根据current_query中的<IDLE>标记区分
+	sessionsByStateSql_V5 = `select case when current_query = '<IDLE>' then 'idle'
+                             when current_query like '<IDLE> in transaction%' then 'idle in transaction'
+                             else 'active' end, count(*)
+                             from pg_stat_activity where procpid <> pg_backend_pid() group by 1;`
+	idleInTransactionSql_V6 = `select count(*) from pg_stat_activity
+                               where pid <> pg_backend_pid() and state in ('idle in transaction', 'idle in transaction (aborted)')
+                               and now() - state_change > $1::int * interval '1 second';`
+	// Greenplum 5 没有state_change字段，使用最后一条语句的开始时间query_start
+	idleInTransactionSql_V5 = `select count(*) from pg_stat_activity
+                               where procpid <> pg_backend_pid() and current_query like '<IDLE> in transaction%'
+                               and now() - query_start > $1::int * interval '1 second';`
+)
+
+var (
+	activeQueryMaxDurationDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "active_query_max_duration_seconds"),
+		"Seconds since the start of the longest running active query, 0 when there is none",
+		nil, nil,
+	)
+
+	sessionsByStateDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "sessions_by_state"),
+		"Number of sessions in pg_stat_activity by state",
+		[]string{"state"}, nil,
+	)
+
+	idleInTransactionDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "idle_in_transaction_sessions_over_threshold"),
+		"Number of sessions idle in transaction for longer than GPDB_IDLE_IN_TRANSACTION_SECONDS",
+		nil, nil,
+	)
+)
+
+func NewActivityScraper() Scraper {
+	return activityScraper{idleInTransactionSeconds: envInt(idleInTransactionSecondsEnv, 300)}
+}
+
+type activityScraper struct {
+	idleInTransactionSeconds int
+}
+
+func (activityScraper) Name() string {
+	return "activity_scraper"
+}
+
+func (s activityScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	errD := scrapeActiveQueryMaxDuration(ctx, db, ch, ver)
+	errS := scrapeSessionsByState(ctx, db, ch, ver)
+	errI := scrapeIdleInTransaction(ctx, db, ch, ver, s.idleInTransactionSeconds)
+
+	return combineErr(
+		wrapErr("active_query_max_duration", errD),
+		wrapErr("sessions_by_state", errS),
+		wrapErr("idle_in_transaction", errI),
+	)
+}
+
+func scrapeActiveQueryMaxDuration(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	querySql := activeQueryMaxDurationSql_V6
+	if ver < 6 {
+		querySql = activeQueryMaxDurationSql_V5
+	}
+
+	return skipScalarNull(scrapeScalarGauge(ctx, db, ch, activeQueryMaxDurationDesc, querySql))
+}
+
+func scrapeSessionsByState(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	querySql := sessionsByStateSql_V6
+	if ver < 6 {
+		querySql = sessionsByStateSql_V5
+	}
+
+	logger.Debugf("Query Database: %s", querySql)
+	rows, err := db.QueryContext(ctx, querySql)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var state string
+		var count float64
+
+		if err = rows.Scan(&state, &count); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(sessionsByStateDesc, prometheus.GaugeValue, count, state)
+	}
+
+	if err = rows.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return combineErr(errs...)
+}
+
+func scrapeIdleInTransaction(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int, idleInTransactionSeconds int) error {
+	querySql := idleInTransactionSql_V6
+	if ver < 6 {
+		querySql = idleInTransactionSql_V5
+	}
+
+	count, err := scrapeScalar(ctx, db, querySql, idleInTransactionSeconds)
+	if err != nil {
+		return skipScalarNull(err)
+	}
+
+	ch <- prometheus.MustNewConstMetric(idleInTransactionDesc, prometheus.GaugeValue, count)
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestActivityScraper(t *testing.T) {
+	setEnv(t, idleInTransactionSecondsEnv, "60")
+
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta(activeQueryMaxDurationSql_V6)).WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(12.5))
+	mock.ExpectQuery(regexp.QuoteMeta(sessionsByStateSql_V6)).WillReturnRows(sqlmock.NewRows([]string{"state", "count"}).
+		AddRow("active", 3).
+		AddRow("idle in transaction", 1))
+	mock.ExpectQuery(regexp.QuoteMeta(idleInTransactionSql_V6)).WithArgs(60).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	expected := `
+# HELP greenplum_server_active_query_max_duration_seconds Seconds since the start of the longest running active query, 0 when there is none
+# TYPE greenplum_server_active_query_max_duration_seconds gauge
+greenplum_server_active_query_max_duration_seconds 12.5
+# HELP greenplum_server_sessions_by_state Number of sessions in pg_stat_activity by state
+# TYPE greenplum_server_sessions_by_state gauge
+greenplum_server_sessions_by_state{state="active"} 3
+greenplum_server_sessions_by_state{state="idle in transaction"} 1
+# HELP greenplum_server_idle_in_transaction_sessions_over_threshold Number of sessions idle in transaction for longer than GPDB_IDLE_IN_TRANSACTION_SECONDS
+# TYPE greenplum_server_idle_in_transaction_sessions_over_threshold gauge
+greenplum_server_idle_in_transaction_sessions_over_threshold 1
+`
+	if err := collectAndCompare(t, NewActivityScraper(), db, 6, expected); err != nil {
+		t.Errorf("unexpected scrape error: %v", err)
+	}
+}
+
+func TestActivityScraperErrors(t *testing.T) {
+	setEnv(t, idleInTransactionSecondsEnv, "")
+
+	t.Run("query_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectFailedQueries(mock, activeQueryMaxDurationSql_V6, sessionsByStateSql_V6, idleInTransactionSql_V6)
+
+		err := collectAndCompare(t, NewActivityScraper(), db, 6, "")
+		expectErrLabels(t, err, "active_query_max_duration", "sessions_by_state", "idle_in_transaction")
+	})
+
+	// 出错的行被跳过，其余行照常输出
+	t.Run("scan_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(activeQueryMaxDurationSql_V5)).WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow("abc"))
+		mock.ExpectQuery(regexp.QuoteMeta(sessionsByStateSql_V5)).WillReturnRows(sqlmock.NewRows([]string{"state", "count"}).
+			AddRow("idle", "abc").
+			AddRow("active", 2))
+		mock.ExpectQuery(regexp.QuoteMeta(idleInTransactionSql_V5)).WithArgs(300).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow("abc"))
+
+		expected := `
+# HELP greenplum_server_sessions_by_state Number of sessions in pg_stat_activity by state
+# TYPE greenplum_server_sessions_by_state gauge
+greenplum_server_sessions_by_state{state="active"} 2
+`
+		err := collectAndCompare(t, NewActivityScraper(), db, 5, expected)
+		expectErrLabels(t, err, "active_query_max_duration", "sessions_by_state", "idle_in_transaction")
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectEmptyQueries(mock, activeQueryMaxDurationSql_V6, sessionsByStateSql_V6, idleInTransactionSql_V6)
+
+		if err := collectAndCompare(t, NewActivityScraper(), db, 6, ""); err != nil {
+			t.Errorf("unexpected scrape error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  准入控制抓取器：按gp_resource_manager选择资源队列(queue)或资源组(group)，统计并发槽位的使用情况
+ */
+
+const (
+	resourceManagerSql = `SELECT current_setting('gp_resource_manager');`
+
+	resqueueSlotsSql = `SELECT rsqname, rsqcountlimit, rsqcountvalue from gp_toolkit.gp_resqueue_status;`
+	resgroupSlotsSql = `
+		SELECT c.groupname, c.concurrency::int, s.num_running
+		FROM gp_toolkit.gp_resgroup_config c
+			JOIN gp_toolkit.gp_resgroup_status s ON s.groupid = c.groupid
+	`
+)
+
+var (
+	admissionSlotsTotalDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "admission_slots_total"),
+		"Concurrency limit of each resource queue or resource group",
+		[]string{"mechanism", "name"}, nil,
+	)
+
+	admissionSlotsUsedDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "admission_slots_used"),
+		"Number of running statements or transactions admitted by each resource queue or resource group",
+		[]string{"mechanism", "name"}, nil,
+	)
+
+	admissionSlotsUsedRatioDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "admission_slots_used_ratio"),
+		"Ratio of used to total concurrency slots of each resource queue or resource group",
+		[]string{"mechanism", "name"}, nil,
+	)
+)
+
+func NewAdmissionScraper() Scraper {
+	return admissionScraper{}
+}
+
+type admissionScraper struct{}
+
+func (admissionScraper) Name() string {
+	return "admission_scraper"
+}
+
+func (admissionScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	manager, err := queryResourceManager(ctx, db, ver)
+	if err != nil {
+		return err
+	}
+
+	mechanism, querySql := "queue", resqueueSlotsSql
+	if manager == "group" {
+		mechanism, querySql = "group", resgroupSlotsSql
+	}
+
+	logger.Debugf("Query Database: %s", querySql)
+	rows, err := db.QueryContext(ctx, querySql)
+
+	if err != nil {
+		if isUndefinedObject(err) {
+			logger.Warnf("skip admission slots metrics, error:%v", err)
+			return nil
+		}
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var name string
+		var total, used float64
+
+		err = rows.Scan(&name, &total, &used)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(admissionSlotsUsedDesc, prometheus.GaugeValue, used, mechanism, name)
+
+		// 资源队列的活动语句数上限为-1时表示不限制
+		if total <= 0 {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(admissionSlotsTotalDesc, prometheus.GaugeValue, total, mechanism, name)
+		ch <- prometheus.MustNewConstMetric(admissionSlotsUsedRatioDesc, prometheus.GaugeValue, used/total, mechanism, name)
+	}
+
+	return combineErr(errs...)
+}
+
+/**
+* 函数：queryResourceManager
+* 功能：获取gp_resource_manager设置的资源管理方式(queue、group等)，Greenplum 5只支持资源队列
+ */
+func queryResourceManager(ctx context.Context, db Queryer, ver int) (string, error) {
+	if ver < 6 {
+		return "queue", nil
+	}
+
+	var manager string
+
+	logger.Debugf("Query Database: %s", resourceManagerSql)
+	err := db.QueryRowContext(ctx, resourceManagerSql).Scan(&manager)
+
+	return manager, err
+}
@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func expectResourceManager(mock sqlmock.Sqlmock, manager string) {
+	mock.ExpectQuery(regexp.QuoteMeta(resourceManagerSql)).WillReturnRows(sqlmock.NewRows([]string{"gp_resource_manager"}).AddRow(manager))
+}
+
+func TestAdmissionScraper(t *testing.T) {
+	db, mock := newMockDB(t)
+	expectResourceManager(mock, "group")
+	mock.ExpectQuery(regexp.QuoteMeta(resgroupSlotsSql)).WillReturnRows(sqlmock.NewRows([]string{"groupname", "concurrency", "num_running"}).
+		AddRow("default_group", 20, 5).
+		AddRow("system_group", 0, 1))
+
+	expected := `
+# HELP greenplum_server_admission_slots_total Concurrency limit of each resource queue or resource group
+# TYPE greenplum_server_admission_slots_total gauge
+greenplum_server_admission_slots_total{mechanism="group",name="default_group"} 20
+# HELP greenplum_server_admission_slots_used Number of running statements or transactions admitted by each resource queue or resource group
+# TYPE greenplum_server_admission_slots_used gauge
+greenplum_server_admission_slots_used{mechanism="group",name="default_group"} 5
+greenplum_server_admission_slots_used{mechanism="group",name="system_group"} 1
+# HELP greenplum_server_admission_slots_used_ratio Ratio of used to total concurrency slots of each resource queue or resource group
+# TYPE greenplum_server_admission_slots_used_ratio gauge
+greenplum_server_admission_slots_used_ratio{mechanism="group",name="default_group"} 0.25
+`
+	if err := collectAndCompare(t, NewAdmissionScraper(), db, 6, expected); err != nil {
+		t.Errorf("unexpected scrape error: %v", err)
+	}
+}
+
+func TestAdmissionScraperErrors(t *testing.T) {
+	t.Run("resource_manager_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(resourceManagerSql)).WillReturnError(errors.New("connection reset"))
+
+		if err := collectAndCompare(t, NewAdmissionScraper(), db, 6, ""); err == nil {
+			t.Error("expected the query error")
+		}
+	})
+
+	t.Run("query_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectResourceManager(mock, "queue")
+		expectFailedQueries(mock, resqueueSlotsSql)
+
+		if err := collectAndCompare(t, NewAdmissionScraper(), db, 6, ""); err == nil {
+			t.Error("expected the query error")
+		}
+	})
+
+	// 出错的行被跳过，其余行照常输出
+	t.Run("scan_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(resqueueSlotsSql)).WillReturnRows(sqlmock.NewRows([]string{"rsqname", "rsqcountlimit", "rsqcountvalue"}).
+			AddRow("etl", "abc", 1).
+			AddRow("pg_default", -1, 2))
+
+		expected := `
+# HELP greenplum_server_admission_slots_used Number of running statements or transactions admitted by each resource queue or resource group
+# TYPE greenplum_server_admission_slots_used gauge
+greenplum_server_admission_slots_used{mechanism="queue",name="pg_default"} 2
+`
+		if err := collectAndCompare(t, NewAdmissionScraper(), db, 5, expected); err == nil {
+			t.Error("expected the scan error")
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectResourceManager(mock, "group")
+		expectEmptyQueries(mock, resgroupSlotsSql)
+
+		if err := collectAndCompare(t, NewAdmissionScraper(), db, 6, ""); err != nil {
+			t.Errorf("unexpected scrape error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,272 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  AO(Append-Optimized)表抓取器，仅统计大小超过GPDB_AO_TABLE_MIN_MB(默认1024MB)的表以控制指标数量，
+ *  段文件数只统计单个segment上段文件数不少于GPDB_AO_SEGFILE_MIN_COUNT(默认32)的表
+ */
+
+const (
+	aoTableMinSizeEnv      = "GPDB_AO_TABLE_MIN_MB"
+	aoCompactionPercentEnv = "GPDB_AO_COMPACTION_PERCENT"
+	aoSegfileMinCountEnv   = "GPDB_AO_SEGFILE_MIN_COUNT"
+
+	aoCompressionRatioSql = `
+		SELECT current_database(), n.nspname, c.relname, get_ao_compression_ratio(c.oid)
+		FROM pg_appendonly a
+			JOIN pg_class c ON c.oid = a.relid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE pg_relation_size(c.oid) >= $1::bigint * 1024 * 1024
+	`
+	aoHiddenTuplesSql = `
+		SELECT dbname, schema_name, table_name, sum((h).hidden_tupcount), sum((h).total_tupcount)
+		FROM (
+			SELECT current_database() dbname, n.nspname schema_name, c.relname table_name,
+				gp_toolkit.__gp_aovisimap_hidden_info(c.oid) h
+			FROM pg_appendonly a
+				JOIN pg_class c ON c.oid = a.relid
+				JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE pg_relation_size(c.oid) >= $1::bigint * 1024 * 1024
+		) t
+		GROUP BY dbname, schema_name, table_name
+	`
+	// 行存表每个段文件一行，列存表每个段文件每列一行，取各segment上段文件数的最大值
+	aoSegfileCountSql_V6 = `
+		SELECT current_database(), n.nspname, c.relname, max(t.segfiles)
+		FROM (
+			SELECT relid, (s).segment_id, count(distinct (s).segno) segfiles
+			FROM (
+				SELECT a.relid, gp_toolkit.__gp_aoseg(a.relid) s FROM pg_appendonly a WHERE not a.columnstore
+			) r
+			GROUP BY relid, (s).segment_id
+			UNION ALL
+			SELECT relid, (s).segment_id, count(distinct (s).segno) segfiles
+			FROM (
+				SELECT a.relid, gp_toolkit.__gp_aocsseg(a.relid) s FROM pg_appendonly a WHERE a.columnstore
+			) r
+			GROUP BY relid, (s).segment_id
+		) t
+			JOIN pg_class c ON c.oid = t.relid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+		GROUP BY n.nspname, c.relname
+		HAVING max(t.segfiles) >= $1
+	`
+	aoSegfileCountSql_V7 = `
+		SELECT current_database(), n.nspname, c.relname, max(t.segfiles)
+		FROM (
+			SELECT relid, (s).segment_id, count(distinct (s).segno) segfiles
+			FROM (
+				SELECT a.relid, gp_toolkit.__gp_aoseg(a.relid) s
+				FROM pg_appendonly a JOIN pg_class c ON c.oid = a.relid JOIN pg_am m ON m.oid = c.relam
+				WHERE m.amname = 'ao_row'
+			) r
+			GROUP BY relid, (s).segment_id
+			UNION ALL
+			SELECT relid, (s).segment_id, count(distinct (s).segno) segfiles
+			FROM (
+				SELECT a.relid, gp_toolkit.__gp_aocsseg(a.relid) s
+				FROM pg_appendonly a JOIN pg_class c ON c.oid = a.relid JOIN pg_am m ON m.oid = c.relam
+				WHERE m.amname = 'ao_column'
+			) r
+			GROUP BY relid, (s).segment_id
+		) t
+			JOIN pg_class c ON c.oid = t.relid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+		GROUP BY n.nspname, c.relname
+		HAVING max(t.segfiles) >= $1
+	`
+)
+
+var (
+	aoCompressionRatioDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "ao_table_compression_ratio"),
+		"Compression ratio of each large append-optimized table",
+		[]string{"dbname", "schema", "table"}, nil,
+	)
+
+	aoHiddenTuplesDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "ao_table_hidden_tuples"),
+		"Number of hidden(deleted or updated) tuples of each large append-optimized table",
+		[]string{"dbname", "schema", "table"}, nil,
+	)
+
+	aoNeedsCompactionDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "ao_table_needs_compaction"),
+		"Whether the hidden tuple percent of the append-optimized table exceeds the compaction threshold",
+		[]string{"dbname", "schema", "table"}, nil,
+	)
+
+	aoSegfileCountDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "ao_table_segfile_count"),
+		"Max number of segment files on a single segment of each fragmented append-optimized table",
+		[]string{"dbname", "schema", "table"}, nil,
+	)
+)
+
+func NewAOTablesScraper(conns *ConnectionCache) Scraper {
+	return aoTablesScraper{
+		minSizeMB:         envInt(aoTableMinSizeEnv, 1024),
+		compactionPercent: envFloat(aoCompactionPercentEnv, 10),
+		minSegfiles:       envInt(aoSegfileMinCountEnv, 32),
+		sampler:           newTableSampler(),
+		conns:             conns,
+	}
+}
+
+type aoTablesScraper struct {
+	minSizeMB         int
+	compactionPercent float64
+	minSegfiles       int
+	sampler           tableSampler
+	conns             *ConnectionCache
+}
+
+func (aoTablesScraper) Name() string {
+	return "ao_tables_scraper"
+}
+
+func (s aoTablesScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	return forEachDatabase(ctx, db, s.conns, ch, func(ctx context.Context, conn *sql.DB, dbname string, ch chan<- prometheus.Metric) error {
+		errR := scrapeAOCompressionRatio(ctx, conn, ch, s.minSizeMB, s.sampler)
+		errH := scrapeAOHiddenTuples(ctx, conn, ch, s.minSizeMB, s.compactionPercent, s.sampler)
+		errS := scrapeAOSegfileCount(ctx, conn, ch, ver, s.minSegfiles, s.sampler)
+
+		return combineErr(
+			wrapErr("compression_ratio", errR),
+			wrapErr("hidden_tuples", errH),
+			wrapErr("segfile_count", errS),
+		)
+	})
+}
+
+func scrapeAOCompressionRatio(ctx context.Context, conn *sql.DB, ch chan<- prometheus.Metric, minSizeMB int, sampler tableSampler) error {
+	logger.Debugf("Query Database: %s", aoCompressionRatioSql)
+	rows, err := conn.QueryContext(ctx, aoCompressionRatioSql, minSizeMB)
+
+	if err != nil {
+		if isUndefinedObject(err) {
+			logger.Warnf("skip ao compression ratio metrics, error:%v", err)
+			return nil
+		}
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var dbname, schema, table string
+		var ratio sql.NullFloat64
+
+		err = rows.Scan(&dbname, &schema, &table, &ratio)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if !sampler.sampled(dbname, schema, table) {
+			continue
+		}
+
+		// 返回-1表示无法计算压缩率
+		if !ratio.Valid || ratio.Float64 < 0 {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(aoCompressionRatioDesc, prometheus.GaugeValue, ratio.Float64, dbname, schema, table)
+	}
+
+	return combineErr(errs...)
+}
+
+func scrapeAOHiddenTuples(ctx context.Context, conn *sql.DB, ch chan<- prometheus.Metric, minSizeMB int, compactionPercent float64, sampler tableSampler) error {
+	logger.Debugf("Query Database: %s", aoHiddenTuplesSql)
+	rows, err := conn.QueryContext(ctx, aoHiddenTuplesSql, minSizeMB)
+
+	if err != nil {
+		if isUndefinedObject(err) {
+			logger.Warnf("skip ao hidden tuples metrics, error:%v", err)
+			return nil
+		}
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var dbname, schema, table string
+		var hidden, total sql.NullFloat64
+
+		err = rows.Scan(&dbname, &schema, &table, &hidden, &total)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if !sampler.sampled(dbname, schema, table) {
+			continue
+		}
+
+		var needsCompaction float64
+		if total.Float64 > 0 && hidden.Float64*100/total.Float64 > compactionPercent {
+			needsCompaction = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(aoHiddenTuplesDesc, prometheus.GaugeValue, hidden.Float64, dbname, schema, table)
+		ch <- prometheus.MustNewConstMetric(aoNeedsCompactionDesc, prometheus.GaugeValue, needsCompaction, dbname, schema, table)
+	}
+
+	return combineErr(errs...)
+}
+
+func scrapeAOSegfileCount(ctx context.Context, conn *sql.DB, ch chan<- prometheus.Metric, ver int, minSegfiles int, sampler tableSampler) error {
+	// Greenplum 7 的pg_appendonly中去掉了columnstore字段，改用表访问方法区分行存和列存
+	querySql := aoSegfileCountSql_V7
+	if ver < 7 {
+		querySql = aoSegfileCountSql_V6
+	}
+
+	logger.Debugf("Query Database: %s", querySql)
+	rows, err := conn.QueryContext(ctx, querySql, minSegfiles)
+
+	if err != nil {
+		if isUndefinedObject(err) {
+			logger.Warnf("skip ao segfile count metrics, error:%v", err)
+			return nil
+		}
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var dbname, schema, table string
+		var segfiles float64
+
+		err = rows.Scan(&dbname, &schema, &table, &segfiles)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if !sampler.sampled(dbname, schema, table) {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(aoSegfileCountDesc, prometheus.GaugeValue, segfiles, dbname, schema, table)
+	}
+
+	return combineErr(errs...)
+}
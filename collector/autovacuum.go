@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/**
+ *  autovacuum工作进程抓取器：统计正在运行的autovacuum工作进程数量及autovacuum_max_workers配置，
+ *  工作进程长时间达到上限说明autovacuum跟不上，Greenplum 5不支持autovacuum，不抓取
+ */
+
+const (
+	autovacuumWorkersSql_V6 = `SELECT count(*) from pg_stat_activity WHERE query ilike 'autovacuum:%';`
+	autovacuumWorkersSql_V7 = `SELECT count(*) from pg_stat_activity WHERE backend_type = 'autovacuum worker';`
+	autovacuumMaxWorkersSql = `SELECT current_setting('autovacuum_max_workers')::float8;`
+)
+
+var (
+	autovacuumWorkersDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "autovacuum_workers_active"),
+		"Number of autovacuum worker backends currently running",
+		nil, nil,
+	)
+
+	autovacuumMaxWorkersDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "autovacuum_max_workers"),
+		"Maximum number of autovacuum worker processes from autovacuum_max_workers",
+		nil, nil,
+	)
+)
+
+func NewAutovacuumScraper() Scraper {
+	return autovacuumScraper{}
+}
+
+type autovacuumScraper struct{}
+
+func (autovacuumScraper) VersionRange() (min, max int) {
+	return 6, 0
+}
+
+func (autovacuumScraper) Name() string {
+	return "autovacuum_scraper"
+}
+
+func (autovacuumScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	if ver < 6 {
+		return nil
+	}
+
+	querySql := autovacuumWorkersSql_V7
+	if ver < 7 {
+		querySql = autovacuumWorkersSql_V6
+	}
+
+	errA := scrapeScalarGauge(ctx, db, ch, autovacuumWorkersDesc, querySql)
+	errB := scrapeScalarGauge(ctx, db, ch, autovacuumMaxWorkersDesc, autovacuumMaxWorkersSql)
+
+	return combineErr(wrapErr("workers", skipScalarNull(errA)), wrapErr("max_workers", skipScalarNull(errB)))
+}
@@ -0,0 +1,75 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  后端内存抓取器：pg_backend_memory_contexts从PostgreSQL 14开始提供，只统计exporter自身连接的后端进程，
+ *  基于更早PostgreSQL版本的Greenplum(包括Greenplum 7)中没有该视图，抓取时跳过
+ */
+
+const (
+	backendMemorySql = `SELECT name, sum(total_bytes) from pg_backend_memory_contexts GROUP BY name;`
+)
+
+var (
+	backendMemoryDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "backend_memory_bytes"),
+		"Memory allocated by the exporter's own backend grouped by memory context name",
+		[]string{"context"}, nil,
+	)
+)
+
+func NewBackendMemoryScraper() Scraper {
+	return backendMemoryScraper{}
+}
+
+type backendMemoryScraper struct{}
+
+func (backendMemoryScraper) VersionRange() (min, max int) {
+	return 7, 0
+}
+
+func (backendMemoryScraper) Name() string {
+	return "backend_memory_scraper"
+}
+
+func (backendMemoryScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	if ver < 7 {
+		return nil
+	}
+
+	logger.Debugf("Query Database: %s", backendMemorySql)
+	rows, err := db.QueryContext(ctx, backendMemorySql)
+
+	if err != nil {
+		if isUndefinedObject(err) {
+			logger.Warnf("skip backend memory metrics, error:%v", err)
+			return nil
+		}
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var name string
+		var bytes float64
+
+		err = rows.Scan(&name, &bytes)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(backendMemoryDesc, prometheus.GaugeValue, bytes, name)
+	}
+
+	return combineErr(errs...)
+}
@@ -1,10 +1,10 @@
 package collector
 
 import (
-	"database/sql"
+	"context"
 	"errors"
 	"github.com/prometheus/client_golang/prometheus"
-	logger "github.com/prometheus/common/log"
+	logger "greenplum-exporter/logging"
 	"time"
 )
 
@@ -19,77 +19,77 @@ const (
 )
 
 var (
-	checkpointsTimedDesc = prometheus.NewDesc(
+	checkpointsTimedDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemServer, "bgwriter_checkpoints_timed_total"),
 		"Number of scheduled checkpoints that have been performed",
 		nil,
 		nil,
 	)
 
-	checkpointsReqDesc = prometheus.NewDesc(
+	checkpointsReqDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemServer, "bgwriter_checkpoints_req_total"),
 		"Number of requested checkpoints that have been performed",
 		nil,
 		nil,
 	)
 
-	checkpointWriteTimeDesc = prometheus.NewDesc(
+	checkpointWriteTimeDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemServer, "bgwriter_checkpoint_write_time_seconds_total"),
 		"Total amount of time that has been spent in the portion of checkpoint processing where files are written to disk",
 		nil,
 		nil,
 	)
 
-	checkpointSyncTimeDesc = prometheus.NewDesc(
+	checkpointSyncTimeDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemServer, "bgwriter_checkpoint_sync_time_seconds_total"),
 		"Total amount of time that has been spent in the portion of checkpoint processing where files are synchronized to disk",
 		nil,
 		nil,
 	)
 
-	buffersCheckpointDesc = prometheus.NewDesc(
+	buffersCheckpointDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemServer, "bgwriter_buffers_checkpoint_total"),
 		"Number of buffers written during checkpoints",
 		nil,
 		nil,
 	)
 
-	buffersCleanDesc = prometheus.NewDesc(
+	buffersCleanDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemServer, "bgwriter_buffers_clean_total"),
 		"Number of buffers written by the background writer",
 		nil,
 		nil,
 	)
 
-	maxWrittenCleanDesc = prometheus.NewDesc(
+	maxWrittenCleanDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemServer, "bgwriter_maxwritten_clean_total"),
 		"Number of times the background writer stopped a cleaning scan because it had written too many buffers",
 		nil,
 		nil,
 	)
 
-	buffersBackendDesc = prometheus.NewDesc(
+	buffersBackendDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemServer, "bgwriter_buffers_backend_total"),
 		"Number of buffers written directly by a backend",
 		nil,
 		nil,
 	)
 
-	buffersBackendFsyncDesc = prometheus.NewDesc(
+	buffersBackendFsyncDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemServer, "bgwriter_buffers_backend_fsync_total"),
 		"Number of times a backend had to execute its own fsync call",
 		nil,
 		nil,
 	)
 
-	buffersAllocDesc = prometheus.NewDesc(
+	buffersAllocDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemServer, "bgwriter_buffers_alloc_total"),
 		"Number of buffers allocated",
 		nil,
 		nil,
 	)
 
-	statsResetDesc = prometheus.NewDesc(
+	statsResetDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemServer, "bgwriter_stats_reset_timestamp"),
 		"Time at which these statistics were last reset",
 		nil,
@@ -107,17 +107,16 @@ func (bgWriterStateScraper) Name() string {
 	return "bg_writer_state_scraper"
 }
 
-func (bgWriterStateScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric, ver int) error {
+func (bgWriterStateScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
 	querySql :=statBgwriterSql_V6;
 	if ver < 6{
 		querySql=statBgwriterSql_V5;
 	}
 
-	rows, err := db.Query(querySql)
-	logger.Infof("Query Database: %s", querySql)
+	rows, err := db.QueryContext(ctx, querySql)
+	logger.Debugf("Query Database: %s", querySql)
 
 	if err != nil {
-		ch <- prometheus.MustNewConstMetric(stateDesc, prometheus.GaugeValue, 0, "", "")
 		logger.Errorf("get metrics for scraper, error:%v", err.Error())
 		return err
 	}
@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/**
+ *  系统表一致性检查抓取器：Greenplum不记录gpcheckcat的执行时间，由运维人员在gpcheckcat执行成功后
+ *  更新GPDB_CATALOG_CHECK_FILE指定的文件，文件内容为检查完成的unix时间戳，内容为空时使用文件的修改时间
+ */
+
+const (
+	catalogCheckFileEnv = "GPDB_CATALOG_CHECK_FILE"
+)
+
+var (
+	lastCatalogCheckDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "last_catalog_check_seconds"),
+		"Seconds since the last catalog consistency check recorded in GPDB_CATALOG_CHECK_FILE",
+		nil, nil,
+	)
+)
+
+func NewCatalogCheckScraper() Scraper {
+	return catalogCheckScraper{path: os.Getenv(catalogCheckFileEnv)}
+}
+
+type catalogCheckScraper struct {
+	path string
+}
+
+func (catalogCheckScraper) Name() string {
+	return "catalog_check_scraper"
+}
+
+func (s catalogCheckScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	if s.path == "" {
+		return nil
+	}
+
+	checkTime, err := readCatalogCheckTime(s.path)
+	if err != nil {
+		return err
+	}
+
+	ch <- prometheus.MustNewConstMetric(lastCatalogCheckDesc, prometheus.GaugeValue, time.Since(checkTime).Seconds())
+
+	return nil
+}
+
+/**
+* 函数：readCatalogCheckTime
+* 功能：读取文件中记录的检查时间(unix时间戳)，文件内容为空时返回文件的修改时间
+ */
+func readCatalogCheckTime(path string) (time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	value := strings.TrimSpace(string(content))
+	if value == "" {
+		return info.ModTime(), nil
+	}
+
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(0, int64(seconds*float64(time.Second))), nil
+}
@@ -1,10 +1,10 @@
 package collector
 
 import (
-	"database/sql"
+	"context"
 	"errors"
 	"github.com/prometheus/client_golang/prometheus"
-	logger "github.com/prometheus/common/log"
+	logger "greenplum-exporter/logging"
 	"time"
 )
 
@@ -19,32 +19,47 @@ const (
 	standbyNameSql    = `SELECT hostname from gp_segment_configuration where content=-1 and role='m'`
 	upTimeSql         = `select extract(epoch from now() - pg_postmaster_start_time())`
 	syncSql           = `SELECT count(*) from pg_stat_replication where state='streaming'`
+	// 比较master与各primary segment的版本字符串，standby无法通过查询获取版本
+	versionCountSql   = `select count(distinct v) from (select version() v from gp_dist_random('gp_id') union all select version()) t`
 	configLoadTimeSql_V6 = `SELECT pg_conf_load_time() `
 	configLoadTimeSql_V5 = `select '2020-06-16 22:09:47.078+08'::timestamp as pg_conf_load_time; `
 )
 
 var (
-	stateDesc = prometheus.NewDesc(
+	stateDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemCluster, "state"),
 		"Whether the GreenPlum database is accessible",
 		[]string{"version", "master", "standby"},
 		nil,
 	)
 
-	upTimeDesc = prometheus.NewDesc(
+	upTimeDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemCluster, "uptime"),
 		"Duration that the GreenPlum database have been started since last up in second",
 		nil, nil,
 	)
 
-	syncDesc = prometheus.NewDesc(
+	serverUpTimeDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "uptime_seconds"),
+		"Seconds since the coordinator(master) postmaster was started",
+		nil, nil,
+	)
+
+	syncDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemCluster, "sync"),
 		"Whether the GreenPlum master node is synchronizing to standby",
 		nil,
 		nil,
 	)
 
-	configLoadTimeDesc = prometheus.NewDesc(
+	versionMismatchDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "version_mismatch"),
+		"Whether the master and primary segments report different GreenPlum versions",
+		nil,
+		nil,
+	)
+
+	configLoadTimeDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemCluster, "config_last_load_time_seconds"),
 		"Timestamp of the last configuration reload",
 		nil,
@@ -62,12 +77,12 @@ func (clusterStateScraper) Name() string {
 	return "cluster_state_scraper"
 }
 
-func (clusterStateScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric, ver int) error {
-	rows, err := db.Query(checkStateSql)
-	logger.Infof("Query Database: %s", checkStateSql)
+func (clusterStateScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	rows, err := db.QueryContext(ctx, checkStateSql)
+	logger.Debugf("Query Database: %s", checkStateSql)
 
 	if err != nil {
-		ch <- prometheus.MustNewConstMetric(stateDesc, prometheus.GaugeValue, 0, "", "")
+		ch <- prometheus.MustNewConstMetric(stateDesc, prometheus.GaugeValue, 0, "", "", "")
 		logger.Errorf("get metrics for scraper, error:%v", err.Error())
 		return err
 	}
@@ -78,30 +93,48 @@ func (clusterStateScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric, ver i
 		var count int
 		err = rows.Scan(&count)
 		if err != nil {
-			ch <- prometheus.MustNewConstMetric(stateDesc, prometheus.GaugeValue, 0, "", "")
+			ch <- prometheus.MustNewConstMetric(stateDesc, prometheus.GaugeValue, 0, "", "", "")
 			logger.Errorf("get metrics for scraper, error:%v", err.Error())
 			return err
 		}
 	}
 
-	version, errV := scrapeVersion(db)
-	master, errM := scrapeMaster(db)
-	standby, errX := scrapeStandby(db)
-	upTime, errU := scrapeUpTime(db)
-	sync, errW := scrapeSync(db)
-	configLoadTime, errY := scrapeConfigLoadTime(db, ver)
+	version, errV := scrapeVersion(ctx, db)
+	master, errM := scrapeMaster(ctx, db)
+	standby, errX := scrapeStandby(ctx, db)
+	upTime, errU := scrapeUpTime(ctx, db)
+	sync, errW := scrapeSync(ctx, db)
+	configLoadTime, errY := scrapeConfigLoadTime(ctx, db, ver)
+	versionCount, errZ := scrapeVersionCount(ctx, db)
 
 	ch <- prometheus.MustNewConstMetric(stateDesc, prometheus.GaugeValue, 1, version, master, standby)
 	ch <- prometheus.MustNewConstMetric(upTimeDesc, prometheus.GaugeValue, upTime)
+	ch <- prometheus.MustNewConstMetric(serverUpTimeDesc, prometheus.GaugeValue, upTime)
 	ch <- prometheus.MustNewConstMetric(syncDesc, prometheus.GaugeValue, sync)
 	ch <- prometheus.MustNewConstMetric(configLoadTimeDesc, prometheus.GaugeValue, float64(configLoadTime.UTC().Unix()))
 
-	return combineErr(errM, errV, errU, errW, errX, errY)
+	if errZ == nil {
+		var mismatch float64
+		if versionCount > 1 {
+			mismatch = 1
+		}
+		ch <- prometheus.MustNewConstMetric(versionMismatchDesc, prometheus.GaugeValue, mismatch)
+	}
+
+	return combineErr(
+		wrapErr("master", errM),
+		wrapErr("version", errV),
+		wrapErr("uptime", errU),
+		wrapErr("sync", errW),
+		wrapErr("standby", errX),
+		wrapErr("config_load_time", errY),
+		wrapErr("version_count", errZ),
+	)
 }
 
-func scrapeUpTime(db *sql.DB) (upTime float64, err error) {
-	rows, err := db.Query(upTimeSql)
-	logger.Infof("Query Database Up Time: %s", upTimeSql)
+func scrapeUpTime(ctx context.Context, db Queryer) (upTime float64, err error) {
+	rows, err := db.QueryContext(ctx, upTimeSql)
+	logger.Debugf("Query Database Up Time: %s", upTimeSql)
 
 	if err != nil {
 		logger.Errorf("get metrics for scraper, error:%v", err.Error())
@@ -120,9 +153,9 @@ func scrapeUpTime(db *sql.DB) (upTime float64, err error) {
 	return
 }
 
-func scrapeVersion(db *sql.DB) (ver string, err error) {
-	rows, err := db.Query(versionSql)
-	logger.Infof("Query Database Version: %s", versionSql)
+func scrapeVersion(ctx context.Context, db Queryer) (ver string, err error) {
+	rows, err := db.QueryContext(ctx, versionSql)
+	logger.Debugf("Query Database Version: %s", versionSql)
 
 	if err != nil {
 		return
@@ -139,9 +172,28 @@ func scrapeVersion(db *sql.DB) (ver string, err error) {
 	return
 }
 
-func scrapeMaster(db *sql.DB) (host string, err error) {
-	rows, err := db.Query(masterNameSql)
-	logger.Infof("Query Database Master Name: %s", masterNameSql)
+func scrapeVersionCount(ctx context.Context, db Queryer) (count float64, err error) {
+	rows, err := db.QueryContext(ctx, versionCountSql)
+	logger.Debugf("Query Database Version Count: %s", versionCountSql)
+
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&count)
+		return
+	}
+
+	err = errors.New("greenPlum segment versions not found")
+	return
+}
+
+func scrapeMaster(ctx context.Context, db Queryer) (host string, err error) {
+	rows, err := db.QueryContext(ctx, masterNameSql)
+	logger.Debugf("Query Database Master Name: %s", masterNameSql)
 
 	if err != nil {
 		return
@@ -159,9 +211,9 @@ func scrapeMaster(db *sql.DB) (host string, err error) {
 	return
 }
 
-func scrapeStandby(db *sql.DB) (host string, err error) {
-	rows, err := db.Query(standbyNameSql)
-	logger.Infof("Query Database Standby Name: %s", standbyNameSql)
+func scrapeStandby(ctx context.Context, db Queryer) (host string, err error) {
+	rows, err := db.QueryContext(ctx, standbyNameSql)
+	logger.Debugf("Query Database Standby Name: %s", standbyNameSql)
 
 	if err != nil {
 		return
@@ -177,9 +229,9 @@ func scrapeStandby(db *sql.DB) (host string, err error) {
 	return
 }
 
-func scrapeSync(db *sql.DB) (sync float64, err error) {
-	rows, err := db.Query(syncSql)
-	logger.Infof("Query Database Sync : %s", syncSql)
+func scrapeSync(ctx context.Context, db Queryer) (sync float64, err error) {
+	rows, err := db.QueryContext(ctx, syncSql)
+	logger.Debugf("Query Database Sync : %s", syncSql)
 
 	if err != nil {
 		return
@@ -196,14 +248,14 @@ func scrapeSync(db *sql.DB) (sync float64, err error) {
 	return
 }
 
-func scrapeConfigLoadTime(db *sql.DB, ver int) (time time.Time, err error) {
+func scrapeConfigLoadTime(ctx context.Context, db Queryer, ver int) (time time.Time, err error) {
 	querySql:=configLoadTimeSql_V6
 	if ver < 6{
 		querySql=configLoadTimeSql_V5;
 	}
 
-	rows, err := db.Query(querySql)
-	logger.Infof("Query Database Config load Time : %s", querySql)
+	rows, err := db.QueryContext(ctx, querySql)
+	logger.Debugf("Query Database Config load Time : %s", querySql)
 
 	if err != nil {
 		return
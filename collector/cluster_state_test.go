@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestClusterStateScraper(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta(checkStateSql)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+	mock.ExpectQuery(regexp.QuoteMeta(versionSql)).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow("6.20.0"))
+	mock.ExpectQuery(regexp.QuoteMeta(masterNameSql)).WillReturnRows(sqlmock.NewRows([]string{"hostname"}).AddRow("mdw"))
+	mock.ExpectQuery(regexp.QuoteMeta(standbyNameSql)).WillReturnRows(sqlmock.NewRows([]string{"hostname"}).AddRow("smdw"))
+	mock.ExpectQuery(regexp.QuoteMeta(upTimeSql)).WillReturnRows(sqlmock.NewRows([]string{"uptime"}).AddRow(3600))
+	mock.ExpectQuery(regexp.QuoteMeta(syncSql)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(configLoadTimeSql_V6)).WillReturnRows(sqlmock.NewRows([]string{"pg_conf_load_time"}).AddRow(time.Unix(1600000000, 0)))
+	mock.ExpectQuery(regexp.QuoteMeta(versionCountSql)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	expected := `
+# HELP greenplum_cluster_state Whether the GreenPlum database is accessible
+# TYPE greenplum_cluster_state gauge
+greenplum_cluster_state{master="mdw",standby="smdw",version="6.20.0"} 1
+# HELP greenplum_cluster_uptime Duration that the GreenPlum database have been started since last up in second
+# TYPE greenplum_cluster_uptime gauge
+greenplum_cluster_uptime 3600
+# HELP greenplum_server_uptime_seconds Seconds since the coordinator(master) postmaster was started
+# TYPE greenplum_server_uptime_seconds gauge
+greenplum_server_uptime_seconds 3600
+# HELP greenplum_cluster_sync Whether the GreenPlum master node is synchronizing to standby
+# TYPE greenplum_cluster_sync gauge
+greenplum_cluster_sync 1
+# HELP greenplum_cluster_config_last_load_time_seconds Timestamp of the last configuration reload
+# TYPE greenplum_cluster_config_last_load_time_seconds gauge
+greenplum_cluster_config_last_load_time_seconds 1.6e+09
+# HELP greenplum_cluster_version_mismatch Whether the master and primary segments report different GreenPlum versions
+# TYPE greenplum_cluster_version_mismatch gauge
+greenplum_cluster_version_mismatch 1
+`
+	if err := collectAndCompare(t, NewClusterStateScraper(), db, 6, expected); err != nil {
+		t.Errorf("unexpected scrape error: %v", err)
+	}
+}
+
+func TestClusterStateScraperErrors(t *testing.T) {
+	unreachable := `
+# HELP greenplum_cluster_state Whether the GreenPlum database is accessible
+# TYPE greenplum_cluster_state gauge
+greenplum_cluster_state{master="",standby="",version=""} 0
+`
+
+	t.Run("query_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(checkStateSql)).WillReturnError(errors.New("connection reset"))
+
+		if err := collectAndCompare(t, NewClusterStateScraper(), db, 6, unreachable); err == nil {
+			t.Error("expected the query error")
+		}
+	})
+
+	t.Run("scan_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(checkStateSql)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow("abc"))
+
+		if err := collectAndCompare(t, NewClusterStateScraper(), db, 6, unreachable); err == nil {
+			t.Error("expected the scan error")
+		}
+	})
+
+	// 各项查询为空时仍输出可访问状态，standby不存在不报错，版本比较结果缺失时不输出
+	t.Run("empty", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectEmptyQueries(mock, checkStateSql, versionSql, masterNameSql, standbyNameSql, upTimeSql, syncSql, configLoadTimeSql_V5, versionCountSql)
+
+		expected := `
+# HELP greenplum_cluster_state Whether the GreenPlum database is accessible
+# TYPE greenplum_cluster_state gauge
+greenplum_cluster_state{master="",standby="",version=""} 1
+# HELP greenplum_cluster_sync Whether the GreenPlum master node is synchronizing to standby
+# TYPE greenplum_cluster_sync gauge
+greenplum_cluster_sync 0
+`
+		err := collectAndCompare(t, NewClusterStateScraper(), db, 5, expected,
+			"greenplum_cluster_state", "greenplum_cluster_sync", "greenplum_cluster_version_mismatch")
+		expectErrLabels(t, err, "master", "version", "uptime", "sync", "config_load_time", "version_count")
+		if err != nil && strings.Contains(err.Error(), "standby: ") {
+			t.Errorf("expected no error of standby, got %v", err)
+		}
+	})
+}
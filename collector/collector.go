@@ -1,17 +1,26 @@
 package collector
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	_ "github.com/lib/pq"
 	"github.com/prometheus/client_golang/prometheus"
 	"greenplum-exporter/stopwatch"
-	logger "github.com/prometheus/common/log"
-	"os"
+	logger "greenplum-exporter/logging"
+	"runtime/debug"
 	"sync"
 	"time"
 )
 
+const gpRoleSql = `select current_setting('gp_role');`
+
+// greenplum_up的检查只执行最简单的查询，使用单独的较短超时时间，不受抓取器查询的影响
+const (
+	upCheckSql     = `SELECT 1;`
+	upCheckTimeout = time.Second * 2
+)
+
 const verMajorSql=`select (select regexp_matches((select (select regexp_matches((select version()), 'Greenplum Database \d{1,}\.\d{1,}\.\d{1,}'))[1] as version), '\d{1,}'))[1];`
 
 // 定义采集器数据类型结构体
@@ -22,16 +31,30 @@ type GreenPlumCollector struct {
 	ver       int
 	metrics  *ExporterMetrics
 	scrapers []Scraper
+	filter   *metricFilter
+	statuses *scraperStatuses
+	// 为true时所有抓取器在同一个只读事务中执行，见snapshot.go
+	consistentSnapshot bool
+	// 每个抓取器的默认超时时间
+	scrapeTimeout time.Duration
+	// 抓取结果缓存，见scrape_cache.go
+	cache *scrapeCache
 }
 
 /**
 * 函数：NewCollector
 * 功能：采集器的生成工厂方法
  */
-func NewCollector(enabledScrapers []Scraper) *GreenPlumCollector {
+func NewCollector(enabledScrapers []Scraper, scrapeTimeout, cacheTTL time.Duration) *GreenPlumCollector {
 	return &GreenPlumCollector{
 		metrics:  NewMetrics(),
 		scrapers: enabledScrapers,
+		filter:   newMetricFilter(),
+		statuses: newScraperStatuses(),
+
+		consistentSnapshot: envBool(consistentSnapshotEnv, false),
+		scrapeTimeout:      scrapeTimeout,
+		cache:              newScrapeCache(cacheTTL),
 	}
 }
 
@@ -49,6 +72,17 @@ func (c *GreenPlumCollector) Collect(ch chan<- prometheus.Metric) {
 	ch <- c.metrics.totalError
 	ch <- c.metrics.scrapeDuration
 	ch <- c.metrics.greenPlumUp
+	ch <- c.metrics.lastScrapeTime
+	ch <- c.metrics.utilityMode
+	c.metrics.scrapeErrors.Collect(ch)
+	c.metrics.incomplete.Collect(ch)
+	c.metrics.emitted.Collect(ch)
+	c.metrics.connectSeconds.Collect(ch)
+	c.metrics.querySeconds.Collect(ch)
+	c.metrics.success.Collect(ch)
+	c.metrics.duration.Collect(ch)
+	c.metrics.buildInfo.Collect(ch)
+	dbConnectSeconds.Collect(ch)
 }
 
 /**
@@ -57,9 +91,54 @@ func (c *GreenPlumCollector) Collect(ch chan<- prometheus.Metric) {
  */
 func (c *GreenPlumCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.metrics.greenPlumUp.Desc()
+	ch <- c.metrics.lastScrapeTime.Desc()
+	ch <- c.metrics.utilityMode.Desc()
 	ch <- c.metrics.scrapeDuration.Desc()
 	ch <- c.metrics.totalScraped.Desc()
 	ch <- c.metrics.totalError.Desc()
+	c.metrics.scrapeErrors.Describe(ch)
+	c.metrics.incomplete.Describe(ch)
+	c.metrics.emitted.Describe(ch)
+	c.metrics.connectSeconds.Describe(ch)
+	c.metrics.querySeconds.Describe(ch)
+	c.metrics.success.Describe(ch)
+	c.metrics.duration.Describe(ch)
+	c.metrics.buildInfo.Describe(ch)
+	dbConnectSeconds.Describe(ch)
+}
+
+/**
+* 函数：Reload
+* 功能：重新加载各抓取器基于文件的配置，单个抓取器失败时保留其原有配置
+ */
+func (c *GreenPlumCollector) Reload() {
+	for _, scraper := range c.scrapers {
+		reloader, ok := scraper.(Reloader)
+		if !ok {
+			continue
+		}
+
+		if err := reloader.Reload(); err != nil {
+			logger.Errorf("reload config for scraper:%s failed, error:%v", scraper.Name(), err)
+			continue
+		}
+
+		logger.Infof("reloaded config for scraper:%s", scraper.Name())
+	}
+}
+
+/**
+* 函数：Close
+* 功能：等待正在进行的抓取结束后关闭与coordinator的连接，exporter退出时调用
+ */
+func (c *GreenPlumCollector) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.db != nil {
+		_ = c.db.Close()
+		c.db = nil
+	}
 }
 
 /**
@@ -70,6 +149,17 @@ func (c *GreenPlumCollector) scrape(ch chan<- prometheus.Metric) {
 	start := time.Now()
 	watch := stopwatch.New("scrape")
 
+	// 所有抓取器的缓存都未过期时直接重放，不连接数据库
+	if c.cache.fresh(c.scrapers) {
+		for _, scraper := range c.scrapers {
+			c.replayCached(scraper, ch)
+		}
+
+		logger.Debugf("serve all scrapers from cache")
+
+		return
+	}
+
 	// 检查并与Greenplum建立连接
 	c.metrics.totalScraped.Inc()
 	watch.MustStart("check connections")
@@ -85,26 +175,154 @@ func (c *GreenPlumCollector) scrape(ch chan<- prometheus.Metric) {
 		return
 	}
 
-	defer c.db.Close()
-
-	logger.Info("check connections ok!")
+	// coordinator连接在多次抓取之间复用，由checkGreenPlumConn检查，exporter退出时由Close关闭
+	logger.Debugf("check connections ok!")
 	c.metrics.greenPlumUp.Set(1)
+	c.checkUtilityMode()
+
+	// 开启一致性快照失败时不使用快照，各抓取器照常执行
+	var snap *snapshot
+	if c.consistentSnapshot {
+		if snap, err = beginSnapshot(c.db); err != nil {
+			logger.Errorf("begin consistent snapshot failed, error:%v", err)
+		} else {
+			defer snap.end()
+		}
+	}
+
+	// 所有抓取器都成功时更新greenplum_last_scrape_timestamp_seconds
+	failed := false
 
 	// 遍历执行MAP中的所有抓取器
 	for _, scraper := range c.scrapers {
-		logger.Info("#### scraping start : " + scraper.Name())
+		if c.replayCached(scraper, ch) {
+			continue
+		}
+
+		logger.Debugf("#### scraping start : %s", scraper.Name())
 		watch.MustStart("scraping: " + scraper.Name())
-		err := scraper.Scrape(c.db, ch, c.ver)
+		tee, collected := c.cache.tee(ch)
+		out, wait := c.filter.wrap(tee)
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeoutOf(scraper))
+		scrapeOn := func(db Queryer) error {
+			return recoverScrape(func() error {
+				return scraper.Scrape(ctx, db, out, c.ver)
+			})
+		}
+		scrape := func() error {
+			return scrapeOn(c.db)
+		}
+		if snap != nil {
+			scrape = func() error {
+				return snap.run(scrapeOn)
+			}
+		}
+		timing := startScrapeTiming(c.db)
+		scrapeStart := time.Now()
+		err := wrapErr(scraper.Name(), scrape())
+		c.metrics.duration.WithLabelValues(scraper.Name()).Observe(time.Since(scrapeStart).Seconds())
+		cancel()
+		connectSeconds, querySeconds := timing.stop()
+		c.metrics.connectSeconds.WithLabelValues(scraper.Name()).Set(connectSeconds)
+		c.metrics.querySeconds.WithLabelValues(scraper.Name()).Set(querySeconds)
+		c.metrics.emitted.WithLabelValues(scraper.Name()).Set(float64(wait()))
+		c.cache.record(scraper.Name(), collected(), err)
 		watch.MustStop()
+		c.statuses.record(scraper.Name(), err)
+		if isIncomplete(err) {
+			c.metrics.incomplete.WithLabelValues(scraper.Name()).Set(1)
+		} else {
+			c.metrics.incomplete.WithLabelValues(scraper.Name()).Set(0)
+		}
 		if err != nil {
-			logger.Errorf("get metrics for scraper:%s failed, error:%v", scraper.Name(), err.Error())
+			failed = true
+			c.metrics.success.WithLabelValues(scraper.Name()).Set(0)
+
+			for _, e := range flattenErr(err) {
+				logger.Errorf("get metrics for scraper:%s failed, error:%v", scraper.Name(), e)
+			}
+
+			for _, category := range errCategories(err) {
+				c.metrics.scrapeErrors.WithLabelValues(scraper.Name(), category).Inc()
+			}
+		} else {
+			c.metrics.success.WithLabelValues(scraper.Name()).Set(1)
 		}
-		logger.Info("#### scraping end : " + scraper.Name())
+		logger.Debugf("#### scraping end : %s", scraper.Name())
 	}
 
 	c.metrics.scrapeDuration.Set(time.Since(start).Seconds())
 
-	logger.Info(fmt.Sprintf("prometheus scraped grennplum exporter successfully at %v, detail elapsed:%s", time.Now(), watch.PrettyPrint()))
+	if !failed {
+		c.metrics.lastScrapeTime.Set(float64(time.Now().Unix()))
+	}
+
+	logger.Debugf("prometheus scraped greenplum exporter successfully at %v, detail elapsed:%s", time.Now(), watch.PrettyPrint())
+}
+
+/**
+* 函数：replayCached
+* 功能：抓取器有未过期的缓存时发送缓存的指标并返回true
+ */
+func (c *GreenPlumCollector) replayCached(scraper Scraper, ch chan<- prometheus.Metric) bool {
+	metrics, ok := c.cache.get(scraper.Name())
+	if !ok {
+		return false
+	}
+
+	for _, m := range metrics {
+		ch <- m
+	}
+
+	return true
+}
+
+/**
+* 函数：recoverScrape
+* 功能：执行抓取器，抓取器panic时记录堆栈并作为错误返回，不影响其它抓取器和整个/metrics接口
+ */
+func recoverScrape(scrape func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("scraper panic: %v\n%s", r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return scrape()
+}
+
+/**
+* 函数：timeoutOf
+* 功能：获取抓取器的超时时间，实现了Timeouter的抓取器取其与默认超时时间中的较大者
+ */
+func (c *GreenPlumCollector) timeoutOf(scraper Scraper) time.Duration {
+	timeout := c.scrapeTimeout
+
+	if timeouter, ok := scraper.(Timeouter); ok && timeouter.Timeout() > timeout {
+		timeout = timeouter.Timeout()
+	}
+
+	return timeout
+}
+
+/**
+* 函数：checkUtilityMode
+* 功能：检查当前连接是否处于utility模式，集群以master-only模式(gpstart -m)启动时只能以utility模式连接
+ */
+func (c *GreenPlumCollector) checkUtilityMode() {
+	var role string
+	if err := c.db.QueryRow(gpRoleSql).Scan(&role); err != nil {
+		logger.Errorf("query gp_role failed, error:%v", err)
+		return
+	}
+
+	if role == "utility" {
+		logger.Warn("connected in utility mode, cluster wide metrics may be unavailable")
+		c.metrics.utilityMode.Set(1)
+	} else {
+		c.metrics.utilityMode.Set(0)
+	}
 }
 
 /**
@@ -116,7 +334,7 @@ func (c *GreenPlumCollector) checkGreenPlumConn() (err error) {
 		return c.getGreenPlumConnection()
 	}
 
-	if err = c.getGreenplumMajorVersion(c.db); err == nil {
+	if err = checkUp(c.db); err == nil {
 		return nil
 	} else {
 		_ = c.db.Close()
@@ -132,7 +350,11 @@ func (c *GreenPlumCollector) checkGreenPlumConn() (err error) {
 func (c *GreenPlumCollector) getGreenPlumConnection() error {
 	//使用PostgreSQL的驱动连接数据库，可参考如下教程：
 	//参考：https://blog.csdn.net/u010412301/article/details/85037685
-	dataSourceName := os.Getenv("GPDB_DATA_SOURCE_URL")
+	dataSourceName, err := defaultDataSourceName()
+
+	if err != nil {
+		return err
+	}
 
 	db, err := sql.Open("postgres", dataSourceName)
 
@@ -140,6 +362,11 @@ func (c *GreenPlumCollector) getGreenPlumConnection() error {
 		return err
 	}
 
+	if err = checkUp(db); err != nil {
+		_ = db.Close()
+		return err
+	}
+
 	if err = c.getGreenplumMajorVersion(db); err != nil {
 		_ = db.Close()
 		return err
@@ -153,6 +380,19 @@ func (c *GreenPlumCollector) getGreenPlumConnection() error {
 	return nil
 }
 
+/**
+* 函数：checkUp
+* 功能：在upCheckTimeout内执行SELECT 1，检查coordinator是否可达
+ */
+func checkUp(db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), upCheckTimeout)
+
+	defer cancel()
+
+	var one int
+	return db.QueryRowContext(ctx, upCheckSql).Scan(&one)
+}
+
 /**
 * 函数：getGreenplumMajorVersion
 * 功能：获取Greenplum数据库的主版本号
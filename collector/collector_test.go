@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const countingScraperSql = `select count(*) from pg_stat_activity;`
+
+var countingScraperDesc = newDesc("greenplum_test_backends", "Number of backends", nil, nil)
+
+// 记录抓取次数的抓取器
+type countingScraper struct {
+	calls *int32
+}
+
+func (countingScraper) Name() string {
+	return "counting_scraper"
+}
+
+func (s countingScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	atomic.AddInt32(s.calls, 1)
+
+	return scrapeScalarGauge(ctx, db, ch, countingScraperDesc, countingScraperSql)
+}
+
+// ttl内并发的Collect只查询一次数据库，其余请求重放缓存的指标
+func TestCollectConcurrentWithinCacheTTL(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta(upCheckSql)).WillReturnRows(sqlmock.NewRows([]string{"?column?"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(gpRoleSql)).WillReturnRows(sqlmock.NewRows([]string{"current_setting"}).AddRow("dispatch"))
+	mock.ExpectQuery(regexp.QuoteMeta(countingScraperSql)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+
+	var calls int32
+	c := NewCollector([]Scraper{countingScraper{calls: &calls}}, time.Second*10, time.Minute)
+	c.db = db
+	c.ver = 6
+
+	const n = 8
+
+	var wg sync.WaitGroup
+	received := make([]int32, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			ch := make(chan prometheus.Metric)
+			go func() {
+				c.Collect(ch)
+				close(ch)
+			}()
+
+			for m := range ch {
+				if m.Desc() == countingScraperDesc {
+					atomic.AddInt32(&received[i], 1)
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("expected the scraper to run once within the ttl, ran %d times", calls)
+	}
+
+	for i, count := range received {
+		if count != 1 {
+			t.Errorf("collect %d received %d samples of the scraper, expected 1", i, count)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  按库连接缓存：按数据库名称缓存连接池，每个数据库只保留一个连接，在多次抓取之间复用，
+ *  避免每次抓取都为每个数据库重新建立连接。在exporter启动时创建一次并传给需要按库连接的抓取器，
+ *  数据库被删除后在下一次列出数据库时关闭其连接池
+ */
+
+type ConnectionCache struct {
+	mu sync.Mutex
+
+	conns map[string]*sql.DB
+}
+
+/**
+* 函数：NewConnectionCache
+* 功能：按库连接缓存的生成工厂方法
+ */
+func NewConnectionCache() *ConnectionCache {
+	return &ConnectionCache{conns: make(map[string]*sql.DB)}
+}
+
+/**
+* 函数：get
+* 功能：获取指定数据库的连接池，不存在或已失效时通过openDatabase建立并缓存
+ */
+func (c *ConnectionCache) get(ctx context.Context, dbname string) (*sql.DB, error) {
+	c.mu.Lock()
+	conn, ok := c.conns[dbname]
+	c.mu.Unlock()
+
+	// 每次获取缓存的连接时Ping验证，greenplum_exporter_db_connect_seconds随之更新
+	if ok {
+		err := pingDatabase(ctx, dbname, conn)
+		if err == nil || ctx.Err() != nil {
+			return conn, err
+		}
+
+		// 缓存的连接已失效(如数据库重启)时关闭并重新建立
+		logger.Warnf("cached connection to database %s is broken, reconnect, error:%v", dbname, err)
+		c.evict(dbname, conn)
+	}
+
+	// 建立连接时不持有锁，多个数据库可以同时建立连接
+	conn, err := openDatabase(ctx, dbname)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetMaxOpenConns(1)
+	conn.SetMaxIdleConns(1)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// 其它抓取器已同时建立了该数据库的连接时使用已缓存的连接
+	if cached, ok := c.conns[dbname]; ok {
+		_ = conn.Close()
+		return cached, nil
+	}
+
+	c.conns[dbname] = conn
+
+	return conn, nil
+}
+
+/**
+* 函数：evict
+* 功能：关闭失效的连接池，仍在缓存中时将其移除
+ */
+func (c *ConnectionCache) evict(dbname string, conn *sql.DB) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conns[dbname] == conn {
+		delete(c.conns, dbname)
+	}
+
+	_ = conn.Close()
+}
+
+/**
+* 函数：retain
+* 功能：关闭并移除不在names中的数据库的连接池
+ */
+func (c *ConnectionCache) retain(names []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	exists := make(map[string]bool, len(names))
+	for _, name := range names {
+		exists[name] = true
+	}
+
+	for dbname, conn := range c.conns {
+		if exists[dbname] {
+			continue
+		}
+
+		logger.Infof("database %s no longer exists, close its connection", dbname)
+		_ = conn.Close()
+		delete(c.conns, dbname)
+		dbConnectSeconds.DeleteLabelValues(dbname)
+	}
+}
+
+/**
+* 函数：Close
+* 功能：关闭并移除所有数据库的连接池，exporter退出时调用，正在执行的查询结束后其连接随之关闭
+ */
+func (c *ConnectionCache) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for dbname, conn := range c.conns {
+		_ = conn.Close()
+		delete(c.conns, dbname)
+	}
+}
@@ -1,10 +1,11 @@
 package collector
 
 import (
-	"database/sql"
+	"context"
 	"errors"
 	"github.com/prometheus/client_golang/prometheus"
-	logger "github.com/prometheus/common/log"
+	logger "greenplum-exporter/logging"
+	"time"
 )
 
 /**
@@ -12,6 +13,9 @@ import (
  */
 
 const (
+	// 运行时长达到statement_timeout的该百分比(默认80)时计入即将超时的查询
+	statementTimeoutNearPercentEnv = "GPDB_STATEMENT_TIMEOUT_NEAR_PERCENT"
+
 	connectionsSql_V6 = `select 
                          count(*) total, 
                          count(*) filter(where query='<IDLE>') idle, 
@@ -26,58 +30,162 @@ const (
                          count(*) filter(where current_query<>'<IDLE>' and not waiting) running,
                          count(*) filter(where current_query<>'<IDLE>' and waiting) waiting
                          from pg_stat_activity where procpid <> pg_backend_pid();`
+	// 正在执行COPY语句，或在外部表(gpfdist等)上持有锁(即正在通过外部表导入导出)的会话，外部表只能识别当前数据库中的
+	copyOperationsSql_V6 = `select count(*) from pg_stat_activity a
+                            where a.pid <> pg_backend_pid() and a.state <> 'idle'
+                            and (upper(ltrim(a.query)) like 'COPY%' or exists (
+                                select 1 from pg_locks l join pg_exttable e on e.reloid = l.relation
+                                where l.locktype = 'relation' and l.mppsessionid = a.sess_id
+                                and l.database = (select oid from pg_database where datname = current_database())));`
+	copyOperationsSql_V5 = `select count(*) from pg_stat_activity a
+                            where a.procpid <> pg_backend_pid() and a.current_query <> '<IDLE>'
+                            and (upper(ltrim(a.current_query)) like 'COPY%' or exists (
+                                select 1 from pg_locks l join pg_exttable e on e.reloid = l.relation
+                                where l.locktype = 'relation' and l.mppsessionid = a.sess_id
+                                and l.database = (select oid from pg_database where datname = current_database())));`
+	waitingBackendsSql_V7 = `select wait_event_type, count(*) from pg_stat_activity
+                             where pid <> pg_backend_pid() and state <> 'idle' and wait_event_type is not null group by 1;`
+	// Greenplum 6 没有wait_event_type字段，使用waiting_reason(lock、replication、resgroup)代替
+	waitingBackendsSql_V6 = `select coalesce(waiting_reason, 'unknown'), count(*) from pg_stat_activity
+                             where pid <> pg_backend_pid() and waiting group by 1;`
+	// 通过外部表(pg_exttable)上持有的relation锁识别正在扫描外部表(gpfdist等)的会话，仅能识别当前数据库中的外部表
+	externalScansSql = `select count(distinct l.pid) from pg_locks l
+                        join pg_exttable e on e.reloid = l.relation
+                        where l.locktype = 'relation' and l.pid <> pg_backend_pid()
+                        and l.database = (select oid from pg_database where datname = current_database());`
+	// 排除exporter的所有连接(按application_name识别，按库连接在两次抓取之间处于空闲状态)和standby的复制连接，
+	// Greenplum 5 没有state_change字段
+	avgIdleSecondsSql_V6 = `select coalesce(avg(extract(epoch from now() - state_change)), 0) from pg_stat_activity
+                            where pid <> pg_backend_pid() and state = 'idle' and coalesce(application_name, '') not in ('gp_walreceiver', $1);`
+	avgIdleSecondsSql_V7 = `select coalesce(avg(extract(epoch from now() - state_change)), 0) from pg_stat_activity
+                            where pid <> pg_backend_pid() and state = 'idle' and backend_type = 'client backend'
+                            and coalesce(application_name, '') <> $1;`
+	// exporter会话的statement_timeout(毫秒)，未对角色或数据库单独设置时即为集群的有效值
+	statementTimeoutSql = `select setting::float8 from pg_settings where name = 'statement_timeout';`
+	queriesNearStatementTimeoutSql_V6 = `select count(*) from pg_stat_activity
+                                         where pid <> pg_backend_pid() and state = 'active'
+                                         and now() - query_start > $1::float8 * interval '1 millisecond';`
+	queriesNearStatementTimeoutSql_V5 = `select count(*) from pg_stat_activity
+                                         where procpid <> pg_backend_pid() and current_query <> '<IDLE>'
+                                         and now() - query_start > $1::float8 * interval '1 millisecond';`
 )
 
 var (
-	currentConnDesc = prometheus.NewDesc(
+	currentConnDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemCluster, "total_connections"),
 		"Current connections of GreenPlum cluster at scrape time",
 		nil, nil,
 	)
 
-	idleConnDesc = prometheus.NewDesc(
+	idleConnDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemCluster, "idle_connections"),
 		"Idle connections of GreenPlum cluster at scape time",
 		nil, nil,
 	)
 
-	activeConnDesc = prometheus.NewDesc(
+	activeConnDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemCluster, "active_connections"),
 		"Active connections of GreenPlum cluster at scape time",
 		nil, nil,
 	)
 
-	runningConnDesc = prometheus.NewDesc(
+	runningConnDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemCluster, "running_connections"),
 		"Running sql count of GreenPlum cluster at scape time",
 		nil, nil,
 	)
 
-	queuingConnDesc = prometheus.NewDesc(
+	queuingConnDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemCluster, "waiting_connections"),
 		"Waiting sql count of GreenPlum cluster at scape time",
 		nil, nil,
 	)
+
+	copyOperationsDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "active_copy_operations"),
+		"Number of backends currently running a COPY statement or loading/unloading through an external table at scrape time",
+		nil, nil,
+	)
+
+	waitingBackendsDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "backends_waiting"),
+		"Number of backends currently waiting grouped by wait event type",
+		[]string{"wait_event_type"}, nil,
+	)
+
+	externalScansDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "active_external_scans"),
+		"Number of sessions currently holding locks on external tables at scrape time",
+		nil, nil,
+	)
+
+	avgIdleSecondsDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "avg_connection_idle_seconds"),
+		"Average seconds idle client connections other than the exporter's have been idle, 0 if there is no idle connection",
+		nil, nil,
+	)
+
+	queriesNearStatementTimeoutDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "queries_near_statement_timeout"),
+		"Number of running queries whose runtime exceeds GPDB_STATEMENT_TIMEOUT_NEAR_PERCENT of statement_timeout, 0 if statement_timeout is disabled",
+		nil, nil,
+	)
+
+	connectionsRecentPeakDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "connections_recent_peak"),
+		"Maximum total connections observed by the exporter within the last GPDB_CONNECTIONS_PEAK_WINDOW_MINUTES",
+		nil, nil,
+	)
 )
 
 func NewConnectionsScraper() Scraper {
-	return &connectionsScraper{}
+	window := envInt(connectionsPeakWindowEnv, 60)
+	if window <= 0 {
+		logger.Warnf("invalid value %d for %s, use %d", window, connectionsPeakWindowEnv, 60)
+		window = 60
+	}
+
+	return &connectionsScraper{
+		nearTimeoutPercent: envFloat(statementTimeoutNearPercentEnv, 80),
+		peak:               newConnPeak(time.Duration(window) * time.Minute),
+	}
 }
 
-type connectionsScraper struct{}
+type connectionsScraper struct {
+	nearTimeoutPercent float64
+	peak               *connPeak
+}
 
 func (connectionsScraper) Name() string {
 	return "connections_scraper"
 }
 
-func (connectionsScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric, ver int) error {
+func (s connectionsScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	errC := scrapeConnections(ctx, db, ch, ver, s.peak)
+	errP := scrapeCopyOperations(ctx, db, ch, ver)
+	errE := scrapeExternalScans(ctx, db, ch)
+	errW := scrapeWaitingBackends(ctx, db, ch, ver)
+	errT := scrapeQueriesNearStatementTimeout(ctx, db, ch, ver, s.nearTimeoutPercent)
+	errI := scrapeAvgIdleSeconds(ctx, db, ch, ver)
+
+	return combineErr(
+		wrapErr("connections", errC),
+		wrapErr("copy_operations", errP),
+		wrapErr("external_scans", errE),
+		wrapErr("waiting_backends", errW),
+		wrapErr("queries_near_statement_timeout", errT),
+		wrapErr("avg_connection_idle_seconds", errI),
+	)
+}
+
+func scrapeConnections(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int, peak *connPeak) error {
 	querySql:=connectionsSql_V6
 	if ver < 6{
 		querySql=connectionsSql_V5;
 	}
 
-	rows, err := db.Query(querySql)
-	logger.Infof("Query Database: %s",querySql)
+	rows, err := db.QueryContext(ctx, querySql)
+	logger.Debugf("Query Database: %s",querySql)
 
 	if err != nil {
 		return err
@@ -99,9 +207,156 @@ func (connectionsScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric, ver in
 		ch <- prometheus.MustNewConstMetric(activeConnDesc, prometheus.GaugeValue, active)
 		ch <- prometheus.MustNewConstMetric(runningConnDesc, prometheus.GaugeValue, running)
 		ch <- prometheus.MustNewConstMetric(queuingConnDesc, prometheus.GaugeValue, waiting)
+		ch <- prometheus.MustNewConstMetric(connectionsRecentPeakDesc, prometheus.GaugeValue, peak.observe(total, time.Now()))
 
 		return nil
 	}
 
 	return errors.New("connections not found")
 }
+
+func scrapeCopyOperations(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	querySql := copyOperationsSql_V6
+	if ver < 6 {
+		querySql = copyOperationsSql_V5
+	}
+
+	rows, err := db.QueryContext(ctx, querySql)
+	logger.Debugf("Query Database: %s", querySql)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var count float64
+
+		err = rows.Scan(&count)
+
+		if err != nil {
+			return err
+		}
+
+		ch <- prometheus.MustNewConstMetric(copyOperationsDesc, prometheus.GaugeValue, count)
+
+		return nil
+	}
+
+	return errors.New("copy operations not found")
+}
+
+func scrapeExternalScans(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	rows, err := db.QueryContext(ctx, externalScansSql)
+	logger.Debugf("Query Database: %s", externalScansSql)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var count float64
+
+		err = rows.Scan(&count)
+
+		if err != nil {
+			return err
+		}
+
+		ch <- prometheus.MustNewConstMetric(externalScansDesc, prometheus.GaugeValue, count)
+
+		return nil
+	}
+
+	return errors.New("external scans not found")
+}
+
+func scrapeWaitingBackends(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	// Greenplum 5 不支持
+	if ver < 6 {
+		return nil
+	}
+
+	querySql := waitingBackendsSql_V7
+	if ver < 7 {
+		querySql = waitingBackendsSql_V6
+	}
+
+	rows, err := db.QueryContext(ctx, querySql)
+	logger.Debugf("Query Database: %s", querySql)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var waitEventType string
+		var count float64
+
+		err = rows.Scan(&waitEventType, &count)
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(waitingBackendsDesc, prometheus.GaugeValue, count, waitEventType)
+	}
+
+	return combineErr(errs...)
+}
+
+func scrapeQueriesNearStatementTimeout(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int, percent float64) error {
+	timeout, err := scrapeScalar(ctx, db, statementTimeoutSql)
+	if err != nil {
+		return skipScalarNull(err)
+	}
+
+	// statement_timeout为0表示不限制
+	if timeout <= 0 {
+		ch <- prometheus.MustNewConstMetric(queriesNearStatementTimeoutDesc, prometheus.GaugeValue, 0)
+		return nil
+	}
+
+	querySql := queriesNearStatementTimeoutSql_V6
+	if ver < 6 {
+		querySql = queriesNearStatementTimeoutSql_V5
+	}
+
+	count, err := scrapeScalar(ctx, db, querySql, timeout*percent/100)
+	if err != nil {
+		return skipScalarNull(err)
+	}
+
+	ch <- prometheus.MustNewConstMetric(queriesNearStatementTimeoutDesc, prometheus.GaugeValue, count)
+
+	return nil
+}
+
+func scrapeAvgIdleSeconds(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	// Greenplum 5 不支持
+	if ver < 6 {
+		return nil
+	}
+
+	querySql := avgIdleSecondsSql_V7
+	if ver < 7 {
+		querySql = avgIdleSecondsSql_V6
+	}
+
+	seconds, err := scrapeScalar(ctx, db, querySql, applicationName())
+	if err != nil {
+		return skipScalarNull(err)
+	}
+
+	ch <- prometheus.MustNewConstMetric(avgIdleSecondsDesc, prometheus.GaugeValue, seconds)
+
+	return nil
+}
@@ -1,9 +1,10 @@
 package collector
 
 import (
+	"context"
 	"database/sql"
 	"github.com/prometheus/client_golang/prometheus"
-	logger "github.com/prometheus/common/log"
+	logger "greenplum-exporter/logging"
 )
 
 /**
@@ -11,6 +12,22 @@ import (
  */
 
 const (
+	// 设置为true时输出按客户端地址分组的连接数greenplum_server_connections_by_client，注意指标基数
+	connectionsByClientEnv = "GPDB_CONNECTIONS_BY_CLIENT"
+	// 设置为false时不输出按应用名称分组的连接数greenplum_server_connections_by_application
+	connectionsByApplicationEnv = "GPDB_CONNECTIONS_BY_APPLICATION"
+	// 设置为false时不输出按用户分组的连接数greenplum_server_connections_by_user
+	connectionsByUserEnv = "GPDB_CONNECTIONS_BY_USER"
+	// 设置为true时按用户分组的连接数中不包含exporter自身的连接
+	connectionsByUserExcludeSelfEnv = "GPDB_CONNECTIONS_BY_USER_EXCLUDE_SELF"
+
+	ownBackendsSql              = `select count(*) from pg_stat_activity where application_name = $1;`
+	connectionsByApplicationSql = `select coalesce(nullif(application_name, ''), 'unknown'), count(*) from pg_stat_activity group by 1;`
+	// 后台进程的usename为null，归为unknown；$1为空时不排除任何连接
+	connectionsByUsenameSql = `select coalesce(usename, 'unknown'), count(*) from pg_stat_activity
+		where $1 = '' or coalesce(application_name, '') <> $1 group by 1;`
+
+	distinctClientAddressesSql = `select count(distinct client_addr) from pg_stat_activity;`
 	connectionsByUserSql_V6 = `select usename, 
                                       count(*) total, 
                                       count(*) filter(where query='<IDLE>') idle, 
@@ -31,84 +48,152 @@ const (
                                                count(*) filter(where current_query='<IDLE>') idle,
                                                count(*) filter(where current_query<>'<IDLE>') active
                                 from pg_stat_activity where procpid <> pg_backend_pid() group by 1;`
+	connectionsByDatabaseSql = `select d.datname, d.datconnlimit, count(a.datid) total
+                                from pg_database d left join pg_stat_activity a on a.datid = d.oid
+                                where d.datallowconn group by d.datname, d.datconnlimit;`
 )
 
 var (
-	totalPerUserDesc = prometheus.NewDesc(
+	totalPerUserDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemCluster, "total_connections_per_user"),
 		"Total connections of specified database user",
 		[]string{"usename"}, nil,
 	)
 
-	activePerUserDesc = prometheus.NewDesc(
+	activePerUserDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemCluster, "active_connections_per_user"),
 		"Active connections of specified database user",
 		[]string{"usename"}, nil,
 	)
 
-	idlePerUserDesc = prometheus.NewDesc(
+	idlePerUserDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemCluster, "idle_connections_per_user"),
 		"Idle connections of specified database user",
 		[]string{"usename"}, nil,
 	)
 
-	totalPerClientDesc = prometheus.NewDesc(
+	totalPerClientDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemCluster, "total_connections_per_client"),
 		"Total connections of specified database user",
 		[]string{"client"}, nil,
 	)
 
-	activePerClientDesc = prometheus.NewDesc(
+	activePerClientDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemCluster, "active_connections_per_client"),
 		"Active connections of specified database user",
 		[]string{"client"}, nil,
 	)
 
-	idlePerClientDesc = prometheus.NewDesc(
+	idlePerClientDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemCluster, "idle_connections_per_client"),
 		"Idle connections of specified database user",
 		[]string{"client"}, nil,
 	)
 
-	totalCountClientDesc = prometheus.NewDesc(
+	totalCountClientDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemCluster, "total_client_count"),
 		"The total client count of greenplum database",
 		nil, nil,
 	)
 
-	totalCountOnlineUsersDesc = prometheus.NewDesc(
+	totalCountOnlineUsersDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemCluster, "total_online_user_count"),
 		"The total online user count of greenplum database",
 		nil, nil,
 	)
+
+	distinctClientAddressesDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "distinct_client_addresses"),
+		"Number of distinct client addresses currently connected",
+		nil, nil,
+	)
+
+	connectionsByClientDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "connections_by_client"),
+		"Current connections of specified client address",
+		[]string{"client_addr"}, nil,
+	)
+
+	connectionsByApplicationDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "connections_by_application"),
+		"Current connections of specified application name, unknown if the name is not set",
+		[]string{"application_name"}, nil,
+	)
+
+	connectionsByUsenameDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "connections_by_user"),
+		"Current connections of specified database role, unknown for background processes",
+		[]string{"usename"}, nil,
+	)
+
+	ownBackendsDesc = newDesc(
+		prometheus.BuildFQName(namespace, subsystemExporter, "own_backends"),
+		"Number of backends opened by the exporter, matched by the application_name of its connections",
+		nil, nil,
+	)
+
+	connectionsPerDatabaseDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_connections"),
+		"Current backend count of specified database",
+		[]string{"dbname"}, nil,
+	)
+
+	connLimitPerDatabaseDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_connection_limit"),
+		"Connection limit of specified database, -1 means unlimited",
+		[]string{"dbname"}, nil,
+	)
 )
 
 func NewConnDetailScraper() Scraper {
-	return connectionsDetailScraper{}
+	return connectionsDetailScraper{
+		byClient:      envBool(connectionsByClientEnv, false),
+		byApplication: envBool(connectionsByApplicationEnv, true),
+		byUser:        envBool(connectionsByUserEnv, true),
+		excludeSelf:   envBool(connectionsByUserExcludeSelfEnv, false),
+	}
 }
 
-type connectionsDetailScraper struct{}
+type connectionsDetailScraper struct {
+	byClient      bool
+	byApplication bool
+	byUser        bool
+	excludeSelf   bool
+}
 
 func (connectionsDetailScraper) Name() string {
 	return "connections_detail_scraper"
 }
 
-func (connectionsDetailScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric, ver int) error {
-	errU := scrapeLoadByUser(db, ch, ver)
-	errC := scrapeLoadByClient(db, ch, ver)
-
-	return combineErr(errC, errU)
+func (s connectionsDetailScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	errU := scrapeLoadByUser(ctx, db, ch, ver)
+	errC := scrapeLoadByClient(ctx, db, ch, ver, s.byClient)
+	errD := scrapeLoadByDatabase(ctx, db, ch)
+	errA := scrapeDistinctClientAddresses(ctx, db, ch)
+	errP := scrapeLoadByApplication(ctx, db, ch, s.byApplication)
+	errO := scrapeOwnBackends(ctx, db, ch)
+	errN := scrapeConnectionsByUser(ctx, db, ch, s.byUser, s.excludeSelf)
+
+	return combineErr(
+		wrapErr("load_by_client", errC),
+		wrapErr("load_by_user", errU),
+		wrapErr("load_by_database", errD),
+		wrapErr("distinct_client_addresses", errA),
+		wrapErr("load_by_application", errP),
+		wrapErr("own_backends", errO),
+		wrapErr("connections_by_user", errN),
+	)
 }
 
-func scrapeLoadByUser(db *sql.DB, ch chan<- prometheus.Metric, ver int) error {
+func scrapeLoadByUser(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
 	querySql:=connectionsByUserSql_V6
 	if ver < 6{
 		querySql=connectionsByUserSql_V5;
 	}
 
-	rows, err := db.Query(querySql)
+	rows, err := db.QueryContext(ctx, querySql)
 
-	logger.Infof("Query Database: %s", querySql)
+	logger.Debugf("Query Database: %s", querySql)
 
 	if err != nil {
 		return err
@@ -142,13 +227,13 @@ func scrapeLoadByUser(db *sql.DB, ch chan<- prometheus.Metric, ver int) error {
 	return combineErr(errs...)
 }
 
-func scrapeLoadByClient(db *sql.DB, ch chan<- prometheus.Metric, ver int) error {
+func scrapeLoadByClient(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int, byClient bool) error {
 	querySql:=connectionsByClientAddressSql_V6
 	if ver < 6{
 		querySql=connectionsByClientAddressSql_V5;
 	}
 
-	rows, err := db.Query(querySql)
+	rows, err := db.QueryContext(ctx, querySql)
 
 	if err != nil {
 		return err
@@ -173,6 +258,10 @@ func scrapeLoadByClient(db *sql.DB, ch chan<- prometheus.Metric, ver int) error
 		ch <- prometheus.MustNewConstMetric(idlePerClientDesc, prometheus.GaugeValue, idle, client.String)
 		ch <- prometheus.MustNewConstMetric(activePerClientDesc, prometheus.GaugeValue, active, client.String)
 
+		if byClient && client.Valid {
+			ch <- prometheus.MustNewConstMetric(connectionsByClientDesc, prometheus.GaugeValue, total, client.String)
+		}
+
 		totalClientCount++
 	}
 
@@ -180,3 +269,120 @@ func scrapeLoadByClient(db *sql.DB, ch chan<- prometheus.Metric, ver int) error
 
 	return combineErr(errs...)
 }
+
+func scrapeLoadByDatabase(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	rows, err := db.QueryContext(ctx, connectionsByDatabaseSql)
+
+	logger.Debugf("Query Database: %s", connectionsByDatabaseSql)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var dbname string
+		var limit, total float64
+
+		err = rows.Scan(&dbname, &limit, &total)
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(connectionsPerDatabaseDesc, prometheus.GaugeValue, total, dbname)
+		ch <- prometheus.MustNewConstMetric(connLimitPerDatabaseDesc, prometheus.GaugeValue, limit, dbname)
+	}
+
+	return combineErr(errs...)
+}
+
+func scrapeOwnBackends(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	count, err := scrapeScalar(ctx, db, ownBackendsSql, applicationName())
+	if err != nil {
+		return skipScalarNull(err)
+	}
+
+	ch <- prometheus.MustNewConstMetric(ownBackendsDesc, prometheus.GaugeValue, count)
+
+	return nil
+}
+
+func scrapeDistinctClientAddresses(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	return skipScalarNull(scrapeScalarGauge(ctx, db, ch, distinctClientAddressesDesc, distinctClientAddressesSql))
+}
+
+func scrapeLoadByApplication(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, byApplication bool) error {
+	if !byApplication {
+		return nil
+	}
+
+	logger.Debugf("Query Database: %s", connectionsByApplicationSql)
+	rows, err := db.QueryContext(ctx, connectionsByApplicationSql)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var application string
+		var total float64
+
+		err = rows.Scan(&application, &total)
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(connectionsByApplicationDesc, prometheus.GaugeValue, total, application)
+	}
+
+	return combineErr(errs...)
+}
+
+func scrapeConnectionsByUser(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, byUser bool, excludeSelf bool) error {
+	if !byUser {
+		return nil
+	}
+
+	excluded := ""
+	if excludeSelf {
+		excluded = applicationName()
+	}
+
+	logger.Debugf("Query Database: %s", connectionsByUsenameSql)
+	rows, err := db.QueryContext(ctx, connectionsByUsenameSql, excluded)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var usename string
+		var total float64
+
+		err = rows.Scan(&usename, &total)
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(connectionsByUsenameDesc, prometheus.GaugeValue, total, usename)
+	}
+
+	return combineErr(errs...)
+}
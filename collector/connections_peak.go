@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"sync"
+	"time"
+)
+
+/**
+ *  连接数近期峰值：记录最近GPDB_CONNECTIONS_PEAK_WINDOW_MINUTES(默认60)分钟内每次抓取的总连接数，
+ *  取其中的最大值，超出时间窗口的样本被丢弃，峰值随之回落
+ */
+
+const connectionsPeakWindowEnv = "GPDB_CONNECTIONS_PEAK_WINDOW_MINUTES"
+
+type connSample struct {
+	total float64
+	time  time.Time
+}
+
+type connPeak struct {
+	mu sync.Mutex
+
+	window  time.Duration
+	samples []connSample
+}
+
+func newConnPeak(window time.Duration) *connPeak {
+	return &connPeak{window: window}
+}
+
+/**
+* 函数：observe
+* 功能：记录本次样本，丢弃窗口外的样本并返回窗口内的最大连接数
+ */
+func (p *connPeak) observe(total float64, now time.Time) float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// 样本按时间递增，从头部丢弃过期的样本
+	expired := 0
+	for expired < len(p.samples) && now.Sub(p.samples[expired].time) > p.window {
+		expired++
+	}
+	p.samples = append(p.samples[expired:], connSample{total: total, time: now})
+
+	peak := total
+	for _, sample := range p.samples {
+		if sample.total > peak {
+			peak = sample.total
+		}
+	}
+
+	return peak
+}
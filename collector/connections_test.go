@@ -0,0 +1,41 @@
+package collector
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestScrapeAvgIdleSeconds(t *testing.T) {
+	setEnv(t, applicationNameEnv, "")
+	setEnv(t, dataSourceEnv, "postgres://gpadmin@localhost:5432/postgres?application_name=gpexp")
+
+	expected := `
+# HELP greenplum_server_avg_connection_idle_seconds Average seconds idle client connections other than the exporter's have been idle, 0 if there is no idle connection
+# TYPE greenplum_server_avg_connection_idle_seconds gauge
+greenplum_server_avg_connection_idle_seconds 42
+`
+	scrape := scrapeFunc(func(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+		return scrapeAvgIdleSeconds(ctx, db, ch, ver)
+	})
+
+	// exporter自身的连接按连接串中的application_name排除
+	for ver, query := range map[int]string{6: avgIdleSecondsSql_V6, 7: avgIdleSecondsSql_V7} {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(query)).WithArgs("gpexp").
+			WillReturnRows(sqlmock.NewRows([]string{"avg"}).AddRow(42))
+
+		if err := collectAndCompare(t, scrape, db, ver, expected); err != nil {
+			t.Errorf("unexpected scrape error of version %d: %v", ver, err)
+		}
+	}
+
+	// Greenplum 5 不查询
+	db, _ := newMockDB(t)
+	if err := collectAndCompare(t, scrape, db, 5, ""); err != nil {
+		t.Errorf("unexpected scrape error of version 5: %v", err)
+	}
+}
@@ -1,13 +1,11 @@
 package collector
 
 import (
-	"container/list"
 	"context"
-	"os"
-	"strings"
 	"database/sql"
 	"github.com/prometheus/client_golang/prometheus"
-	logger "github.com/prometheus/common/log"
+	logger "greenplum-exporter/logging"
+	"strconv"
 	"time"
 )
 
@@ -16,8 +14,12 @@ import (
  */
 
 const (
-	databaseSizeSql = `SELECT sodddatname as database_name,sodddatsize/(1024*1024) as database_size_mb from gp_toolkit.gp_size_of_database;`
-	tableCountSql   = `SELECT count(*) as total from information_schema.tables where table_schema not in ('gp_toolkit','information_schema','pg_catalog');`
+	// 设置为false时不再输出以MB为单位的旧指标greenplum_node_database_name_mb_size
+	databaseSizeMBEnv = "GPDB_DATABASE_SIZE_MB_METRIC"
+	// 数据库大小告警阈值（MB），大于0时输出超过阈值的数据库个数
+	databaseSizeAlertMBEnv = "GPDB_DB_SIZE_ALERT_MB"
+
+	databaseSizeSql = `SELECT sodddatname as database_name,sodddatsize/(1024*1024) as database_size_mb,sodddatsize as database_size_bytes from gp_toolkit.gp_size_of_database;`
 	bloatTableSql   = `
 		SELECT current_database(),bdinspname,bdirelname,bdirelpages,bdiexppages,(
 		case 
@@ -27,7 +29,7 @@ const (
 		end) as bloat_state 
 		FROM gp_toolkit.gp_bloat_diag ORDER BY bloat_state desc
 	`
-	skewTableSql=`
+	skewTableSql_V6=`
 		SELECT current_database(),schema_name,table_name,max_div_avg,pg_size_pretty(total_size) table_size 
 		FROM (
 			SELECT schema_name,table_name,
@@ -50,169 +52,469 @@ const (
 		AND max_div_avg>1.5
 		ORDER BY total_size DESC;
 	`
+	// Greenplum 7 的pg_class中没有relstorage字段，存储类型由relam(访问方法)区分
+	skewTableSql_V7=`
+		SELECT current_database(),schema_name,table_name,max_div_avg,pg_size_pretty(total_size) table_size 
+		FROM (
+			SELECT schema_name,table_name,
+				MAX(size)/(AVG(size)+0.001) AS max_div_avg,
+				CAST(SUM(size) AS BIGINT) total_size
+			FROM
+				(
+			SELECT o.gp_segment_id,
+						n.nspname as schema_name,
+						o.relname as table_name,
+						pg_relation_size(o.oid) size
+				FROM gp_dist_random('pg_class') o
+					LEFT JOIN pg_namespace n on o.relnamespace=n.oid
+				WHERE o.relkind='r'
+				AND o.relam IN (SELECT oid FROM pg_am WHERE amname IN ('ao_row','heap'))
+			) t
+			GROUP BY schema_name,table_name
+			)tab 
+		WHERE total_size >= 1024*1024*1024
+		AND max_div_avg>1.5
+		ORDER BY total_size DESC;
+	`
 	hitCacheRateSql = `select sum(blks_hit)/(sum(blks_read)+sum(blks_hit))*100 from pg_stat_database;`
 	txCommitRateSql = `select sum(xact_commit)/(sum(xact_commit)+sum(xact_rollback))*100 from pg_stat_database;`
+	databaseBlocksSql = `select datname, blks_read, blks_hit from pg_stat_database where datname is not null;`
+	// conflicts字段从Greenplum 6(PostgreSQL 9.1)开始提供
+	databaseConflictsSql_V6 = `select datname, conflicts from pg_stat_database where datname is not null;`
+	catalogRelationCountSql = `select count(*) from pg_class c join pg_namespace n on n.oid = c.relnamespace where n.nspname in ('pg_catalog', 'pg_toast');`
+	// 没有任何扫描时返回null，不输出指标
+	seqScanRatioSql = `select sum(coalesce(seq_scan, 0))::float8 / nullif(sum(coalesce(seq_scan, 0) + coalesce(idx_scan, 0)), 0) from pg_stat_all_tables;`
+	functionCountSql = `select count(*) from pg_proc p join pg_namespace n on n.oid = p.pronamespace
+		where n.nspname not in ('pg_catalog', 'information_schema', 'gp_toolkit');`
+	viewCountSql = `select count(*) from pg_views where schemaname not in ('pg_catalog', 'information_schema', 'gp_toolkit');`
+	// 有数据页但没有行数估算的表视为从未分析过，不依赖gp_toolkit
+	unanalyzedTablesSql = `select count(*) from pg_class c join pg_namespace n on n.oid = c.relnamespace
+		where c.relkind = 'r' and c.reltuples <= 0 and c.relpages > 0
+		and n.nspname not in ('pg_catalog', 'information_schema', 'gp_toolkit', 'pg_toast', 'pg_aoseg', 'pg_bitmapindex');`
+	// 未分析过的表reltuples为0(Greenplum 7中为-1)，没有用户表或都未分析时返回null，不输出指标
+	maxTableRowsSql = `select max(c.reltuples) from pg_class c join pg_namespace n on n.oid = c.relnamespace
+		where c.relkind = 'r' and c.reltuples > 0
+		and n.nspname not in ('pg_catalog', 'information_schema', 'gp_toolkit', 'pg_toast', 'pg_aoseg', 'pg_bitmapindex');`
+	// pg_toast、pg_aoseg等模式中既有系统表的也有用户表的辅助表，通过oid排除initdb创建的对象
+	objectsByKindSql = `select c.relkind, count(*) from pg_class c join pg_namespace n on n.oid = c.relnamespace
+		where c.oid >= 16384 and n.nspname not in ('pg_catalog', 'information_schema', 'gp_toolkit')
+		GROUP BY c.relkind;`
 )
 
+// Greenplum 5、6使用relstorage区分存储类型
+var skewTableSqls = versionedSql{5: skewTableSql_V6, 7: skewTableSql_V7}
+
 var (
-	databaseSizeDesc = prometheus.NewDesc(
+	databaseSizeDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemNode, "database_name_mb_size"), //指标的名称
 		"Total MB size of each database name in the file system",                  //帮助信息，显示在指标的上面作为注释
 		[]string{"dbname"},                                                        //定义的label名称数组
 		nil,                                                                       //定义的Labels
 	)
 
-	tablesCountDesc = prometheus.NewDesc(
+	databaseSizeBytesDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "database_size_bytes"),
+		"Total bytes size of each database name in the file system",
+		[]string{"dbname"},
+		nil,
+	)
+
+	tablesCountDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemNode, "database_table_total_count"),
 		"Total table count of each database name in the file system",
 		[]string{"dbname"},
 		nil,
 	)
 
-	bloatTableDesc = prometheus.NewDesc(
+	databaseScrapeFailuresDesc = newDesc(
+		prometheus.BuildFQName(namespace, subsystemExporter, "database_scrape_failures"),
+		"Whether the per-database queries of each database failed in the last scrape",
+		[]string{"dbname"},
+		nil,
+	)
+
+	schemaTableCountDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "schema_table_count"),
+		"Total table count of each schema in each database",
+		[]string{"dbname", "schema"},
+		nil,
+	)
+
+	bloatTableDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemServer, "database_table_bloat_list"),
 		"Bloat table list of each database name in greenplum cluster",
 		[]string{"dbname","schema","table","relpages","exppages"},
 		nil,
 	)
 
-	skewTableDesc = prometheus.NewDesc(
+	// relpages和exppages作为标签时无法排序和计算，以数值形式另外输出
+	bloatRelPagesDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_table_bloat_relpages"),
+		"Actual number of pages of each bloated table reported by gp_toolkit.gp_bloat_diag",
+		[]string{"dbname", "schema", "table"},
+		nil,
+	)
+
+	bloatExpPagesDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_table_bloat_exppages"),
+		"Expected number of pages of each bloated table reported by gp_toolkit.gp_bloat_diag",
+		[]string{"dbname", "schema", "table"},
+		nil,
+	)
+
+	bloatWastedPagesDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_table_bloat_wasted_pages"),
+		"Number of pages above the expected number of each bloated table, relpages - exppages",
+		[]string{"dbname", "schema", "table"},
+		nil,
+	)
+
+	skewTableDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemServer, "database_table_skew_list"),
 		"Skew table list of each database name in greenplum cluster",
 		[]string{"dbname","schema","table","size"},
 		nil,
 	)
 
-	hitCacheRateDesc = prometheus.NewDesc(
+	hitCacheRateDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemServer, "database_hit_cache_percent_rate"),
 		"Cache hit percent rat for all of database in greenplum server system",
 		nil,
 		nil,
 	)
 
-	txCommitRateDesc = prometheus.NewDesc(
+	txCommitRateDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemServer, "database_transition_commit_percent_rate"),
 		"Transition commit percent rat for all of database in greenplum server system",
 		nil,
 		nil,
 	)
+
+	databaseBlksReadDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_blks_read_total"),
+		"Number of disk blocks read in each database",
+		[]string{"datname"},
+		nil,
+	)
+
+	databaseBlksHitDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_blks_hit_total"),
+		"Number of times disk blocks were found already in the buffer cache in each database",
+		[]string{"datname"},
+		nil,
+	)
+
+	databaseConflictsDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_conflicts_total"),
+		"Number of queries canceled due to conflicts with recovery in each database, only counted on a hot standby",
+		[]string{"datname"},
+		nil,
+	)
+
+	catalogRelationCountDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "catalog_relation_count"),
+		"Number of relations in the pg_catalog and pg_toast schemas of each database",
+		[]string{"dbname"},
+		nil,
+	)
+
+	databasesOverThresholdDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "databases_over_threshold"),
+		"Number of databases larger than the configured size threshold",
+		nil,
+		nil,
+	)
+
+	databaseSizeThresholdDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "database_size_threshold_bytes"),
+		"Configured database size threshold in bytes",
+		nil,
+		nil,
+	)
+
+	seqScanRatioDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_seq_scan_ratio"),
+		"Ratio of sequential scans to all sequential and index scans of the tables in each database",
+		[]string{"dbname"},
+		nil,
+	)
+
+	functionCountDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "function_count"),
+		"Number of user-defined functions in each database",
+		[]string{"dbname"},
+		nil,
+	)
+
+	viewCountDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "view_count"),
+		"Number of user-defined views in each database",
+		[]string{"dbname"},
+		nil,
+	)
+
+	objectsByKindDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_objects_by_kind"),
+		"Number of user relations in each database grouped by pg_class relkind",
+		[]string{"dbname", "relkind"},
+		nil,
+	)
+
+	unanalyzedTablesDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "unanalyzed_tables"),
+		"Number of user tables in each database that have pages but no row estimate, which are likely never analyzed",
+		[]string{"dbname"},
+		nil,
+	)
+
+	maxTableRowsDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_max_table_rows"),
+		"Estimated number of rows (pg_class.reltuples) of the largest user table in each database",
+		[]string{"dbname"},
+		nil,
+	)
 )
 
-func NewDatabaseSizeScraper() Scraper {
-	return databaseSizeScraper{}
+func NewDatabaseSizeScraper(conns *ConnectionCache) Scraper {
+	return databaseSizeScraper{
+		emitMB:  envBool(databaseSizeMBEnv, true),
+		alertMB: envFloat(databaseSizeAlertMBEnv, 0),
+		sizeSql: newOverridableSql("DATABASE_SIZE", databaseSizeSql, 3),
+		sampler: newTableSampler(),
+		conns:   conns,
+	}
 }
 
-type databaseSizeScraper struct{}
+type databaseSizeScraper struct {
+	emitMB  bool
+	alertMB float64
+	sizeSql overridableSql
+	sampler tableSampler
+	conns   *ConnectionCache
+}
 
 func (databaseSizeScraper) Name() string {
 	return "database_size_scraper"
 }
 
-func (databaseSizeScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric, ver int) error {
-	ctx := context.Background()
-	ctx, cancel := context.WithTimeout(ctx, time.Second*2)
-
-	defer cancel()
-
-	logger.Infof("Query Database: %s", databaseSizeSql)
-	rows, err := db.QueryContext(ctx, databaseSizeSql)
+func (s databaseSizeScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	logger.Debugf("Query Database: %s", s.sizeSql.query)
+	rows, err := db.QueryContext(ctx, s.sizeSql.query)
 	if err != nil {
 		return err
 	}
 
 	defer rows.Close()
 
+	if err = s.sizeSql.checkColumns(rows); err != nil {
+		return err
+	}
+
 	errs := make([]error, 0)
 
-	names := list.New()
+	names := make([]string, 0)
+	overThreshold := 0
 	for rows.Next() {
 		var dbname string
-		var mbSize float64
+		var mbSize, bytesSize float64
 
-		err := rows.Scan(&dbname, &mbSize)
+		err := rows.Scan(&dbname, &mbSize, &bytesSize)
 
 		if err != nil {
-			errs = append(errs, err)
+			errs = append(errs, rowErr("database_size", err, "dbname", dbname))
 			continue
 		}
 
-		ch <- prometheus.MustNewConstMetric(databaseSizeDesc, prometheus.GaugeValue, mbSize, dbname)
-		names.PushBack(dbname)
+		if s.emitMB {
+			ch <- prometheus.MustNewConstMetric(databaseSizeDesc, prometheus.GaugeValue, mbSize, dbname)
+		}
+		ch <- prometheus.MustNewConstMetric(databaseSizeBytesDesc, prometheus.GaugeValue, bytesSize, dbname)
+		names = append(names, dbname)
+
+		if s.alertMB > 0 && bytesSize > s.alertMB*1024*1024 {
+			overThreshold++
+		}
+	}
+
+	// 超时后输出已读取的数据库，错误由combineErr一并返回
+	if err = rows.Err(); err != nil {
+		errs = append(errs, err)
 	}
 
-	for item := names.Front(); nil != item; item = item.Next() {
-		dbname := item.Value.(string)
-		count, err := queryTablesCount(dbname,ch)
+	if s.alertMB > 0 {
+		ch <- prometheus.MustNewConstMetric(databasesOverThresholdDesc, prometheus.GaugeValue, float64(overThreshold))
+		ch <- prometheus.MustNewConstMetric(databaseSizeThresholdDesc, prometheus.GaugeValue, s.alertMB*1024*1024)
+	}
+
+	loopCtx, loopCancel := newDatabaseLoopContext(ctx)
+
+	defer loopCancel()
+
+	// 各数据库并发查询，见runDatabases
+	errL := runDatabases(loopCtx, names, ch, func(ctx context.Context, dbname string, ch chan<- prometheus.Metric) error {
+		err := queryDatabaseTables(ctx, s.conns, dbname, ch, ver, s.sampler)
 		if err != nil {
-			errs = append(errs, err)
-			continue
+			ch <- prometheus.MustNewConstMetric(databaseScrapeFailuresDesc, prometheus.GaugeValue, 1, dbname)
+			return err
 		}
 
-		ch <- prometheus.MustNewConstMetric(tablesCountDesc, prometheus.GaugeValue, count, dbname)
+		ch <- prometheus.MustNewConstMetric(databaseScrapeFailuresDesc, prometheus.GaugeValue, 0, dbname)
+
+		return nil
+	})
+	if errL != nil {
+		errs = append(errs, errL)
 	}
 
-	errM := queryHitCacheRate(db, ch)
+	errM := queryHitCacheRate(ctx, db, ch)
 	if errM != nil {
 		errs = append(errs, errM)
 	}
 
-	errN := queryTxCommitRate(db, ch)
+	errN := queryTxCommitRate(ctx, db, ch)
 	if errN != nil {
 		errs = append(errs, errN)
 	}
 
+	errB := queryDatabaseBlocks(ctx, db, ch)
+	if errB != nil {
+		errs = append(errs, errB)
+	}
+
+	errC := queryDatabaseConflicts(ctx, db, ch, ver)
+	if errC != nil {
+		errs = append(errs, errC)
+	}
+
 	return combineErr(errs...)
 }
 
-func queryTablesCount(dbname string,ch chan<- prometheus.Metric) (count float64, err error) {
-	dataSourceName := os.Getenv("GPDB_DATA_SOURCE_URL")
-	newDataSourceName := strings.Replace(dataSourceName, "/postgres", "/"+dbname, 1)
-	logger.Infof("Connection string is : %s", newDataSourceName)
-	conn, errA := sql.Open("postgres", newDataSourceName)
+// 按数据库名称建立新的连接，由ConnectionCache调用并缓存，可替换为其它实现（如sqlmock）以便脱离真实集群验证按库循环的逻辑
+var openDatabase = func(ctx context.Context, dbname string) (*sql.DB, error) {
+	newDataSourceName, err := databaseDataSourceName(dbname)
+	if err != nil {
+		return nil, err
+	}
 
-	if errA != nil {
-		err=errA
-		return
+	logger.Debugf("Connection string is : %s", newDataSourceName)
+
+	conn, err := sql.Open("postgres", newDataSourceName)
+	if err != nil {
+		return nil, err
 	}
 
-	defer conn.Close()
+	// sql.Open不会建立连接，通过Ping建立并验证连接
+	if err = pingDatabase(ctx, dbname, conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
 
-	rows, errB := conn.Query(tableCountSql)
-	logger.Infof("Query Database: %s", tableCountSql)
+	return conn, nil
+}
 
-	if errB != nil {
-		err=errB
-		return
+/**
+* 函数：pingDatabase
+* 功能：在2秒内Ping按库连接，新建的连接池在此时建立连接；耗时计入抓取器的连接耗时并记录到greenplum_exporter_db_connect_seconds
+ */
+func pingDatabase(ctx context.Context, dbname string, conn *sql.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*2)
+
+	defer cancel()
+
+	start := time.Now()
+	err := conn.PingContext(ctx)
+	scrapeConnectTimer.add(time.Since(start))
+
+	if err != nil {
+		return err
 	}
 
-	defer rows.Close()
+	dbConnectSeconds.WithLabelValues(dbname).Set(time.Since(start).Seconds())
 
-	for rows.Next() {
-		errC := rows.Scan(&count)
-		if errC != nil {
-			err=errC
-			return
-		}
+	return nil
+}
+
+/**
+* 函数：queryDatabaseTables
+* 功能：在指定数据库上执行按库查询：表数量、膨胀表、倾斜表及各类对象统计。各查询相互独立，单个查询失败时其它查询照常输出，
+*      视图或函数不存在(如未安装gp_toolkit)时跳过该查询
+ */
+func queryDatabaseTables(ctx context.Context, conns *ConnectionCache, dbname string, ch chan<- prometheus.Metric, ver int, sampler tableSampler) error {
+	conn, err := conns.get(ctx, dbname)
+	if err != nil {
+		return err
+	}
+
+	errT := queryTablesCount(ctx, conn, ch, dbname)
+	errS := querySchemaTableCount(ctx, conn, ch, dbname)
+	errD := queryBloatTables(ctx, conn, ch, sampler)
+	errF := querySkewTables(ctx, conn, ch, ver, sampler)
+	errG := queryCatalogRelationCount(ctx, conn, ch, dbname)
+	errH := querySeqScanRatio(ctx, conn, ch, dbname)
+	errM := queryMaxTableRows(ctx, conn, ch, dbname)
+	errU := queryUnanalyzedTables(ctx, conn, ch, dbname)
+	errO := queryObjectCounts(ctx, conn, ch, dbname)
+	errK := queryObjectsByKind(ctx, conn, ch, dbname)
+
+	return combineErr(
+		wrapErr("table_count", skipUndefinedObject("table count", errT)),
+		wrapErr("schema_table_count", skipUndefinedObject("schema table count", errS)),
+		wrapErr("bloat_tables", skipUndefinedObject("bloat tables", errD)),
+		wrapErr("skew_tables", skipUndefinedObject("skew tables", errF)),
+		wrapErr("catalog_relation_count", skipUndefinedObject("catalog relation count", errG)),
+		wrapErr("seq_scan_ratio", skipUndefinedObject("seq scan ratio", errH)),
+		wrapErr("max_table_rows", skipUndefinedObject("max table rows", errM)),
+		wrapErr("unanalyzed_tables", skipUndefinedObject("unanalyzed tables", errU)),
+		wrapErr("object_counts", skipUndefinedObject("object counts", errO)),
+		wrapErr("objects_by_kind", skipUndefinedObject("objects by kind", errK)),
+	)
+}
+
+func queryTablesCount(ctx context.Context, conn *sql.DB, ch chan<- prometheus.Metric, dbname string) error {
+	// 表数量的查询语句由--tables.included-schemas和--tables.excluded-schemas决定，见table_schemas.go
+	tableCountSql, schemas := tableSchemas.tableCount()
+
+	count, err := scrapeScalar(ctx, conn, tableCountSql, schemas)
+	if err != nil {
+		return skipScalarNull(err)
 	}
 
-	errD := queryBloatTables(conn, ch)
-	if errD != nil {
-		err=errD
-		return
+	ch <- prometheus.MustNewConstMetric(tablesCountDesc, prometheus.GaugeValue, count, dbname)
+
+	return nil
+}
+
+func querySchemaTableCount(ctx context.Context, conn *sql.DB, ch chan<- prometheus.Metric, dbname string) error {
+	schemaTableCountSql, schemas := tableSchemas.schemaTableCount()
+	rows, err := conn.QueryContext(ctx, schemaTableCountSql, schemas)
+	logger.Debugf("Query Database: %s", schemaTableCountSql)
+
+	if err != nil {
+		return err
 	}
 
-	errF := querySkewTables(conn, ch)
-	if errF != nil {
-		err=errF
-		return
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var schema string
+		var count float64
+
+		if err = rows.Scan(&schema, &count); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(schemaTableCountDesc, prometheus.GaugeValue, count, dbname, schema)
 	}
 
-	return
+	return combineErr(errs...)
 }
 
-func queryBloatTables(conn *sql.DB, ch chan<- prometheus.Metric) error {
-	rows, err := conn.Query(bloatTableSql)
-	logger.Infof("Query bloat tables sql: %s", bloatTableSql)
+func queryBloatTables(ctx context.Context, conn *sql.DB, ch chan<- prometheus.Metric, sampler tableSampler) error {
+	rows, err := conn.QueryContext(ctx, bloatTableSql)
+	logger.Debugf("Query bloat tables sql: %s", bloatTableSql)
 
 	if err != nil {
 		return err
@@ -227,19 +529,39 @@ func queryBloatTables(conn *sql.DB, ch chan<- prometheus.Metric) error {
 		var bloatstate float64
 		err = rows.Scan(&dbname,&schema,&table,&relpages,&exppages,&bloatstate)
 		if err != nil {
-			errs = append(errs, err)
+			errs = append(errs, rowErr("bloat_tables", err, "dbname", dbname, "schema", schema, "table", table))
+			continue
+		}
+
+		if !sampler.sampled(dbname, schema, table) {
 			continue
 		}
 
 		ch <- prometheus.MustNewConstMetric(bloatTableDesc, prometheus.GaugeValue, bloatstate, dbname, schema, table, relpages, exppages)
+
+		relPages, errR := strconv.ParseFloat(relpages, 64)
+		expPages, errE := strconv.ParseFloat(exppages, 64)
+		if errR != nil || errE != nil {
+			errs = append(errs, rowErr("bloat_tables", combineErr(errR, errE), "dbname", dbname, "schema", schema, "table", table))
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(bloatRelPagesDesc, prometheus.GaugeValue, relPages, dbname, schema, table)
+		ch <- prometheus.MustNewConstMetric(bloatExpPagesDesc, prometheus.GaugeValue, expPages, dbname, schema, table)
+		ch <- prometheus.MustNewConstMetric(bloatWastedPagesDesc, prometheus.GaugeValue, relPages-expPages, dbname, schema, table)
+	}
+
+	if err = rows.Err(); err != nil {
+		errs = append(errs, err)
 	}
 
 	return combineErr(errs...)
 }
 
-func querySkewTables(conn *sql.DB, ch chan<- prometheus.Metric) error {
-	rows, err := conn.Query(skewTableSql)
-	logger.Infof("Query skew tables sql: %s", skewTableSql)
+func querySkewTables(ctx context.Context, conn *sql.DB, ch chan<- prometheus.Metric, ver int, sampler tableSampler) error {
+	querySql := skewTableSqls.forVersion(ver)
+	rows, err := conn.QueryContext(ctx, querySql)
+	logger.Debugf("Query skew tables sql: %s", querySql)
 
 	if err != nil {
 		return err
@@ -254,19 +576,50 @@ func querySkewTables(conn *sql.DB, ch chan<- prometheus.Metric) error {
 		var slope float64
 		err = rows.Scan(&dbname,&schema,&table,&slope,&size)
 		if err != nil {
-			errs = append(errs, err)
+			errs = append(errs, rowErr("skew_tables", err, "dbname", dbname, "schema", schema, "table", table))
+			continue
+		}
+
+		if !sampler.sampled(dbname, schema, table) {
 			continue
 		}
 
 		ch <- prometheus.MustNewConstMetric(skewTableDesc, prometheus.GaugeValue, slope, dbname, schema, table, size)
 	}
 
+	if err = rows.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
 	return combineErr(errs...)
 }
 
-func queryHitCacheRate(db *sql.DB, ch chan<- prometheus.Metric) error {
-	rows, err := db.Query(hitCacheRateSql)
-	logger.Infof("Query Database: %s", hitCacheRateSql)
+func queryCatalogRelationCount(ctx context.Context, conn *sql.DB, ch chan<- prometheus.Metric, dbname string) error {
+	return skipScalarNull(scrapeScalarGauge(ctx, conn, ch, catalogRelationCountDesc, catalogRelationCountSql, dbname))
+}
+
+func querySeqScanRatio(ctx context.Context, conn *sql.DB, ch chan<- prometheus.Metric, dbname string) error {
+	return skipScalarNull(scrapeScalarGauge(ctx, conn, ch, seqScanRatioDesc, seqScanRatioSql, dbname))
+}
+
+func queryMaxTableRows(ctx context.Context, conn *sql.DB, ch chan<- prometheus.Metric, dbname string) error {
+	return skipScalarNull(scrapeScalarGauge(ctx, conn, ch, maxTableRowsDesc, maxTableRowsSql, dbname))
+}
+
+func queryUnanalyzedTables(ctx context.Context, conn *sql.DB, ch chan<- prometheus.Metric, dbname string) error {
+	return skipScalarNull(scrapeScalarGauge(ctx, conn, ch, unanalyzedTablesDesc, unanalyzedTablesSql, dbname))
+}
+
+func queryObjectCounts(ctx context.Context, conn *sql.DB, ch chan<- prometheus.Metric, dbname string) error {
+	errF := scrapeScalarGauge(ctx, conn, ch, functionCountDesc, functionCountSql, dbname)
+	errV := scrapeScalarGauge(ctx, conn, ch, viewCountDesc, viewCountSql, dbname)
+
+	return combineErr(skipScalarNull(errF), skipScalarNull(errV))
+}
+
+func queryObjectsByKind(ctx context.Context, conn *sql.DB, ch chan<- prometheus.Metric, dbname string) error {
+	rows, err := conn.QueryContext(ctx, objectsByKindSql)
+	logger.Debugf("Query Database: %s", objectsByKindSql)
 
 	if err != nil {
 		return err
@@ -274,36 +627,85 @@ func queryHitCacheRate(db *sql.DB, ch chan<- prometheus.Metric) error {
 
 	defer rows.Close()
 
+	errs := make([]error, 0)
+
 	for rows.Next() {
-		var rate float64
-		err = rows.Scan(&rate)
+		var relkind string
+		var count float64
 
-		ch <- prometheus.MustNewConstMetric(hitCacheRateDesc, prometheus.GaugeValue, rate)
+		if err = rows.Scan(&relkind, &count); err != nil {
+			errs = append(errs, err)
+			continue
+		}
 
-		break
+		ch <- prometheus.MustNewConstMetric(objectsByKindDesc, prometheus.GaugeValue, count, dbname, relkind)
 	}
 
-	return nil
+	return combineErr(errs...)
 }
 
-func queryTxCommitRate(db *sql.DB, ch chan<- prometheus.Metric) error {
-	rows, err := db.Query(txCommitRateSql)
-	logger.Infof("Query Database: %s", txCommitRateSql)
+func queryDatabaseBlocks(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	logger.Debugf("Query Database: %s", databaseBlocksSql)
+	rows, err := db.QueryContext(ctx, databaseBlocksSql)
+	if err != nil {
+		return err
+	}
 
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var datname string
+		var blksRead, blksHit float64
+
+		err = rows.Scan(&datname, &blksRead, &blksHit)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(databaseBlksReadDesc, prometheus.CounterValue, blksRead, datname)
+		ch <- prometheus.MustNewConstMetric(databaseBlksHitDesc, prometheus.CounterValue, blksHit, datname)
+	}
+
+	return combineErr(errs...)
+}
+
+func queryHitCacheRate(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	return skipScalarNull(scrapeScalarGauge(ctx, db, ch, hitCacheRateDesc, hitCacheRateSql))
+}
+
+func queryTxCommitRate(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	return skipScalarNull(scrapeScalarGauge(ctx, db, ch, txCommitRateDesc, txCommitRateSql))
+}
+
+func queryDatabaseConflicts(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	if ver < 6 {
+		return nil
+	}
+
+	logger.Debugf("Query Database: %s", databaseConflictsSql_V6)
+	rows, err := db.QueryContext(ctx, databaseConflictsSql_V6)
 	if err != nil {
 		return err
 	}
 
 	defer rows.Close()
 
+	errs := make([]error, 0)
+
 	for rows.Next() {
-		var rate float64
-		err = rows.Scan(&rate)
+		var datname string
+		var conflicts float64
 
-		ch <- prometheus.MustNewConstMetric(txCommitRateDesc, prometheus.GaugeValue, rate)
+		if err = rows.Scan(&datname, &conflicts); err != nil {
+			errs = append(errs, err)
+			continue
+		}
 
-		break
+		ch <- prometheus.MustNewConstMetric(databaseConflictsDesc, prometheus.CounterValue, conflicts, datname)
 	}
 
-	return nil
+	return combineErr(errs...)
 }
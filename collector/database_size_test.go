@@ -0,0 +1,337 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 按库连接上queryDatabaseTables依次执行的查询，除表数量外均返回空结果
+func expectDatabaseTables(mock sqlmock.Sqlmock, tables float64) {
+	mock.ExpectQuery(regexp.QuoteMeta(tableCountSql_Excluded)).
+		WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow(tables))
+
+	for _, query := range []string{
+		schemaTableCountSql_Excluded,
+		bloatTableSql,
+		skewTableSqls.forVersion(6),
+		catalogRelationCountSql,
+		seqScanRatioSql,
+		maxTableRowsSql,
+		unanalyzedTablesSql,
+		functionCountSql,
+		viewCountSql,
+		objectsByKindSql,
+	} {
+		mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{"value"}))
+	}
+}
+
+func TestDatabaseSizeScraper(t *testing.T) {
+	db, mock := newMockDB(t)
+	sales, salesMock := newMockDB(t)
+	mockOpenDatabase(t, map[string]*sql.DB{"sales": sales})
+
+	mock.ExpectQuery(regexp.QuoteMeta(databaseSizeSql)).
+		WillReturnRows(sqlmock.NewRows([]string{"database_name", "database_size_mb", "database_size_bytes"}).
+			AddRow("sales", 2, 2*1024*1024))
+	expectDatabaseTables(salesMock, 12)
+	mock.ExpectQuery(regexp.QuoteMeta(hitCacheRateSql)).
+		WillReturnRows(sqlmock.NewRows([]string{"rate"}).AddRow(99.5))
+	mock.ExpectQuery(regexp.QuoteMeta(txCommitRateSql)).
+		WillReturnRows(sqlmock.NewRows([]string{"rate"}).AddRow(100))
+	mock.ExpectQuery(regexp.QuoteMeta(databaseBlocksSql)).
+		WillReturnRows(sqlmock.NewRows([]string{"datname", "blks_read", "blks_hit"}).AddRow("sales", 10, 90))
+	mock.ExpectQuery(regexp.QuoteMeta(databaseConflictsSql_V6)).
+		WillReturnRows(sqlmock.NewRows([]string{"datname", "conflicts"}).AddRow("sales", 0))
+
+	conns := NewConnectionCache()
+	defer conns.Close()
+
+	expected := `
+# HELP greenplum_node_database_name_mb_size Total MB size of each database name in the file system
+# TYPE greenplum_node_database_name_mb_size gauge
+greenplum_node_database_name_mb_size{dbname="sales"} 2
+# HELP greenplum_node_database_size_bytes Total bytes size of each database name in the file system
+# TYPE greenplum_node_database_size_bytes gauge
+greenplum_node_database_size_bytes{dbname="sales"} 2.097152e+06
+# HELP greenplum_node_database_table_total_count Total table count of each database name in the file system
+# TYPE greenplum_node_database_table_total_count gauge
+greenplum_node_database_table_total_count{dbname="sales"} 12
+# HELP greenplum_exporter_database_scrape_failures Whether the per-database queries of each database failed in the last scrape
+# TYPE greenplum_exporter_database_scrape_failures gauge
+greenplum_exporter_database_scrape_failures{dbname="sales"} 0
+# HELP greenplum_server_database_hit_cache_percent_rate Cache hit percent rat for all of database in greenplum server system
+# TYPE greenplum_server_database_hit_cache_percent_rate gauge
+greenplum_server_database_hit_cache_percent_rate 99.5
+# HELP greenplum_server_database_transition_commit_percent_rate Transition commit percent rat for all of database in greenplum server system
+# TYPE greenplum_server_database_transition_commit_percent_rate gauge
+greenplum_server_database_transition_commit_percent_rate 100
+# HELP greenplum_server_database_blks_read_total Number of disk blocks read in each database
+# TYPE greenplum_server_database_blks_read_total counter
+greenplum_server_database_blks_read_total{datname="sales"} 10
+# HELP greenplum_server_database_blks_hit_total Number of times disk blocks were found already in the buffer cache in each database
+# TYPE greenplum_server_database_blks_hit_total counter
+greenplum_server_database_blks_hit_total{datname="sales"} 90
+# HELP greenplum_server_database_conflicts_total Number of queries canceled due to conflicts with recovery in each database, only counted on a hot standby
+# TYPE greenplum_server_database_conflicts_total counter
+greenplum_server_database_conflicts_total{datname="sales"} 0
+`
+
+	if err := collectAndCompare(t, NewDatabaseSizeScraper(conns), db, 6, expected); err != nil {
+		t.Errorf("unexpected scrape error: %v", err)
+	}
+}
+
+func TestQueryHitCacheAndTxCommitRate(t *testing.T) {
+	for _, q := range []struct {
+		name   string
+		query  string
+		metric string
+		help   string
+		scrape scrapeFunc
+	}{
+		{
+			name:   "hit_cache_rate",
+			query:  hitCacheRateSql,
+			metric: "greenplum_server_database_hit_cache_percent_rate",
+			help:   "Cache hit percent rat for all of database in greenplum server system",
+			scrape: func(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+				return queryHitCacheRate(ctx, db, ch)
+			},
+		},
+		{
+			name:   "tx_commit_rate",
+			query:  txCommitRateSql,
+			metric: "greenplum_server_database_transition_commit_percent_rate",
+			help:   "Transition commit percent rat for all of database in greenplum server system",
+			scrape: func(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+				return queryTxCommitRate(ctx, db, ch)
+			},
+		},
+	} {
+		t.Run(q.name+"/value", func(t *testing.T) {
+			db, mock := newMockDB(t)
+			mock.ExpectQuery(regexp.QuoteMeta(q.query)).WillReturnRows(sqlmock.NewRows([]string{"rate"}).AddRow(97.5))
+
+			expected := fmt.Sprintf("# HELP %s %s\n# TYPE %s gauge\n%s 97.5\n", q.metric, q.help, q.metric, q.metric)
+			if err := collectAndCompare(t, q.scrape, db, 6, expected); err != nil {
+				t.Errorf("unexpected scrape error: %v", err)
+			}
+		})
+
+		// 没有数据库或统计为空时sum的结果为NULL，不输出指标也不报错
+		t.Run(q.name+"/null", func(t *testing.T) {
+			db, mock := newMockDB(t)
+			mock.ExpectQuery(regexp.QuoteMeta(q.query)).WillReturnRows(sqlmock.NewRows([]string{"rate"}).AddRow(nil))
+
+			if err := collectAndCompare(t, q.scrape, db, 6, ""); err != nil {
+				t.Errorf("unexpected scrape error: %v", err)
+			}
+		})
+
+		t.Run(q.name+"/empty", func(t *testing.T) {
+			db, mock := newMockDB(t)
+			mock.ExpectQuery(regexp.QuoteMeta(q.query)).WillReturnRows(sqlmock.NewRows([]string{"rate"}))
+
+			if err := collectAndCompare(t, q.scrape, db, 6, ""); err != nil {
+				t.Errorf("unexpected scrape error: %v", err)
+			}
+		})
+
+		t.Run(q.name+"/query_error", func(t *testing.T) {
+			db, mock := newMockDB(t)
+			mock.ExpectQuery(regexp.QuoteMeta(q.query)).WillReturnError(errors.New("connection reset"))
+
+			if err := collectAndCompare(t, q.scrape, db, 6, ""); err == nil || !strings.Contains(err.Error(), "connection reset") {
+				t.Errorf("expected the query error, got %v", err)
+			}
+		})
+
+		t.Run(q.name+"/scan_error", func(t *testing.T) {
+			db, mock := newMockDB(t)
+			mock.ExpectQuery(regexp.QuoteMeta(q.query)).WillReturnRows(sqlmock.NewRows([]string{"rate"}).AddRow("not a number"))
+
+			if err := collectAndCompare(t, q.scrape, db, 6, ""); err == nil {
+				t.Error("expected a scan error")
+			}
+		})
+	}
+}
+
+func TestQueryBloatTables(t *testing.T) {
+	scrape := func(conn *sql.DB) scrapeFunc {
+		return func(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+			return queryBloatTables(ctx, conn, ch, tableSampler{fraction: 1})
+		}
+	}
+
+	// 列顺序：数据库、模式、表、relpages、exppages、膨胀程度
+	columns := []string{"current_database", "bdinspname", "bdirelname", "bdirelpages", "bdiexppages", "bloat_state"}
+
+	t.Run("rows", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(bloatTableSql)).WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("sales", "public", "orders", "1200", "100", 2).
+			AddRow("sales", "public", "items", "30", "10", 1))
+
+		expected := `
+# HELP greenplum_server_database_table_bloat_list Bloat table list of each database name in greenplum cluster
+# TYPE greenplum_server_database_table_bloat_list gauge
+greenplum_server_database_table_bloat_list{dbname="sales",exppages="100",relpages="1200",schema="public",table="orders"} 2
+greenplum_server_database_table_bloat_list{dbname="sales",exppages="10",relpages="30",schema="public",table="items"} 1
+# HELP greenplum_server_database_table_bloat_relpages Actual number of pages of each bloated table reported by gp_toolkit.gp_bloat_diag
+# TYPE greenplum_server_database_table_bloat_relpages gauge
+greenplum_server_database_table_bloat_relpages{dbname="sales",schema="public",table="orders"} 1200
+greenplum_server_database_table_bloat_relpages{dbname="sales",schema="public",table="items"} 30
+# HELP greenplum_server_database_table_bloat_exppages Expected number of pages of each bloated table reported by gp_toolkit.gp_bloat_diag
+# TYPE greenplum_server_database_table_bloat_exppages gauge
+greenplum_server_database_table_bloat_exppages{dbname="sales",schema="public",table="orders"} 100
+greenplum_server_database_table_bloat_exppages{dbname="sales",schema="public",table="items"} 10
+# HELP greenplum_server_database_table_bloat_wasted_pages Number of pages above the expected number of each bloated table, relpages - exppages
+# TYPE greenplum_server_database_table_bloat_wasted_pages gauge
+greenplum_server_database_table_bloat_wasted_pages{dbname="sales",schema="public",table="orders"} 1100
+greenplum_server_database_table_bloat_wasted_pages{dbname="sales",schema="public",table="items"} 20
+`
+		if err := collectAndCompare(t, scrape(db), nil, 6, expected); err != nil {
+			t.Errorf("unexpected scrape error: %v", err)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(bloatTableSql)).WillReturnRows(sqlmock.NewRows(columns))
+
+		if err := collectAndCompare(t, scrape(db), nil, 6, ""); err != nil {
+			t.Errorf("unexpected scrape error: %v", err)
+		}
+	})
+
+	// 出错的行不输出，其它行照常输出，错误中带有出错行的表名
+	t.Run("scan_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(bloatTableSql)).WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("sales", "public", "orders", "1200", "100", "significant").
+			AddRow("sales", "public", "items", "30", "10", 1))
+
+		expected := `
+# HELP greenplum_server_database_table_bloat_list Bloat table list of each database name in greenplum cluster
+# TYPE greenplum_server_database_table_bloat_list gauge
+greenplum_server_database_table_bloat_list{dbname="sales",exppages="10",relpages="30",schema="public",table="items"} 1
+`
+		err := collectAndCompare(t, scrape(db), nil, 6, expected, "greenplum_server_database_table_bloat_list")
+		if err == nil || !strings.Contains(err.Error(), `table="orders"`) {
+			t.Errorf("expected a scan error of table orders, got %v", err)
+		}
+	})
+
+	t.Run("query_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(bloatTableSql)).WillReturnError(errors.New("connection reset"))
+
+		if err := collectAndCompare(t, scrape(db), nil, 6, ""); err == nil {
+			t.Error("expected the query error")
+		}
+	})
+}
+
+// 单行的类型不匹配时跳过该行，错误中带有查询标识和该行的数据库名称
+func TestDatabaseSizeScraperScanTypeMismatch(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta(databaseSizeSql)).
+		WillReturnRows(sqlmock.NewRows([]string{"database_name", "database_size_mb", "database_size_bytes"}).
+			AddRow("sales", "unknown", 2*1024*1024))
+	mock.ExpectQuery(regexp.QuoteMeta(hitCacheRateSql)).WillReturnRows(sqlmock.NewRows([]string{"rate"}))
+	mock.ExpectQuery(regexp.QuoteMeta(txCommitRateSql)).WillReturnRows(sqlmock.NewRows([]string{"rate"}))
+	mock.ExpectQuery(regexp.QuoteMeta(databaseBlocksSql)).WillReturnRows(sqlmock.NewRows([]string{"datname", "blks_read", "blks_hit"}))
+
+	conns := NewConnectionCache()
+	defer conns.Close()
+
+	err := collectAndCompare(t, NewDatabaseSizeScraper(conns), db, 5, "", "greenplum_node_database_size_bytes")
+	if err == nil {
+		t.Fatal("expected a scan error")
+	}
+
+	for _, s := range []string{"database_size row", `dbname="sales"`, "float64"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("expected %q in the error, got %v", s, err)
+		}
+	}
+}
+
+// 覆盖的SQL列数不符时返回带有查询和环境变量名称的错误，不扫描任何行
+func TestDatabaseSizeScraperColumnCountMismatch(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta(databaseSizeSql)).
+		WillReturnRows(sqlmock.NewRows([]string{"database_name", "database_size_bytes"}).AddRow("sales", 2*1024*1024))
+
+	err := collectAndCompare(t, NewDatabaseSizeScraper(NewConnectionCache()), db, 6, "")
+	if err == nil {
+		t.Fatal("expected a column count error")
+	}
+
+	for _, s := range []string{databaseSizeSql, "returns 2 columns, expected 3", "GPDB_SQL_DATABASE_SIZE"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("expected %q in the error, got %v", s, err)
+		}
+	}
+}
+
+// 列数少于扫描的字段数时每一行都出错，错误中带有查询标识
+func TestQueryBloatTablesColumnCountMismatch(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta(bloatTableSql)).
+		WillReturnRows(sqlmock.NewRows([]string{"current_database", "bdinspname", "bdirelname", "bdirelpages", "bdiexppages"}).
+			AddRow("sales", "public", "orders", "1200", "100"))
+
+	scrape := scrapeFunc(func(ctx context.Context, _ Queryer, ch chan<- prometheus.Metric, ver int) error {
+		return queryBloatTables(ctx, db, ch, tableSampler{fraction: 1})
+	})
+
+	err := collectAndCompare(t, scrape, nil, 6, "")
+	if err == nil || !strings.Contains(err.Error(), "bloat_tables row") || !strings.Contains(err.Error(), "expected 5 destination arguments") {
+		t.Errorf("expected a column count error of bloat_tables, got %v", err)
+	}
+}
+
+// 单个数据库连接失败时标记该库的失败并返回错误，其它数据库和coordinator上的查询照常输出
+func TestDatabaseSizeScraperDatabaseFailure(t *testing.T) {
+	db, mock := newMockDB(t)
+	sales, salesMock := newMockDB(t)
+	mockOpenDatabase(t, map[string]*sql.DB{"sales": sales, "broken": nil})
+
+	mock.ExpectQuery(regexp.QuoteMeta(databaseSizeSql)).
+		WillReturnRows(sqlmock.NewRows([]string{"database_name", "database_size_mb", "database_size_bytes"}).
+			AddRow("sales", 2, 2*1024*1024).
+			AddRow("broken", 1, 1024*1024))
+	expectDatabaseTables(salesMock, 12)
+	mock.ExpectQuery(regexp.QuoteMeta(hitCacheRateSql)).WillReturnRows(sqlmock.NewRows([]string{"rate"}))
+	mock.ExpectQuery(regexp.QuoteMeta(txCommitRateSql)).WillReturnRows(sqlmock.NewRows([]string{"rate"}))
+	mock.ExpectQuery(regexp.QuoteMeta(databaseBlocksSql)).WillReturnRows(sqlmock.NewRows([]string{"datname", "blks_read", "blks_hit"}))
+
+	conns := NewConnectionCache()
+	defer conns.Close()
+
+	expected := `
+# HELP greenplum_node_database_table_total_count Total table count of each database name in the file system
+# TYPE greenplum_node_database_table_total_count gauge
+greenplum_node_database_table_total_count{dbname="sales"} 12
+# HELP greenplum_exporter_database_scrape_failures Whether the per-database queries of each database failed in the last scrape
+# TYPE greenplum_exporter_database_scrape_failures gauge
+greenplum_exporter_database_scrape_failures{dbname="broken"} 1
+greenplum_exporter_database_scrape_failures{dbname="sales"} 0
+`
+	err := collectAndCompare(t, NewDatabaseSizeScraper(conns), db, 5, expected,
+		"greenplum_node_database_table_total_count", "greenplum_exporter_database_scrape_failures")
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("expected the connection error of database broken, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package collector
+
+import (
+	"sync"
+	"time"
+)
+
+/**
+ *  磁盘可用空间趋势：记录每个主机上一次抓取的可用空间，按两次抓取之间的减少速度估算磁盘写满所需的时间
+ */
+
+type diskSample struct {
+	free float64
+	time time.Time
+}
+
+type diskTrend struct {
+	mu sync.Mutex
+
+	samples map[string]diskSample
+}
+
+func newDiskTrend() *diskTrend {
+	return &diskTrend{samples: make(map[string]diskSample)}
+}
+
+/**
+* 函数：hoursToFull
+* 功能：记录本次样本并返回预计写满的小时数，首次抓取或可用空间没有减少时返回false
+ */
+func (d *diskTrend) hoursToFull(hostname string, free float64, now time.Time) (float64, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	prev, ok := d.samples[hostname]
+	d.samples[hostname] = diskSample{free: free, time: now}
+
+	if !ok || free >= prev.free {
+		return 0, false
+	}
+
+	elapsed := now.Sub(prev.time).Hours()
+	if elapsed <= 0 {
+		return 0, false
+	}
+
+	return free / ((prev.free - free) / elapsed), true
+}
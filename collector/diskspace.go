@@ -1,9 +1,9 @@
 package collector
 
 import (
-	"database/sql"
+	"context"
 	"github.com/prometheus/client_golang/prometheus"
-	logger "github.com/prometheus/common/log"
+	logger "greenplum-exporter/logging"
 )
 
 /**
@@ -16,19 +16,19 @@ const (
 )
 
 var (
-	fsTotalDesc = prometheus.NewDesc(
+	fsTotalDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemNode, "fs_total_bytes"),
 		"Total bytes in the file system",
 		[]string{"hostname", "filesystem"}, nil,
 	)
 
-	fsUsedDesc = prometheus.NewDesc(
+	fsUsedDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemNode, "fs_used_bytes"),
 		"Total bytes used in the file system",
 		[]string{"hostname", "filesystem"}, nil,
 	)
 
-	fsAvailableDesc = prometheus.NewDesc(
+	fsAvailableDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemNode, "fs_available_bytes"),
 		"Total bytes available in the file system",
 		[]string{"hostname", "filesystem"}, nil,
@@ -45,9 +45,9 @@ func (diskScraper) Name() string {
 	return "filesystem_scraper"
 }
 
-func (diskScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric, ver int) error {
-	rows, err := db.Query(fileSystemSql)
-	logger.Infof("Query Database: %s",fileSystemSql)
+func (diskScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	rows, err := db.QueryContext(ctx, fileSystemSql)
+	logger.Debugf("Query Database: %s",fileSystemSql)
 
 	if err != nil {
 		return err
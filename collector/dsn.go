@@ -0,0 +1,279 @@
+package collector
+
+import (
+	"net/url"
+	"os"
+	"strings"
+	"unicode"
+)
+
+/**
+ *  数据库连接串的处理
+ */
+
+const (
+	dataSourceEnv      = "GPDB_DATA_SOURCE_URL"
+	defaultDatabaseEnv = "GPDB_DEFAULT_DATABASE"
+	applicationNameEnv = "GPDB_APPLICATION_NAME"
+
+	defaultApplicationName = "greenplum_exporter"
+)
+
+// 设置后覆盖连接串中的同名SSL参数，同时作用于监控入口连接和按库连接
+var sslParameterEnvs = []struct {
+	key string
+	env string
+}{
+	{key: "sslmode", env: "GPDB_SSLMODE"},
+	{key: "sslrootcert", env: "GPDB_SSLROOTCERT"},
+	{key: "sslcert", env: "GPDB_SSLCERT"},
+	{key: "sslkey", env: "GPDB_SSLKEY"},
+}
+
+/**
+* 函数：defaultDatabase
+* 功能：获取作为监控入口的数据库名称，优先使用GPDB_DEFAULT_DATABASE，其次为连接串中的数据库
+ */
+func defaultDatabase() string {
+	if dbname := os.Getenv(defaultDatabaseEnv); dbname != "" {
+		return dbname
+	}
+
+	dataSourceName := os.Getenv(dataSourceEnv)
+	if isURLDataSourceName(dataSourceName) {
+		if u, err := url.Parse(dataSourceName); err == nil {
+			if dbname := strings.TrimPrefix(u.Path, "/"); dbname != "" {
+				return dbname
+			}
+		}
+	} else if dbname := keywordValue(parseKeywordDataSourceName(dataSourceName), "dbname"); dbname != "" {
+		return dbname
+	}
+
+	return "postgres"
+}
+
+/**
+* 函数：applicationName
+* 功能：获取exporter连接使用的application_name，优先使用GPDB_APPLICATION_NAME，其次为连接串中的application_name
+ */
+func applicationName() string {
+	if name := os.Getenv(applicationNameEnv); name != "" {
+		return name
+	}
+
+	dataSourceName := os.Getenv(dataSourceEnv)
+	if isURLDataSourceName(dataSourceName) {
+		if u, err := url.Parse(dataSourceName); err == nil {
+			if name := u.Query().Get("application_name"); name != "" {
+				return name
+			}
+		}
+	} else if name := keywordValue(parseKeywordDataSourceName(dataSourceName), "application_name"); name != "" {
+		return name
+	}
+
+	return defaultApplicationName
+}
+
+/**
+* 函数：defaultDataSourceName
+* 功能：获取监控入口数据库的连接串
+ */
+func defaultDataSourceName() (string, error) {
+	dataSourceName := os.Getenv(dataSourceEnv)
+
+	if os.Getenv(defaultDatabaseEnv) != "" {
+		var err error
+		if dataSourceName, err = replaceDatabase(dataSourceName, defaultDatabase()); err != nil {
+			return "", err
+		}
+	}
+
+	return withConnectionParameters(dataSourceName)
+}
+
+/**
+* 函数：databaseDataSourceName
+* 功能：获取指定数据库的连接串
+ */
+func databaseDataSourceName(dbname string) (string, error) {
+	dataSourceName, err := replaceDatabase(os.Getenv(dataSourceEnv), dbname)
+	if err != nil {
+		return "", err
+	}
+
+	return withConnectionParameters(dataSourceName)
+}
+
+/**
+* 函数：withConnectionParameters
+* 功能：设置连接串的application_name及通过环境变量指定的SSL参数，连接串中的其它参数保持不变
+ */
+func withConnectionParameters(dataSourceName string) (string, error) {
+	dataSourceName, err := replaceParameter(dataSourceName, "application_name", applicationName())
+	if err != nil {
+		return "", err
+	}
+
+	for _, param := range sslParameterEnvs {
+		value := os.Getenv(param.env)
+		if value == "" {
+			continue
+		}
+
+		if dataSourceName, err = replaceParameter(dataSourceName, param.key, value); err != nil {
+			return "", err
+		}
+	}
+
+	return dataSourceName, nil
+}
+
+/**
+* 函数：replaceDatabase
+* 功能：替换连接串中的数据库名称，其余部分（账号、主机、Unix socket目录、参数）保持不变
+*      支持URL格式(postgres://...)和key=value格式(host=/var/run/postgresql dbname=postgres)
+ */
+func replaceDatabase(dataSourceName, dbname string) (string, error) {
+	if !isURLDataSourceName(dataSourceName) {
+		return replaceKeyword(dataSourceName, "dbname", dbname), nil
+	}
+
+	u, err := url.Parse(dataSourceName)
+	if err != nil {
+		return "", err
+	}
+
+	u.Path = "/" + dbname
+	u.RawPath = ""
+
+	return u.String(), nil
+}
+
+/**
+* 函数：replaceParameter
+* 功能：设置连接串中的连接参数(如application_name)，已存在时替换
+ */
+func replaceParameter(dataSourceName, key, value string) (string, error) {
+	if !isURLDataSourceName(dataSourceName) {
+		return replaceKeyword(dataSourceName, key, value), nil
+	}
+
+	u, err := url.Parse(dataSourceName)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	query.Set(key, value)
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+func isURLDataSourceName(dataSourceName string) bool {
+	return strings.HasPrefix(dataSourceName, "postgres://") || strings.HasPrefix(dataSourceName, "postgresql://")
+}
+
+// key=value格式连接串中的一项
+type keywordPair struct {
+	key   string
+	value string
+}
+
+func keywordValue(pairs []keywordPair, key string) string {
+	for _, pair := range pairs {
+		if pair.key == key {
+			return pair.value
+		}
+	}
+
+	return ""
+}
+
+func replaceKeyword(dataSourceName, key, value string) string {
+	pairs := parseKeywordDataSourceName(dataSourceName)
+
+	replaced := false
+	for i := range pairs {
+		if pairs[i].key == key {
+			pairs[i].value = value
+			replaced = true
+		}
+	}
+
+	if !replaced {
+		pairs = append(pairs, keywordPair{key: key, value: value})
+	}
+
+	items := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(pair.value)
+		items = append(items, pair.key+"='"+value+"'")
+	}
+
+	return strings.Join(items, " ")
+}
+
+/**
+* 函数：parseKeywordDataSourceName
+* 功能：解析key=value格式的连接串，值可以使用单引号包裹并以反斜杠转义
+ */
+func parseKeywordDataSourceName(dataSourceName string) []keywordPair {
+	pairs := make([]keywordPair, 0)
+	runes := []rune(dataSourceName)
+
+	for i := 0; i < len(runes); {
+		for i < len(runes) && unicode.IsSpace(runes[i]) {
+			i++
+		}
+
+		start := i
+		for i < len(runes) && runes[i] != '=' && !unicode.IsSpace(runes[i]) {
+			i++
+		}
+
+		key := string(runes[start:i])
+
+		for i < len(runes) && unicode.IsSpace(runes[i]) {
+			i++
+		}
+
+		if i >= len(runes) || runes[i] != '=' {
+			break
+		}
+		i++
+
+		for i < len(runes) && unicode.IsSpace(runes[i]) {
+			i++
+		}
+
+		var value []rune
+		if i < len(runes) && runes[i] == '\'' {
+			i++
+			for i < len(runes) && runes[i] != '\'' {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				value = append(value, runes[i])
+				i++
+			}
+			i++
+		} else {
+			for i < len(runes) && !unicode.IsSpace(runes[i]) {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				value = append(value, runes[i])
+				i++
+			}
+		}
+
+		if key != "" {
+			pairs = append(pairs, keywordPair{key: key, value: string(value)})
+		}
+	}
+
+	return pairs
+}
@@ -1,9 +1,9 @@
 package collector
 
 import (
-	"database/sql"
+	"context"
 	"github.com/prometheus/client_golang/prometheus"
-	logger "github.com/prometheus/common/log"
+	logger "greenplum-exporter/logging"
 )
 
 /**
@@ -16,13 +16,13 @@ const (
 )
 
 var (
-	dynamicMemUsedDesc = prometheus.NewDesc(
+	dynamicMemUsedDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemNode, "dynamic_memory_used_mb"),
 		"The amount of dynamic memory in MB allocated to query processes running on this segment host",
 		[]string{"hostname"}, nil,
 	)
 
-	dynamicMemAvailableDesc = prometheus.NewDesc(
+	dynamicMemAvailableDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemNode, "dynamic_memory_available_mb"),
 		"The amount of additional dynamic memory (in MB) available to the query processes running on this segment host",
 		[]string{"hostname"}, nil,
@@ -39,9 +39,9 @@ func (dynamicMemoryScraper) Name() string {
 	return "dynamic_mem_scraper"
 }
 
-func (dynamicMemoryScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric, ver int) error {
-	rows, err := db.Query(dynamicMemorySql)
-	logger.Infof("Query Database: %s",dynamicMemorySql)
+func (dynamicMemoryScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	rows, err := db.QueryContext(ctx, dynamicMemorySql)
+	logger.Debugf("Query Database: %s",dynamicMemorySql)
 
 	if err != nil {
 		return err
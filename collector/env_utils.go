@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	logger "greenplum-exporter/logging"
+)
+
+/**
+* 函数：envInt
+* 功能：读取整数类型的环境变量，未设置或格式错误时使用默认值
+ */
+func envInt(name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		logger.Warnf("invalid value %q for %s, use default %d", value, name, defaultValue)
+		return defaultValue
+	}
+
+	return i
+}
+
+/**
+* 函数：envFloat
+* 功能：读取浮点类型的环境变量，未设置或格式错误时使用默认值
+ */
+func envFloat(name string, defaultValue float64) float64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		logger.Warnf("invalid value %q for %s, use default %v", value, name, defaultValue)
+		return defaultValue
+	}
+
+	return f
+}
+
+/**
+* 函数：envBool
+* 功能：读取布尔类型的环境变量，未设置或格式错误时使用默认值
+ */
+func envBool(name string, defaultValue bool) bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		logger.Warnf("invalid value %q for %s, use default %v", value, name, defaultValue)
+		return defaultValue
+	}
+
+	return b
+}
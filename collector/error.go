@@ -1,27 +1,209 @@
 package collector
 
-import "errors"
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/lib/pq"
+	logger "greenplum-exporter/logging"
+)
+
+// 错误分类，用于greenplum_exporter_scrape_errors_total指标的category标签
+const (
+	errorCategoryTimeout         = "timeout"
+	errorCategoryConnection      = "connection"
+	errorCategoryPermission      = "permission"
+	errorCategoryMissingRelation = "missing_relation"
+	errorCategoryOther           = "other"
+)
+
+// 组合后的多个错误，保留每个原始错误以便分类，输出时每个错误占一行
+type multiError []error
+
+func (m multiError) Error() string {
+	msgs := make([]string, 0, len(m))
+	for _, err := range flattenErr(m) {
+		msgs = append(msgs, err.Error())
+	}
+
+	return strings.Join(msgs, "\n")
+}
 
 /**
 * 函数：combineErr
 * 功能：error的组合
  */
 func combineErr(errs ...error) error {
-	var errStr string
+	combined := make(multiError, 0, len(errs))
 	for _, err := range errs {
 		if err != nil {
-			if errStr == "" {
-				errStr += err.Error()
-			} else {
-				errStr += "; " + err.Error()
-			}
-
+			combined = append(combined, err)
 		}
 	}
 
-	if errStr == "" {
+	if len(combined) == 0 {
 		return nil
+	} else if len(combined) == 1 {
+		return combined[0]
 	} else {
-		return errors.New(errStr)
+		return combined
+	}
+}
+
+/**
+* 函数：wrapErr
+* 功能：为错误加上上下文(抓取器名称、数据库名称、SQL标识等)，组合错误中的每个错误分别加上下文
+ */
+func wrapErr(label string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var combined multiError
+	if errors.As(err, &combined) {
+		wrapped := make(multiError, 0, len(combined))
+		for _, e := range combined {
+			wrapped = append(wrapped, wrapErr(label, e))
+		}
+
+		return wrapped
+	}
+
+	return fmt.Errorf("%s: %w", label, err)
+}
+
+/**
+* 函数：rowErr
+* 功能：为单行的扫描或解析错误附加查询标识和该行的关键列，关键列按名称、值成对传入。
+*       扫描失败时只有出错字段之前的列已赋值，其余关键列为空值
+ */
+func rowErr(query string, err error, keyValues ...string) error {
+	if err == nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(keyValues)/2)
+	for i := 0; i+1 < len(keyValues); i += 2 {
+		keys = append(keys, fmt.Sprintf("%s=%q", keyValues[i], keyValues[i+1]))
+	}
+
+	return fmt.Errorf("%s row (%s): %w", query, strings.Join(keys, ", "), err)
+}
+
+/**
+* 函数：flattenErr
+* 功能：将组合错误展开为原始错误列表
+ */
+func flattenErr(err error) []error {
+	if err == nil {
+		return nil
+	}
+
+	var combined multiError
+	if !errors.As(err, &combined) {
+		return []error{err}
+	}
+
+	flat := make([]error, 0, len(combined))
+	for _, e := range combined {
+		flat = append(flat, flattenErr(e)...)
 	}
+
+	return flat
+}
+
+/**
+* 函数：isUndefinedObject
+* 功能：判断错误是否因视图、函数、字段或模式不存在引起
+ */
+func isUndefinedObject(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+
+	switch pqErr.Code {
+	case "42P01", "42883", "42703", "3F000":
+		return true
+	}
+
+	return false
+}
+
+/**
+* 函数：skipUndefinedObject
+* 功能：视图、函数、字段或模式不存在时记录警告并忽略错误，what为被跳过的指标说明
+ */
+func skipUndefinedObject(what string, err error) error {
+	if isUndefinedObject(err) {
+		logger.Warnf("skip %s metrics, error:%v", what, err)
+		return nil
+	}
+
+	return err
+}
+
+/**
+* 函数：classifyErr
+* 功能：对单个错误进行分类：timeout、connection、permission、missing_relation、other
+ */
+func classifyErr(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return errorCategoryTimeout
+	}
+
+	if isUndefinedObject(err) {
+		return errorCategoryMissingRelation
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == "57014":
+			return errorCategoryTimeout
+		case pqErr.Code == "42501" || pqErr.Code.Class() == "28":
+			return errorCategoryPermission
+		case pqErr.Code.Class() == "08" || pqErr.Code.Class() == "57":
+			return errorCategoryConnection
+		}
+
+		return errorCategoryOther
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return errorCategoryTimeout
+		}
+		return errorCategoryConnection
+	}
+
+	if errors.Is(err, driver.ErrBadConn) {
+		return errorCategoryConnection
+	}
+
+	return errorCategoryOther
+}
+
+/**
+* 函数：errCategories
+* 功能：获取(组合)错误涉及的所有分类，每个分类只出现一次
+ */
+func errCategories(err error) []string {
+	seen := make(map[string]bool)
+	categories := make([]string, 0)
+
+	for _, e := range flattenErr(err) {
+		category := classifyErr(e)
+		if !seen[category] {
+			seen[category] = true
+			categories = append(categories, category)
+		}
+	}
+
+	return categories
 }
@@ -0,0 +1,24 @@
+package collector
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRowErr(t *testing.T) {
+	if err := rowErr("bloat_tables", nil, "dbname", "sales"); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+
+	cause := errors.New("converting driver.Value type string to a float64")
+	err := rowErr("bloat_tables", cause, "dbname", "sales", "schema", "public", "table", `my "table"`)
+
+	expected := `bloat_tables row (dbname="sales", schema="public", table="my \"table\""): converting driver.Value type string to a float64`
+	if err.Error() != expected {
+		t.Errorf("expected %s, got %s", expected, err)
+	}
+
+	if !errors.Is(err, cause) {
+		t.Error("the row error must wrap its cause")
+	}
+}
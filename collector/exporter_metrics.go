@@ -1,6 +1,9 @@
 package collector
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/version"
+)
 
 const (
 	namespace         = "greenplum"
@@ -10,12 +13,33 @@ const (
 	subSystemNode     = "node"
 )
 
+// 最近一次获取按库连接时建立连接或Ping验证的耗时，由pingDatabase记录，数据库被删除后移除
+var dbConnectSeconds = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystemExporter,
+		Name:      "db_connect_seconds",
+		Help:      "Seconds taken to establish or ping the connection to each database the last time it was used",
+	},
+	[]string{"dbname"},
+)
+
 // 定义指标类型结构体
 type ExporterMetrics struct {
 	totalScraped   prometheus.Counter
 	totalError     prometheus.Counter
 	scrapeDuration prometheus.Gauge
 	greenPlumUp    prometheus.Gauge
+	lastScrapeTime prometheus.Gauge
+	utilityMode    prometheus.Gauge
+	scrapeErrors   *prometheus.CounterVec
+	incomplete     *prometheus.GaugeVec
+	emitted        *prometheus.GaugeVec // 每次抓取重新设置，不是累计值，因此为Gauge且名称不带_total
+	connectSeconds *prometheus.GaugeVec
+	querySeconds   *prometheus.GaugeVec
+	success        *prometheus.GaugeVec
+	duration       *prometheus.HistogramVec
+	buildInfo      prometheus.Collector
 }
 
 /**
@@ -55,5 +79,85 @@ func NewMetrics() *ExporterMetrics {
 				Help:      "Whether greenPlum cluster is reachable",
 			},
 		),
+		lastScrapeTime: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "last_scrape_timestamp_seconds",
+				Help:      "Unix timestamp of the last scrape in which greenPlum was reachable and every scraper succeeded",
+			},
+		),
+		utilityMode: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subSystemCluster,
+				Name:      "in_utility_mode",
+				Help:      "Whether the exporter connection runs in utility mode, in which most cluster wide metrics are unavailable or misleading",
+			},
+		),
+		scrapeErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystemExporter,
+				Name:      "scrape_errors_total",
+				Help:      "Total errors of each scraper by error category",
+			},
+			[]string{"scraper", "category"},
+		),
+		incomplete: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystemExporter,
+				Name:      "scrape_incomplete",
+				Help:      "Whether the last scrape of each scraper skipped databases because the database loop deadline was exceeded",
+			},
+			[]string{"scraper"},
+		),
+		emitted: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystemExporter,
+				Name:      "metrics_emitted",
+				Help:      "Number of samples each scraper emitted in the last scrape after metric filtering",
+			},
+			[]string{"scraper"},
+		),
+		connectSeconds: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystemExporter,
+				Name:      "scrape_connect_seconds",
+				Help:      "Seconds each scraper spent waiting for the connection pool and connecting to databases in the last scrape",
+			},
+			[]string{"scraper"},
+		),
+		querySeconds: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystemExporter,
+				Name:      "scrape_query_seconds",
+				Help:      "Seconds each scraper spent outside of connection acquisition, mostly executing queries, in the last scrape",
+			},
+			[]string{"scraper"},
+		),
+		// 与node_exporter的node_scrape_collector_success/duration_seconds对应，用于对单个抓取器的失败告警
+		success: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "scrape_success",
+				Help:      "Whether the last scrape of each scraper succeeded, 0 on errors or panics",
+			},
+			[]string{"collector"},
+		),
+		duration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "scrape_duration_seconds",
+				Help:      "Duration of each scrape of each scraper, including the queries against every database",
+				Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+			},
+			[]string{"collector"},
+		),
+		// greenplum_exporter_build_info，版本信息在编译时通过ldflags注入
+		buildInfo: version.NewCollector(namespace + "_" + subsystemExporter),
 	}
 }
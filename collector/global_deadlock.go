@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  全局死锁抓取器（Greenplum 6及以上版本）：全局死锁检测器没有提供统计视图，
+ *  这里通过gp_toolkit统计最近GPDB_LOG_GLOBAL_DEADLOCK_WINDOW_MINUTES(默认60，最大1440)分钟内
+ *  master日志中全局死锁检测器输出的日志条数。日志会轮转，条数不是单调递增的，因此以Gauge输出
+ */
+
+const (
+	globalDeadlockWindowEnv = "GPDB_LOG_GLOBAL_DEADLOCK_WINDOW_MINUTES"
+
+	globalDeadlocksSql = `SELECT count(*) from gp_toolkit.gp_log_master_concise
+		where logtime > now() - $1::int * interval '1 minute' and logmessage ilike '%global deadlock detected%';`
+)
+
+var (
+	globalDeadlocksDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "global_deadlocks_recent"),
+		"Number of global deadlocks reported by the global deadlock detector in the master logs within GPDB_LOG_GLOBAL_DEADLOCK_WINDOW_MINUTES",
+		nil, nil,
+	)
+)
+
+func NewGlobalDeadlockScraper() Scraper {
+	return globalDeadlockScraper{windowMinutes: logWindowMinutes(globalDeadlockWindowEnv)}
+}
+
+type globalDeadlockScraper struct {
+	windowMinutes int
+}
+
+func (globalDeadlockScraper) VersionRange() (min, max int) {
+	return 6, 0
+}
+
+// 需要读取master上的全部日志文件，耗时较长
+func (globalDeadlockScraper) Timeout() time.Duration {
+	return time.Second * 10
+}
+
+func (globalDeadlockScraper) Name() string {
+	return "global_deadlock_scraper"
+}
+
+func (s globalDeadlockScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	// Greenplum 5 没有全局死锁检测器
+	if ver < 6 {
+		return nil
+	}
+
+	count, err := scrapeScalar(ctx, db, globalDeadlocksSql, s.windowMinutes)
+
+	// gp_toolkit未安装时跳过
+	if isUndefinedObject(err) {
+		logger.Warnf("skip global deadlock metrics, error:%v", err)
+		return nil
+	}
+
+	if err != nil {
+		return skipScalarNull(err)
+	}
+
+	ch <- prometheus.MustNewConstMetric(globalDeadlocksDesc, prometheus.GaugeValue, count)
+
+	return nil
+}
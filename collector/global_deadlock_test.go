@@ -0,0 +1,41 @@
+package collector
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestGlobalDeadlockScraper(t *testing.T) {
+	setEnv(t, globalDeadlockWindowEnv, "30")
+
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta(globalDeadlocksSql)).WithArgs(30).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	expected := `
+# HELP greenplum_cluster_global_deadlocks_recent Number of global deadlocks reported by the global deadlock detector in the master logs within GPDB_LOG_GLOBAL_DEADLOCK_WINDOW_MINUTES
+# TYPE greenplum_cluster_global_deadlocks_recent gauge
+greenplum_cluster_global_deadlocks_recent 2
+`
+	if err := collectAndCompare(t, NewGlobalDeadlockScraper(), db, 6, expected); err != nil {
+		t.Errorf("unexpected scrape error: %v", err)
+	}
+}
+
+// Greenplum 5 不查询，未安装gp_toolkit时跳过
+func TestGlobalDeadlockScraperSkipped(t *testing.T) {
+	db, _ := newMockDB(t)
+	if err := collectAndCompare(t, NewGlobalDeadlockScraper(), db, 5, ""); err != nil {
+		t.Errorf("unexpected scrape error of version 5: %v", err)
+	}
+
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta(globalDeadlocksSql)).WillReturnError(&pq.Error{Code: "42P01"})
+
+	if err := collectAndCompare(t, NewGlobalDeadlockScraper(), db, 6, ""); err != nil {
+		t.Errorf("expected gp_toolkit errors to be skipped, got %v", err)
+	}
+}
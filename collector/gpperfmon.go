@@ -0,0 +1,35 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"os"
+)
+
+/**
+ *  gpperfmon数据库的连接，gpperfmon相关的抓取器通过--gpperfmon开启
+ */
+
+const (
+	gpperfmonDatabaseEnv = "GPDB_GPPERFMON_DATABASE"
+)
+
+/**
+* 函数：gpperfmonDatabase
+* 功能：获取gpperfmon数据库名称，默认为gpperfmon
+ */
+func gpperfmonDatabase() string {
+	if dbname := os.Getenv(gpperfmonDatabaseEnv); dbname != "" {
+		return dbname
+	}
+
+	return "gpperfmon"
+}
+
+/**
+* 函数：openGpperfmon
+* 功能：获取gpperfmon数据库的连接，连接在多次抓取之间复用
+ */
+func openGpperfmon(ctx context.Context, conns *ConnectionCache) (*sql.DB, error) {
+	return conns.get(ctx, gpperfmonDatabase())
+}
@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/**
+ *  gpperfmon状态抓取器：gpperfmon停止采集后其它基于gpperfmon的指标不会更新，通过最新一条采集记录的时间发现；
+ *  历史表分区没有清理时gpperfmon数据库会持续增长，输出其大小和最早一条采集记录的时间
+ */
+
+const (
+	gpperfmonLastCollectionSql = `SELECT extract(epoch from now() - max(ctime)) from system_history;`
+	gpperfmonOldestHistorySql  = `SELECT extract(epoch from min(ctime)::timestamptz) from system_history;`
+	gpperfmonDatabaseSizeSql   = `SELECT pg_database_size(current_database());`
+)
+
+var (
+	gpperfmonLastCollectionDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "gpperfmon_last_collection_seconds"),
+		"Seconds since the newest sample in gpperfmon system_history",
+		nil, nil,
+	)
+
+	gpperfmonOldestHistoryDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "gpperfmon_oldest_history_timestamp_seconds"),
+		"Timestamp of the oldest sample retained in gpperfmon system_history",
+		nil, nil,
+	)
+
+	gpperfmonDatabaseSizeDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "gpperfmon_database_size_bytes"),
+		"Total bytes size of the gpperfmon database",
+		nil, nil,
+	)
+)
+
+func NewGpperfmonStatusScraper(conns *ConnectionCache) Scraper {
+	return gpperfmonStatusScraper{conns: conns}
+}
+
+type gpperfmonStatusScraper struct {
+	conns *ConnectionCache
+}
+
+func (gpperfmonStatusScraper) Name() string {
+	return "gpperfmon_status_scraper"
+}
+
+func (s gpperfmonStatusScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	conn, err := openGpperfmon(ctx, s.conns)
+	if err != nil {
+		return err
+	}
+
+	// system_history为空(gpperfmon从未采集)时不输出
+	errL := skipScalarNull(scrapeScalarGauge(ctx, conn, ch, gpperfmonLastCollectionDesc, gpperfmonLastCollectionSql))
+	errO := skipScalarNull(scrapeScalarGauge(ctx, conn, ch, gpperfmonOldestHistoryDesc, gpperfmonOldestHistorySql))
+	errS := skipScalarNull(scrapeScalarGauge(ctx, conn, ch, gpperfmonDatabaseSizeDesc, gpperfmonDatabaseSizeSql))
+
+	return combineErr(
+		wrapErr("last_collection", errL),
+		wrapErr("oldest_history", errO),
+		wrapErr("database_size", errS),
+	)
+}
@@ -0,0 +1,167 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// 将单个抓取器包装为prometheus.Collector，Collect时执行一次抓取并记录返回的错误
+type scraperCollector struct {
+	scraper Scraper
+	db      Queryer
+	ver     int
+	err     error
+}
+
+func (c *scraperCollector) Describe(ch chan<- *prometheus.Desc) {
+}
+
+func (c *scraperCollector) Collect(ch chan<- prometheus.Metric) {
+	c.err = c.scraper.Scrape(context.Background(), c.db, ch, c.ver)
+}
+
+// 将单个查询函数包装为抓取器，便于用collectAndCompare测试抓取器内部的查询
+type scrapeFunc func(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error
+
+func (scrapeFunc) Name() string {
+	return "test_scraper"
+}
+
+func (f scrapeFunc) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	return f(ctx, db, ch, ver)
+}
+
+/**
+* 函数：newMockDB
+* 功能：创建sqlmock连接，测试结束时检查所有预期的查询都已执行
+ */
+func newMockDB(t *testing.T) (*sql.DB, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("create sqlmock failed: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unfulfilled expectations: %v", err)
+		}
+		db.Close()
+	})
+
+	return db, mock
+}
+
+/**
+* 函数：expectEmptyQueries
+* 功能：按顺序预期多个返回空结果的查询
+ */
+func expectEmptyQueries(mock sqlmock.Sqlmock, queries ...string) {
+	for _, query := range queries {
+		mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnRows(sqlmock.NewRows([]string{"value"}))
+	}
+}
+
+/**
+* 函数：expectFailedQueries
+* 功能：按顺序预期多个执行失败的查询
+ */
+func expectFailedQueries(mock sqlmock.Sqlmock, queries ...string) {
+	for _, query := range queries {
+		mock.ExpectQuery(regexp.QuoteMeta(query)).WillReturnError(errors.New("connection reset"))
+	}
+}
+
+/**
+* 函数：mockOpenDatabase
+* 功能：将按库连接替换为各库的sqlmock连接，值为nil时模拟连接失败，测试结束时恢复openDatabase
+ */
+func mockOpenDatabase(t *testing.T, dbs map[string]*sql.DB) {
+	t.Helper()
+
+	origin := openDatabase
+	openDatabase = func(ctx context.Context, dbname string) (*sql.DB, error) {
+		db, ok := dbs[dbname]
+		if !ok {
+			t.Errorf("unexpected connection to database %q", dbname)
+			return nil, sql.ErrConnDone
+		}
+
+		if db == nil {
+			return nil, errors.New("connection refused")
+		}
+
+		return db, nil
+	}
+
+	t.Cleanup(func() {
+		openDatabase = origin
+	})
+}
+
+/**
+* 函数：setEnv
+* 功能：设置环境变量，value为空时清除，测试结束时恢复原值
+ */
+func setEnv(t *testing.T, key, value string) {
+	t.Helper()
+
+	origin, ok := os.LookupEnv(key)
+	if value == "" {
+		os.Unsetenv(key)
+	} else {
+		os.Setenv(key, value)
+	}
+
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, origin)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+/**
+* 函数：collectAndCompare
+* 功能：在fakeDB上执行一次抓取，将输出的指标与文本格式的expected比较(只比较metricNames中的指标，为空时比较全部)，返回抓取器的错误
+ */
+func collectAndCompare(t *testing.T, scraper Scraper, fakeDB Queryer, ver int, expected string, metricNames ...string) error {
+	t.Helper()
+
+	c := &scraperCollector{scraper: scraper, db: fakeDB, ver: ver}
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), metricNames...); err != nil {
+		t.Errorf("unexpected metrics: %v", err)
+	}
+
+	return c.err
+}
+
+/**
+* 函数：expectErrLabels
+* 功能：检查抓取器返回的组合错误中包含labels中每个子项的错误
+ */
+func expectErrLabels(t *testing.T, err error, labels ...string) {
+	t.Helper()
+
+	if err == nil {
+		t.Errorf("expected the errors of %v", labels)
+		return
+	}
+
+	for _, label := range labels {
+		if !strings.Contains(err.Error(), label+": ") {
+			t.Errorf("expected the error of %s, got %v", label, err)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  主机磁盘IO抓取器：gpperfmon的system_history只记录每个采样周期内的读写速率，
+ *  这里按相邻两条记录的时间间隔累加为读写字节总数
+ */
+
+const (
+	systemHistoryColumnsSql = `
+		SELECT count(*) FROM information_schema.columns
+		WHERE table_name = 'system_history' AND column_name in ('disk_rb_rate', 'disk_wb_rate')
+	`
+	hostDiskIOSeedSql = `
+		SELECT hostname, max(ctime), max(ctime)::timestamptz FROM system_history
+		WHERE ctime >= now() - interval '10 minute'
+		GROUP BY hostname
+	`
+	hostDiskIOSql = `
+		SELECT hostname, ctime, ctime::timestamptz, disk_rb_rate, disk_wb_rate FROM system_history
+		WHERE ctime > $1::timestamp
+		ORDER BY ctime
+	`
+)
+
+var (
+	hostDiskReadBytesDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "host_disk_read_bytes"),
+		"Bytes read from disk on the host accumulated from gpperfmon system_history since the exporter started",
+		[]string{"hostname"}, nil,
+	)
+
+	hostDiskWriteBytesDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "host_disk_write_bytes"),
+		"Bytes written to disk on the host accumulated from gpperfmon system_history since the exporter started",
+		[]string{"hostname"}, nil,
+	)
+)
+
+func NewHostDiskIOScraper(conns *ConnectionCache) Scraper {
+	return &hostDiskIOScraper{hosts: make(map[string]*hostDiskIO), conns: conns}
+}
+
+type hostDiskIO struct {
+	// lastTime为gpperfmon中不带时区的ctime，用于下一次查询；sampleTime为带时区的采集时间，作为指标的时间戳
+	lastTime   time.Time
+	sampleTime time.Time
+	readBytes  float64
+	writeBytes float64
+}
+
+type hostDiskIOScraper struct {
+	mu sync.Mutex
+
+	hosts map[string]*hostDiskIO
+	conns *ConnectionCache
+}
+
+func (*hostDiskIOScraper) Name() string {
+	return "host_disk_io_scraper"
+}
+
+func (s *hostDiskIOScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	conn, err := openGpperfmon(ctx, s.conns)
+	if err != nil {
+		return err
+	}
+
+	// 不同版本的gpperfmon中system_history的字段不同，没有读写速率字段时不抓取
+	var columns int
+	err = conn.QueryRowContext(ctx, systemHistoryColumnsSql).Scan(&columns)
+	if err != nil {
+		return err
+	}
+
+	if columns < 2 {
+		logger.Warnf("gpperfmon system_history has no disk_rb_rate/disk_wb_rate columns, skip disk io metrics")
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.hosts) == 0 {
+		if err = s.seed(ctx, conn); err != nil {
+			return err
+		}
+	} else if err = s.accumulate(ctx, conn); err != nil {
+		return err
+	}
+
+	for hostname, io := range s.hosts {
+		ch <- prometheus.NewMetricWithTimestamp(io.sampleTime, prometheus.MustNewConstMetric(hostDiskReadBytesDesc, prometheus.CounterValue, io.readBytes, hostname))
+		ch <- prometheus.NewMetricWithTimestamp(io.sampleTime, prometheus.MustNewConstMetric(hostDiskWriteBytesDesc, prometheus.CounterValue, io.writeBytes, hostname))
+	}
+
+	return nil
+}
+
+/**
+* 函数：seed
+* 功能：首次抓取时只记录每个主机最新一条记录的时间，不累加历史数据
+ */
+func (s *hostDiskIOScraper) seed(ctx context.Context, conn *sql.DB) error {
+	logger.Debugf("Query Database: %s", hostDiskIOSeedSql)
+	rows, err := conn.QueryContext(ctx, hostDiskIOSeedSql)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var hostname string
+		var ctime, sampleTime time.Time
+
+		if err := rows.Scan(&hostname, &ctime, &sampleTime); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		s.hosts[hostname] = &hostDiskIO{lastTime: ctime, sampleTime: sampleTime}
+	}
+
+	return combineErr(errs...)
+}
+
+/**
+* 函数：accumulate
+* 功能：把上次抓取之后新增的记录按 速率*采样间隔 累加到读写字节数
+ */
+func (s *hostDiskIOScraper) accumulate(ctx context.Context, conn *sql.DB) error {
+	var since time.Time
+	for _, io := range s.hosts {
+		if since.IsZero() || io.lastTime.Before(since) {
+			since = io.lastTime
+		}
+	}
+
+	logger.Debugf("Query Database: %s", hostDiskIOSql)
+	rows, err := conn.QueryContext(ctx, hostDiskIOSql, since.Format("2006-01-02 15:04:05.999999"))
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var hostname string
+		var ctime, sampleTime time.Time
+		var readRate, writeRate float64
+
+		if err := rows.Scan(&hostname, &ctime, &sampleTime, &readRate, &writeRate); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		io, ok := s.hosts[hostname]
+		if !ok {
+			s.hosts[hostname] = &hostDiskIO{lastTime: ctime, sampleTime: sampleTime}
+			continue
+		}
+
+		if !ctime.After(io.lastTime) {
+			continue
+		}
+
+		elapsed := ctime.Sub(io.lastTime).Seconds()
+		io.readBytes += readRate * elapsed
+		io.writeBytes += writeRate * elapsed
+		io.lastTime = ctime
+		io.sampleTime = sampleTime
+	}
+
+	return combineErr(errs...)
+}
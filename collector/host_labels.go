@@ -0,0 +1,136 @@
+package collector
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  主机标签映射：从GPDB_HOST_LABELS指定的CSV文件中加载主机名对应的机架(rack)和数据中心(dc)
+ *  文件格式为每行"hostname,rack,dc"，以#开头的行为注释；文件修改后在下一次抓取时自动重新加载
+ */
+
+const (
+	hostLabelsEnv = "GPDB_HOST_LABELS"
+)
+
+type hostLabel struct {
+	rack string
+	dc   string
+}
+
+type hostLabels struct {
+	mu sync.Mutex
+
+	path    string
+	modTime time.Time
+	labels  map[string]hostLabel
+}
+
+func newHostLabels(path string) *hostLabels {
+	return &hostLabels{path: path, labels: make(map[string]hostLabel)}
+}
+
+/**
+* 函数：refresh
+* 功能：映射文件有变化时重新加载，加载失败时保留上一次的映射
+ */
+func (h *hostLabels) refresh() {
+	if h.path == "" {
+		return
+	}
+
+	info, err := os.Stat(h.path)
+	if err != nil {
+		logger.Errorf("stat host labels file %s failed, error:%v", h.path, err)
+		return
+	}
+
+	h.mu.Lock()
+	changed := !info.ModTime().Equal(h.modTime)
+	h.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if err = h.reload(); err != nil {
+		logger.Errorf("load host labels file %s failed, error:%v", h.path, err)
+	}
+}
+
+/**
+* 函数：reload
+* 功能：重新加载映射文件
+ */
+func (h *hostLabels) reload() error {
+	if h.path == "" {
+		return nil
+	}
+
+	file, err := os.Open(h.path)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	labels := make(map[string]hostLabel)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		label := hostLabel{}
+		if len(record) > 1 {
+			label.rack = strings.TrimSpace(record[1])
+		}
+		if len(record) > 2 {
+			label.dc = strings.TrimSpace(record[2])
+		}
+
+		labels[strings.TrimSpace(record[0])] = label
+	}
+
+	h.mu.Lock()
+	h.labels = labels
+	h.modTime = info.ModTime()
+	h.mu.Unlock()
+
+	logger.Infof("loaded %d host labels from %s", len(labels), h.path)
+
+	return nil
+}
+
+/**
+* 函数：lookup
+* 功能：获取主机的机架和数据中心标签，未知主机返回空字符串
+ */
+func (h *hostLabels) lookup(hostname string) (rack, dc string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	label := h.labels[hostname]
+
+	return label.rack, label.dc
+}
@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/**
+ *  索引膨胀抓取器：按平均字段宽度估算btree索引应占用的页数，实际页数超过估算值GPDB_INDEX_BLOAT_RATIO倍(默认2倍)时认为需要REINDEX
+ *  只统计不小于128页的索引，避免小索引带来的误差
+ */
+
+const (
+	indexBloatRatioEnv = "GPDB_INDEX_BLOAT_RATIO"
+
+	indexBloatSql = `
+		SELECT n.nspname schema_name, ci.relname index_name, ci.relpages,
+			ceil(ci.reltuples * (sum(coalesce(s.avg_width, 8)) + 12) / (current_setting('block_size')::numeric * 0.9)) est_pages
+		FROM pg_index i
+			JOIN pg_class ci ON ci.oid = i.indexrelid
+			JOIN pg_class ct ON ct.oid = i.indrelid
+			JOIN pg_namespace n ON n.oid = ct.relnamespace
+			JOIN pg_am am ON am.oid = ci.relam AND am.amname = 'btree'
+			JOIN pg_attribute a ON a.attrelid = ci.oid AND a.attnum > 0
+			LEFT JOIN pg_stats s ON s.schemaname = n.nspname AND s.tablename = ct.relname AND s.attname = a.attname
+		WHERE n.nspname NOT IN ('gp_toolkit','information_schema','pg_catalog')
+			AND ci.relpages >= 128 AND ci.reltuples > 0
+		GROUP BY n.nspname, ci.relname, ci.relpages, ci.reltuples
+	`
+	indexesNeedingReindexSql = `SELECT count(*) FROM (` + indexBloatSql + `) t WHERE relpages > est_pages * $1::numeric`
+)
+
+var (
+	indexesNeedingReindexDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "indexes_needing_reindex"),
+		"Number of btree indexes whose estimated bloat exceeds the threshold in each database",
+		[]string{"dbname"}, nil,
+	)
+)
+
+func NewIndexBloatScraper(conns *ConnectionCache) Scraper {
+	return indexBloatScraper{ratio: envFloat(indexBloatRatioEnv, 2), conns: conns}
+}
+
+type indexBloatScraper struct {
+	ratio float64
+	conns *ConnectionCache
+}
+
+func (indexBloatScraper) Name() string {
+	return "index_bloat_scraper"
+}
+
+func (s indexBloatScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	return forEachDatabase(ctx, db, s.conns, ch, func(ctx context.Context, conn *sql.DB, dbname string, ch chan<- prometheus.Metric) error {
+		count, err := scrapeScalar(ctx, conn, indexesNeedingReindexSql, s.ratio)
+		if err != nil {
+			return skipScalarNull(err)
+		}
+
+		ch <- prometheus.MustNewConstMetric(indexesNeedingReindexDesc, prometheus.GaugeValue, count, dbname)
+
+		return nil
+	})
+}
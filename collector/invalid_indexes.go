@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/**
+ *  无效索引抓取器：统计每个用户数据库中indisvalid或indisready为false的索引数，这些索引不会被优化器使用
+ */
+
+const (
+	invalidIndexesSql = `SELECT count(*) from pg_index where not indisvalid or not indisready;`
+)
+
+var (
+	invalidIndexesDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "invalid_indexes"),
+		"Number of invalid or not ready indexes in each database",
+		[]string{"dbname"}, nil,
+	)
+)
+
+func NewInvalidIndexesScraper(conns *ConnectionCache) Scraper {
+	return invalidIndexesScraper{conns: conns}
+}
+
+type invalidIndexesScraper struct {
+	conns *ConnectionCache
+}
+
+func (invalidIndexesScraper) Name() string {
+	return "invalid_indexes_scraper"
+}
+
+func (s invalidIndexesScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	return forEachDatabase(ctx, db, s.conns, ch, func(ctx context.Context, conn *sql.DB, dbname string, ch chan<- prometheus.Metric) error {
+		return skipScalarNull(scrapeScalarGauge(ctx, conn, ch, invalidIndexesDesc, invalidIndexesSql, dbname))
+	})
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/**
+ *  大表未分区抓取器：统计每个用户数据库中大小超过GPDB_LARGE_TABLE_SIZE_MB(默认102400，即100GB)且不属于
+ *  任何分区表(既不是分区表的父表也不是子分区)的表个数。需要计算每个表的大小，开销较大，默认不开启；
+ *  查询超时时间为GPDB_LARGE_TABLE_TIMEOUT_SECONDS(默认60秒)，小于--scrape.timeout时使用后者
+ */
+
+const (
+	largeTableSizeEnv    = "GPDB_LARGE_TABLE_SIZE_MB"
+	largeTableTimeoutEnv = "GPDB_LARGE_TABLE_TIMEOUT_SECONDS"
+
+	// Greenplum 7 使用原生分区，子分区的relispartition为true，父表的relkind为p
+	largeUnpartitionedTablesSql_V7 = `SELECT count(*) from pg_class c join pg_namespace n on n.oid = c.relnamespace
+		where c.relkind in ('r', 'm') and not c.relispartition
+		and n.nspname not in ('pg_catalog', 'information_schema', 'gp_toolkit')
+		and pg_total_relation_size(c.oid) > $1::bigint * 1024 * 1024;`
+	largeUnpartitionedTablesSql_V6 = `SELECT count(*) from pg_class c join pg_namespace n on n.oid = c.relnamespace
+		where c.relkind = 'r' and c.relstorage <> 'x'
+		and n.nspname not in ('pg_catalog', 'information_schema', 'gp_toolkit')
+		and c.oid not in (select parrelid from pg_partition)
+		and c.oid not in (select parchildrelid from pg_partition_rule)
+		and pg_total_relation_size(c.oid) > $1::bigint * 1024 * 1024;`
+)
+
+var (
+	largeUnpartitionedTablesDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "large_unpartitioned_tables"),
+		"Number of tables in each database larger than GPDB_LARGE_TABLE_SIZE_MB that are not part of a partitioned table",
+		[]string{"dbname"}, nil,
+	)
+)
+
+func NewLargeTablesScraper(conns *ConnectionCache) Scraper {
+	return largeTablesScraper{
+		thresholdMB: envInt(largeTableSizeEnv, 102400),
+		timeout:     time.Duration(envInt(largeTableTimeoutEnv, 60)) * time.Second,
+		conns:       conns,
+	}
+}
+
+type largeTablesScraper struct {
+	thresholdMB int
+	timeout     time.Duration
+	conns       *ConnectionCache
+}
+
+// 需要计算每个库中所有表的大小，耗时较长
+func (s largeTablesScraper) Timeout() time.Duration {
+	return s.timeout
+}
+
+func (largeTablesScraper) Name() string {
+	return "large_tables_scraper"
+}
+
+func (s largeTablesScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	// Greenplum 5和6的分区信息都在pg_partition、pg_partition_rule中
+	querySql := largeUnpartitionedTablesSql_V7
+	if ver < 7 {
+		querySql = largeUnpartitionedTablesSql_V6
+	}
+
+	return forEachDatabase(ctx, db, s.conns, ch, func(ctx context.Context, conn *sql.DB, dbname string, ch chan<- prometheus.Metric) error {
+		count, err := scrapeScalar(ctx, conn, querySql, s.thresholdMB)
+		if err != nil {
+			return skipScalarNull(err)
+		}
+
+		ch <- prometheus.MustNewConstMetric(largeUnpartitionedTablesDesc, prometheus.GaugeValue, count, dbname)
+
+		return nil
+	})
+}
@@ -0,0 +1,24 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLargeTablesScraperTimeout(t *testing.T) {
+	for _, c := range []struct {
+		value   string
+		timeout time.Duration
+	}{
+		{"", time.Minute},
+		{"300", 5 * time.Minute},
+		{"abc", time.Minute},
+	} {
+		setEnv(t, largeTableTimeoutEnv, c.value)
+
+		scraper := NewLargeTablesScraper(nil).(Timeouter)
+		if timeout := scraper.Timeout(); timeout != c.timeout {
+			t.Errorf("expected timeout %v of %q, got %v", c.timeout, c.value, timeout)
+		}
+	}
+}
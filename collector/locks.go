@@ -1,9 +1,9 @@
 package collector
 
 import (
-	"database/sql"
+	"context"
 	"github.com/prometheus/client_golang/prometheus"
-	logger "github.com/prometheus/common/log"
+	logger "greenplum-exporter/logging"
 	"time"
 )
 
@@ -64,15 +64,52 @@ const (
 		pg_stat_activity.application_name, state , lock_satus ,pg_stat_activity.current_query, start_time
 		ORDER BY start_time
 		`
+	// pg_locks包含所有segment上的锁，只有同一segment上同一对象的锁才构成等待关系；
+	// 一个分布式会话在每个segment上有不同的pid，按mppsessionid标识会话
+	lockWaitEdgesSql = `
+		SELECT DISTINCT w.mppsessionid, h.mppsessionid
+		  FROM pg_locks w
+		  JOIN pg_locks h ON h.granted AND NOT w.granted AND w.mppsessionid <> h.mppsessionid
+		   AND w.gp_segment_id = h.gp_segment_id
+		   AND w.locktype = h.locktype
+		   AND w.database IS NOT DISTINCT FROM h.database
+		   AND w.relation IS NOT DISTINCT FROM h.relation
+		   AND w.page IS NOT DISTINCT FROM h.page
+		   AND w.tuple IS NOT DISTINCT FROM h.tuple
+		   AND w.virtualxid IS NOT DISTINCT FROM h.virtualxid
+		   AND w.transactionid IS NOT DISTINCT FROM h.transactionid
+		   AND w.classid IS NOT DISTINCT FROM h.classid
+		   AND w.objid IS NOT DISTINCT FROM h.objid
+		   AND w.objsubid IS NOT DISTINCT FROM h.objsubid
+		`
+	// pg_locks包含所有segment上的锁，按mppsessionid去重统计会话数
+	blockedByLocktypeSql = `SELECT locktype, count(distinct mppsessionid) from pg_locks WHERE NOT granted GROUP BY locktype;`
 )
 
 var (
-	locksDesc = prometheus.NewDesc(
+	locksDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemServer, "locks_table_detail"),
 		"Table locks detail for greenplum database",
 		[]string{"pid", "datname", "usename", "locktype", "mode", "application_name", "state", "lock_satus", "query"},
 		nil,
 	)
+
+	lockWaitChainDepthDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "max_lock_wait_chain_depth"),
+		"Depth of the longest lock wait chain, 0 if no session is waiting for a lock",
+		nil,
+		nil,
+	)
+
+	blockedByLocktypeDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "blocked_sessions_by_locktype"),
+		"Number of sessions waiting for a lock grouped by lock type",
+		[]string{"locktype"},
+		nil,
+	)
+
+	// 常见的锁类型没有等待时输出0，保证序列存在
+	commonLocktypes = []string{"relation", "extend", "page", "tuple", "transactionid", "virtualxid", "object", "advisory"}
 )
 
 func NewLocksScraper() Scraper {
@@ -85,14 +122,22 @@ func (locksScraper) Name() string {
 	return "locks_scraper"
 }
 
-func (locksScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric, ver int) error {
+func (locksScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	errD := scrapeLocksDetail(ctx, db, ch, ver)
+	errC := scrapeLockWaitChain(ctx, db, ch)
+	errT := scrapeBlockedByLocktype(ctx, db, ch)
+
+	return combineErr(wrapErr("locks_detail", errD), wrapErr("lock_wait_chain", errC), wrapErr("blocked_by_locktype", errT))
+}
+
+func scrapeLocksDetail(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
 	querySql :=locksQuerySql_V6;
 	if ver < 6{
 		querySql=locksQuerySql_V5;
 	}
 
-	rows, err := db.Query(querySql)
-	logger.Infof("Query Database: %s", querySql)
+	rows, err := db.QueryContext(ctx, querySql)
+	logger.Debugf("Query Database: %s", querySql)
 
 	if err != nil {
 		logger.Errorf("get metrics for scraper, error:%v", err.Error())
@@ -127,3 +172,114 @@ func (locksScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric, ver int) err
 
 	return nil
 }
+
+func scrapeLockWaitChain(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	rows, err := db.QueryContext(ctx, lockWaitEdgesSql)
+	logger.Debugf("Query Database: %s", lockWaitEdgesSql)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	// 等待关系图：等待会话 -> 阻塞会话列表
+	blockers := make(map[int64][]int64)
+	for rows.Next() {
+		var waiting, blocking int64
+
+		if err = rows.Scan(&waiting, &blocking); err != nil {
+			return err
+		}
+
+		blockers[waiting] = append(blockers[waiting], blocking)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	ch <- prometheus.MustNewConstMetric(lockWaitChainDepthDesc, prometheus.GaugeValue, float64(maxWaitChainDepth(blockers)))
+
+	return nil
+}
+
+func scrapeBlockedByLocktype(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	rows, err := db.QueryContext(ctx, blockedByLocktypeSql)
+	logger.Debugf("Query Database: %s", blockedByLocktypeSql)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	counts := make(map[string]float64)
+	for _, locktype := range commonLocktypes {
+		counts[locktype] = 0
+	}
+
+	for rows.Next() {
+		var locktype string
+		var count float64
+
+		if err = rows.Scan(&locktype, &count); err != nil {
+			return err
+		}
+
+		counts[locktype] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for locktype, count := range counts {
+		ch <- prometheus.MustNewConstMetric(blockedByLocktypeDesc, prometheus.GaugeValue, count, locktype)
+	}
+
+	return nil
+}
+
+/**
+* 函数：maxWaitChainDepth
+* 功能：计算等待关系图中最长等待链的长度，出现环(死锁)时环上的每个会话只计算一次
+ */
+func maxWaitChainDepth(blockers map[int64][]int64) int {
+	depth := make(map[int64]int)
+	visiting := make(map[int64]bool)
+
+	var walk func(pid int64) int
+	walk = func(pid int64) int {
+		if d, ok := depth[pid]; ok {
+			return d
+		}
+
+		if visiting[pid] {
+			return 0
+		}
+
+		visiting[pid] = true
+
+		max := 0
+		for _, blocker := range blockers[pid] {
+			if d := walk(blocker) + 1; d > max {
+				max = d
+			}
+		}
+
+		visiting[pid] = false
+		depth[pid] = max
+
+		return max
+	}
+
+	max := 0
+	for pid := range blockers {
+		if d := walk(pid); d > max {
+			max = d
+		}
+	}
+
+	return max
+}
@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMaxWaitChainDepth(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		blockers map[int64][]int64
+		depth    int
+	}{
+		{"no_wait", map[int64][]int64{}, 0},
+		{"single", map[int64][]int64{1: {2}}, 1},
+		{"chain", map[int64][]int64{1: {2}, 2: {3}, 3: {4}}, 3},
+		{"branches", map[int64][]int64{1: {2, 5}, 2: {3}, 5: {6}, 6: {7}, 7: {8}}, 4},
+		{"deadlock", map[int64][]int64{1: {2}, 2: {1}}, 2},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			if depth := maxWaitChainDepth(c.blockers); depth != c.depth {
+				t.Errorf("expected depth %d, got %d", c.depth, depth)
+			}
+		})
+	}
+}
+
+func TestLocksScraper(t *testing.T) {
+	db, mock := newMockDB(t)
+	start := time.Unix(1600000000, 0)
+	mock.ExpectQuery(regexp.QuoteMeta(locksQuerySql_V6)).WillReturnRows(sqlmock.NewRows(
+		[]string{"pid", "datname", "usename", "locktype", "mode", "application_name", "state", "lock_satus", "query", "start_time", "count"}).
+		AddRow("1001", "sales", "etl", "relation", "AccessExclusiveLock", "psql", "active", "get_lock", "truncate orders", start, 1))
+	// 会话3等待2，2等待1
+	mock.ExpectQuery(regexp.QuoteMeta(lockWaitEdgesSql)).WillReturnRows(sqlmock.NewRows([]string{"w", "h"}).
+		AddRow(3, 2).
+		AddRow(2, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(blockedByLocktypeSql)).WillReturnRows(sqlmock.NewRows([]string{"locktype", "count"}).
+		AddRow("relation", 2))
+
+	expected := `
+# HELP greenplum_server_locks_table_detail Table locks detail for greenplum database
+# TYPE greenplum_server_locks_table_detail gauge
+greenplum_server_locks_table_detail{application_name="psql",datname="sales",lock_satus="get_lock",locktype="relation",mode="AccessExclusiveLock",pid="1001",query="truncate orders",state="active",usename="etl"} 1.6e+09
+# HELP greenplum_server_max_lock_wait_chain_depth Depth of the longest lock wait chain, 0 if no session is waiting for a lock
+# TYPE greenplum_server_max_lock_wait_chain_depth gauge
+greenplum_server_max_lock_wait_chain_depth 2
+# HELP greenplum_server_blocked_sessions_by_locktype Number of sessions waiting for a lock grouped by lock type
+# TYPE greenplum_server_blocked_sessions_by_locktype gauge
+greenplum_server_blocked_sessions_by_locktype{locktype="advisory"} 0
+greenplum_server_blocked_sessions_by_locktype{locktype="extend"} 0
+greenplum_server_blocked_sessions_by_locktype{locktype="object"} 0
+greenplum_server_blocked_sessions_by_locktype{locktype="page"} 0
+greenplum_server_blocked_sessions_by_locktype{locktype="relation"} 2
+greenplum_server_blocked_sessions_by_locktype{locktype="transactionid"} 0
+greenplum_server_blocked_sessions_by_locktype{locktype="tuple"} 0
+greenplum_server_blocked_sessions_by_locktype{locktype="virtualxid"} 0
+`
+	if err := collectAndCompare(t, NewLocksScraper(), db, 6, expected); err != nil {
+		t.Errorf("unexpected scrape error: %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package collector
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  正在执行的维护操作(VACUUM/ANALYZE)抓取器
+ *  Greenplum 7 通过pg_stat_progress_vacuum统计vacuum和autovacuum，其它类型及旧版本通过SQL文本识别
+ */
+
+const (
+	maintenanceQueriesSql_V6 = `select query from pg_stat_activity
+		where pid <> pg_backend_pid() and state <> 'idle'
+		and (ltrim(query) ilike 'vacuum%' or ltrim(query) ilike 'analyze%' or ltrim(query) ilike 'autovacuum:%');`
+	maintenanceQueriesSql_V5 = `select current_query from pg_stat_activity
+		where procpid <> pg_backend_pid()
+		and (ltrim(current_query) ilike 'vacuum%' or ltrim(current_query) ilike 'analyze%' or ltrim(current_query) ilike 'autovacuum:%');`
+	vacuumProgressSql_V7 = `select case when a.backend_type = 'autovacuum worker' then 'autovacuum' else 'vacuum' end, count(*)
+		from pg_stat_progress_vacuum p join pg_stat_activity a on a.pid = p.pid group by 1;`
+)
+
+const (
+	maintenanceVacuum     = "vacuum"
+	maintenanceVacuumFull = "vacuum full"
+	maintenanceAnalyze    = "analyze"
+	maintenanceAutovacuum = "autovacuum"
+)
+
+var (
+	maintenanceOperationsDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "maintenance_operations_running"),
+		"Number of backends currently performing each kind of maintenance operation",
+		[]string{"type"}, nil,
+	)
+
+	vacuumFullPattern = regexp.MustCompile(`(?i)^vacuum\s*(\([^)]*\bfull\b[^)]*\)|full\b)`)
+)
+
+func NewMaintenanceScraper() Scraper {
+	return maintenanceScraper{}
+}
+
+type maintenanceScraper struct{}
+
+func (maintenanceScraper) Name() string {
+	return "maintenance_scraper"
+}
+
+func (maintenanceScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	counts := map[string]float64{
+		maintenanceVacuum:     0,
+		maintenanceVacuumFull: 0,
+		maintenanceAnalyze:    0,
+		maintenanceAutovacuum: 0,
+	}
+
+	querySql := maintenanceQueriesSql_V6
+	if ver < 6 {
+		querySql = maintenanceQueriesSql_V5
+	}
+
+	logger.Debugf("Query Database: %s", querySql)
+	rows, err := db.QueryContext(ctx, querySql)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var query string
+
+		if err = rows.Scan(&query); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		op := classifyMaintenance(query)
+
+		// Greenplum 7 的vacuum和autovacuum由进度视图统计
+		if ver >= 7 && (op == maintenanceVacuum || op == maintenanceAutovacuum) {
+			continue
+		}
+
+		counts[op]++
+	}
+
+	if ver >= 7 {
+		errs = append(errs, scrapeVacuumProgress(ctx, db, counts))
+	}
+
+	for op, count := range counts {
+		ch <- prometheus.MustNewConstMetric(maintenanceOperationsDesc, prometheus.GaugeValue, count, op)
+	}
+
+	return combineErr(errs...)
+}
+
+func scrapeVacuumProgress(ctx context.Context, db Queryer, counts map[string]float64) error {
+	logger.Debugf("Query Database: %s", vacuumProgressSql_V7)
+	rows, err := db.QueryContext(ctx, vacuumProgressSql_V7)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var op string
+		var count float64
+
+		if err = rows.Scan(&op, &count); err != nil {
+			return err
+		}
+
+		counts[op] += count
+	}
+
+	return rows.Err()
+}
+
+/**
+* 函数：classifyMaintenance
+* 功能：根据SQL文本识别维护操作的类型
+ */
+func classifyMaintenance(query string) string {
+	query = strings.TrimSpace(query)
+	lower := strings.ToLower(query)
+
+	switch {
+	case strings.HasPrefix(lower, "autovacuum:"):
+		return maintenanceAutovacuum
+	case vacuumFullPattern.MatchString(query):
+		return maintenanceVacuumFull
+	case strings.HasPrefix(lower, "vacuum"):
+		return maintenanceVacuum
+	default:
+		return maintenanceAnalyze
+	}
+}
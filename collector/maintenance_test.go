@@ -0,0 +1,95 @@
+package collector
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestClassifyMaintenance(t *testing.T) {
+	for query, op := range map[string]string{
+		"autovacuum: VACUUM public.orders": maintenanceAutovacuum,
+		"VACUUM FULL orders":               maintenanceVacuumFull,
+		"vacuum (verbose, full) orders":    maintenanceVacuumFull,
+		"  vacuum analyze orders":          maintenanceVacuum,
+		"vacuum fullname_table":            maintenanceVacuum,
+		"ANALYZE orders":                   maintenanceAnalyze,
+	} {
+		if got := classifyMaintenance(query); got != op {
+			t.Errorf("classifyMaintenance(%q) = %q, expected %q", query, got, op)
+		}
+	}
+}
+
+func TestMaintenanceScraper(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta(maintenanceQueriesSql_V6)).WillReturnRows(sqlmock.NewRows([]string{"query"}).
+		AddRow("vacuum full orders").
+		AddRow("vacuum analyze orders").
+		AddRow("autovacuum: VACUUM public.orders"))
+	mock.ExpectQuery(regexp.QuoteMeta(vacuumProgressSql_V7)).WillReturnRows(sqlmock.NewRows([]string{"type", "count"}).
+		AddRow("vacuum", 1).
+		AddRow("autovacuum", 2))
+
+	expected := `
+# HELP greenplum_server_maintenance_operations_running Number of backends currently performing each kind of maintenance operation
+# TYPE greenplum_server_maintenance_operations_running gauge
+greenplum_server_maintenance_operations_running{type="analyze"} 0
+greenplum_server_maintenance_operations_running{type="autovacuum"} 2
+greenplum_server_maintenance_operations_running{type="vacuum"} 1
+greenplum_server_maintenance_operations_running{type="vacuum full"} 1
+`
+	if err := collectAndCompare(t, NewMaintenanceScraper(), db, 7, expected); err != nil {
+		t.Errorf("unexpected scrape error: %v", err)
+	}
+}
+
+func TestMaintenanceScraperErrors(t *testing.T) {
+	t.Run("query_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectFailedQueries(mock, maintenanceQueriesSql_V6)
+
+		if err := collectAndCompare(t, NewMaintenanceScraper(), db, 6, ""); err == nil {
+			t.Error("expected the query error")
+		}
+	})
+
+	// 出错的行被跳过，其余行照常计数
+	t.Run("scan_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(maintenanceQueriesSql_V5)).WillReturnRows(sqlmock.NewRows([]string{"current_query"}).
+			AddRow(nil).
+			AddRow("analyze orders"))
+
+		expected := `
+# HELP greenplum_server_maintenance_operations_running Number of backends currently performing each kind of maintenance operation
+# TYPE greenplum_server_maintenance_operations_running gauge
+greenplum_server_maintenance_operations_running{type="analyze"} 1
+greenplum_server_maintenance_operations_running{type="autovacuum"} 0
+greenplum_server_maintenance_operations_running{type="vacuum"} 0
+greenplum_server_maintenance_operations_running{type="vacuum full"} 0
+`
+		if err := collectAndCompare(t, NewMaintenanceScraper(), db, 5, expected); err == nil {
+			t.Error("expected the scan error")
+		}
+	})
+
+	// 没有维护操作时各类型输出0
+	t.Run("empty", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectEmptyQueries(mock, maintenanceQueriesSql_V6, vacuumProgressSql_V7)
+
+		expected := `
+# HELP greenplum_server_maintenance_operations_running Number of backends currently performing each kind of maintenance operation
+# TYPE greenplum_server_maintenance_operations_running gauge
+greenplum_server_maintenance_operations_running{type="analyze"} 0
+greenplum_server_maintenance_operations_running{type="autovacuum"} 0
+greenplum_server_maintenance_operations_running{type="vacuum"} 0
+greenplum_server_maintenance_operations_running{type="vacuum full"} 0
+`
+		if err := collectAndCompare(t, NewMaintenanceScraper(), db, 7, expected); err != nil {
+			t.Errorf("unexpected scrape error: %v", err)
+		}
+	})
+}
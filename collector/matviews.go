@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/**
+ *  物化视图抓取器：Greenplum 6开始支持物化视图。系统表中没有记录物化视图最近一次刷新的时间，
+ *  这里只统计每个数据库中物化视图的个数以及尚未填充数据(创建时使用WITH NO DATA且未刷新)的个数
+ */
+
+const (
+	matviewCountSql = `SELECT count(*), count(*) filter(where not ispopulated) from pg_matviews;`
+)
+
+var (
+	matviewCountDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "matview_count"),
+		"Number of materialized views in each database",
+		[]string{"dbname"}, nil,
+	)
+
+	matviewsNotPopulatedDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "matviews_not_populated"),
+		"Number of materialized views in each database that have never been refreshed since created WITH NO DATA",
+		[]string{"dbname"}, nil,
+	)
+)
+
+func NewMatviewsScraper(conns *ConnectionCache) Scraper {
+	return matviewsScraper{conns: conns}
+}
+
+type matviewsScraper struct {
+	conns *ConnectionCache
+}
+
+func (matviewsScraper) VersionRange() (min, max int) {
+	return 6, 0
+}
+
+func (matviewsScraper) Name() string {
+	return "matviews_scraper"
+}
+
+func (s matviewsScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	if ver < 6 {
+		return nil
+	}
+
+	return forEachDatabase(ctx, db, s.conns, ch, func(ctx context.Context, conn *sql.DB, dbname string, ch chan<- prometheus.Metric) error {
+		var count, notPopulated float64
+		if err := conn.QueryRowContext(ctx, matviewCountSql).Scan(&count, &notPopulated); err != nil {
+			return err
+		}
+
+		ch <- prometheus.MustNewConstMetric(matviewCountDesc, prometheus.GaugeValue, count, dbname)
+		ch <- prometheus.MustNewConstMetric(matviewsNotPopulatedDesc, prometheus.GaugeValue, notPopulated, dbname)
+
+		return nil
+	})
+}
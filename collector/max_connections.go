@@ -1,11 +1,11 @@
 package collector
 
 import (
-	"database/sql"
+	"context"
 	"errors"
 	"fmt"
 	"github.com/prometheus/client_golang/prometheus"
-	logger "github.com/prometheus/common/log"
+	logger "greenplum-exporter/logging"
 )
 
 /**
@@ -15,14 +15,21 @@ import (
 const (
 	maxConnectionsSql = `show max_connections`
 	suReservedSql     = `show superuser_reserved_connections`
+	totalBackendsSql  = `select count(*) from pg_stat_activity`
 )
 
 var (
-	maxConnDesc = prometheus.NewDesc(
+	maxConnDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemCluster, "max_connections"),
 		"Max connection of greenPlum cluster",
 		nil, nil,
 	)
+
+	connUtilizationDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "connection_utilization_percent"),
+		"Percent of current backends to max_connections of greenPlum coordinator",
+		nil, nil,
+	)
 )
 
 func NewMaxConnScraper() Scraper {
@@ -35,14 +42,14 @@ func (maxConnScraper) Name() string {
 	return "max_connection_scraper"
 }
 
-func (maxConnScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric, ver int) error {
-	maxConn, err := showConnections(db, maxConnectionsSql)
+func (maxConnScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	maxConn, err := showConnections(ctx, db, maxConnectionsSql)
 
 	if err != nil {
 		return err
 	}
 
-	reserved, err := showConnections(db, suReservedSql)
+	reserved, err := showConnections(ctx, db, suReservedSql)
 
 	if err != nil {
 		logger.Warn(err.Error())
@@ -51,12 +58,22 @@ func (maxConnScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric, ver int) e
 	//这里的最大连接数应为max_connections减去superuser_reserved_connections
 	ch <- prometheus.MustNewConstMetric(maxConnDesc, prometheus.GaugeValue, maxConn-reserved)
 
+	total, err := showConnections(ctx, db, totalBackendsSql)
+
+	if err != nil {
+		return err
+	}
+
+	if maxConn > 0 {
+		ch <- prometheus.MustNewConstMetric(connUtilizationDesc, prometheus.GaugeValue, total*100/maxConn)
+	}
+
 	return nil
 }
 
-func showConnections(db *sql.DB, sql string) (conn float64, err error) {
-	rows, err := db.Query(sql)
-	logger.Infof("Query Database: %s",sql)
+func showConnections(ctx context.Context, db Queryer, sql string) (conn float64, err error) {
+	rows, err := db.QueryContext(ctx, sql)
+	logger.Debugf("Query Database: %s",sql)
 
 	if err != nil {
 		return
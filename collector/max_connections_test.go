@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMaxConnScraper(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta(maxConnectionsSql)).WillReturnRows(sqlmock.NewRows([]string{"max_connections"}).AddRow("250"))
+	mock.ExpectQuery(regexp.QuoteMeta(suReservedSql)).WillReturnRows(sqlmock.NewRows([]string{"superuser_reserved_connections"}).AddRow("10"))
+	mock.ExpectQuery(regexp.QuoteMeta(totalBackendsSql)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(50))
+
+	expected := `
+# HELP greenplum_cluster_max_connections Max connection of greenPlum cluster
+# TYPE greenplum_cluster_max_connections gauge
+greenplum_cluster_max_connections 240
+# HELP greenplum_server_connection_utilization_percent Percent of current backends to max_connections of greenPlum coordinator
+# TYPE greenplum_server_connection_utilization_percent gauge
+greenplum_server_connection_utilization_percent 20
+`
+	if err := collectAndCompare(t, NewMaxConnScraper(), db, 6, expected); err != nil {
+		t.Errorf("unexpected scrape error: %v", err)
+	}
+}
+
+func TestMaxConnScraperErrors(t *testing.T) {
+	t.Run("max_connections_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(maxConnectionsSql)).WillReturnError(errors.New("connection reset"))
+
+		if err := collectAndCompare(t, NewMaxConnScraper(), db, 6, ""); err == nil {
+			t.Error("expected the query error")
+		}
+	})
+
+	t.Run("max_connections_empty", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(maxConnectionsSql)).WillReturnRows(sqlmock.NewRows([]string{"max_connections"}))
+
+		if err := collectAndCompare(t, NewMaxConnScraper(), db, 6, ""); err == nil {
+			t.Error("expected a not found error")
+		}
+	})
+
+	// 获取superuser_reserved_connections失败时只记录警告，最大连接数按不保留计算
+	t.Run("reserved_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(maxConnectionsSql)).WillReturnRows(sqlmock.NewRows([]string{"max_connections"}).AddRow("250"))
+		mock.ExpectQuery(regexp.QuoteMeta(suReservedSql)).WillReturnError(errors.New("permission denied"))
+		mock.ExpectQuery(regexp.QuoteMeta(totalBackendsSql)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(25))
+
+		expected := `
+# HELP greenplum_cluster_max_connections Max connection of greenPlum cluster
+# TYPE greenplum_cluster_max_connections gauge
+greenplum_cluster_max_connections 250
+`
+		if err := collectAndCompare(t, NewMaxConnScraper(), db, 6, expected, "greenplum_cluster_max_connections"); err != nil {
+			t.Errorf("unexpected scrape error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package collector
+
+import (
+	"os"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/**
+ *  按指标全名(如greenplum_server_database_table_bloat_list)过滤抓取器输出的指标
+ */
+
+const (
+	metricAllowlistEnv = "GPDB_METRIC_ALLOWLIST"
+	metricBlocklistEnv = "GPDB_METRIC_BLOCKLIST"
+)
+
+// 各指标描述对应的指标全名，由newDesc在包初始化时登记，之后只读
+var descNames = make(map[*prometheus.Desc]string)
+
+type metricFilter struct {
+	allow map[string]bool
+	block map[string]bool
+}
+
+/**
+* 函数：newDesc
+* 功能：创建指标描述并登记其指标全名，供过滤器按名称判断；只能在包级变量初始化时调用
+ */
+func newDesc(fqName, help string, variableLabels []string, constLabels prometheus.Labels) *prometheus.Desc {
+	desc := prometheus.NewDesc(fqName, help, variableLabels, constLabels)
+	descNames[desc] = fqName
+
+	return desc
+}
+
+/**
+* 函数：newMetricFilter
+* 功能：根据GPDB_METRIC_ALLOWLIST、GPDB_METRIC_BLOCKLIST(逗号分隔)创建指标过滤器
+ */
+func newMetricFilter() *metricFilter {
+	return &metricFilter{
+		allow: splitNames(os.Getenv(metricAllowlistEnv)),
+		block: splitNames(os.Getenv(metricBlocklistEnv)),
+	}
+}
+
+func splitNames(value string) map[string]bool {
+	names := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names[name] = true
+		}
+	}
+
+	return names
+}
+
+/**
+* 函数：allowed
+* 功能：判断指标是否允许输出，allowlist非空时只输出其中的指标，blocklist中的指标总是被丢弃
+ */
+func (f *metricFilter) allowed(m prometheus.Metric) bool {
+	if len(f.allow) == 0 && len(f.block) == 0 {
+		return true
+	}
+
+	name := descNames[m.Desc()]
+
+	if len(f.allow) > 0 && !f.allow[name] {
+		return false
+	}
+
+	return !f.block[name]
+}
+
+/**
+* 函数：wrap
+* 功能：包装指标通道，经过过滤后再转发到ch；使用完毕后需调用返回的函数等待转发结束，该函数返回转发的样本数
+ */
+func (f *metricFilter) wrap(ch chan<- prometheus.Metric) (chan<- prometheus.Metric, func() int) {
+	in := make(chan prometheus.Metric)
+	done := make(chan struct{})
+
+	forwarded := 0
+
+	go func() {
+		defer close(done)
+
+		for m := range in {
+			if f.allowed(m) {
+				ch <- m
+				forwarded++
+			}
+		}
+	}()
+
+	return in, func() int {
+		close(in)
+		<-done
+
+		return forwarded
+	}
+}
@@ -0,0 +1,37 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetricFilter(t *testing.T) {
+	up := prometheus.MustNewConstMetric(primarySegmentsUpDesc, prometheus.GaugeValue, 4)
+	skew := prometheus.MustNewConstMetric(segmentClockSkewDesc, prometheus.GaugeValue, 0.1)
+
+	for _, c := range []struct {
+		name      string
+		allowlist string
+		blocklist string
+		up, skew  bool
+	}{
+		{"no_filter", "", "", true, true},
+		{"allowlist", "greenplum_cluster_primary_segments_up", "", true, false},
+		{"blocklist", "", " greenplum_cluster_max_segment_clock_skew_seconds ,", true, false},
+		{"blocklist_wins", "greenplum_cluster_primary_segments_up", "greenplum_cluster_primary_segments_up", false, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			setEnv(t, metricAllowlistEnv, c.allowlist)
+			setEnv(t, metricBlocklistEnv, c.blocklist)
+
+			f := newMetricFilter()
+			if allowed := f.allowed(up); allowed != c.up {
+				t.Errorf("expected primary_segments_up allowed %v, got %v", c.up, allowed)
+			}
+			if allowed := f.allowed(skew); allowed != c.skew {
+				t.Errorf("expected max_segment_clock_skew_seconds allowed %v, got %v", c.skew, allowed)
+			}
+		})
+	}
+}
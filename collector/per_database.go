@@ -0,0 +1,226 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  按数据库循环执行查询的公共逻辑
+ */
+
+const (
+	// 单个抓取器按库循环的总时长上限(秒)，超时后跳过剩余的数据库，默认0不限制
+	databaseLoopTimeoutEnv = "GPDB_DATABASE_LOOP_TIMEOUT_SECONDS"
+	// 单个抓取器同时查询的数据库个数，默认4
+	databaseConcurrencyEnv = "GPDB_DATABASE_CONCURRENCY"
+
+	userDatabasesSql = `SELECT datname from pg_database where datallowconn and not datistemplate;`
+)
+
+var databaseLoopTimeout = time.Duration(envInt(databaseLoopTimeoutEnv, 0)) * time.Second
+
+var databaseConcurrency = envInt(databaseConcurrencyEnv, 4)
+
+// 按库循环超时后因跳过剩余数据库而不完整的抓取
+type incompleteError struct {
+	skipped int
+}
+
+func (e *incompleteError) Error() string {
+	return fmt.Sprintf("database loop deadline exceeded, %d databases skipped", e.skipped)
+}
+
+func (e *incompleteError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+/**
+* 函数：isIncomplete
+* 功能：判断(组合)错误中是否包含按库循环超时导致的不完整抓取
+ */
+func isIncomplete(err error) bool {
+	for _, e := range flattenErr(err) {
+		var incomplete *incompleteError
+		if errors.As(e, &incomplete) {
+			return true
+		}
+	}
+
+	return false
+}
+
+/**
+* 函数：newDatabaseLoopContext
+* 功能：基于抓取器的上下文创建按库循环使用的上下文，配置了GPDB_DATABASE_LOOP_TIMEOUT_SECONDS时带有超时
+ */
+func newDatabaseLoopContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if databaseLoopTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, databaseLoopTimeout)
+}
+
+/**
+* 函数：checkDatabaseLoop
+* 功能：按库循环或抓取器超时后返回incompleteError并记录跳过的数据库个数
+ */
+func checkDatabaseLoop(ctx context.Context, remaining int) error {
+	if ctx.Err() != context.DeadlineExceeded {
+		return nil
+	}
+
+	logger.Warnf("database loop deadline exceeded, skip remaining %d databases", remaining)
+
+	return &incompleteError{skipped: remaining}
+}
+
+/**
+* 函数：listDatabases
+* 功能：获取所有允许连接的用户数据库名称
+ */
+func listDatabases(ctx context.Context, db Queryer) ([]string, error) {
+	logger.Debugf("Query Database: %s", userDatabasesSql)
+	rows, err := db.QueryContext(ctx, userDatabasesSql)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	names := make([]string, 0)
+	for rows.Next() {
+		var dbname string
+		if err := rows.Scan(&dbname); err != nil {
+			return nil, err
+		}
+
+		names = append(names, dbname)
+	}
+
+	return names, rows.Err()
+}
+
+/**
+* 函数：forEachDatabase
+* 功能：获取每个用户数据库的连接并执行fn，最多同时查询GPDB_DATABASE_CONCURRENCY个数据库，单个数据库失败不影响其它数据库，
+*      fn使用按库循环的上下文，并通过传入的ch发送指标
+ */
+func forEachDatabase(ctx context.Context, db Queryer, conns *ConnectionCache, ch chan<- prometheus.Metric, fn func(ctx context.Context, conn *sql.DB, dbname string, ch chan<- prometheus.Metric) error) error {
+	names, err := listDatabases(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	conns.retain(names)
+
+	ctx, cancel := newDatabaseLoopContext(ctx)
+
+	defer cancel()
+
+	return runDatabases(ctx, names, ch, func(ctx context.Context, dbname string, ch chan<- prometheus.Metric) error {
+		conn, err := conns.get(ctx, dbname)
+		if err != nil {
+			return err
+		}
+
+		return fn(ctx, conn, dbname, ch)
+	})
+}
+
+/**
+* 函数：runDatabases
+* 功能：以有界的并发对names中的每个数据库执行fn，错误(包括fn的panic)加上数据库名称后合并返回；ctx超时后不再启动剩余的数据库，
+*      并返回incompleteError。fn通过传入的ch发送指标，ctx结束后丢弃未发送的指标，避免阻塞
+ */
+func runDatabases(ctx context.Context, names []string, ch chan<- prometheus.Metric, fn func(ctx context.Context, dbname string, ch chan<- prometheus.Metric) error) error {
+	concurrency := databaseConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	out, wait := guardChannel(ctx, ch)
+
+	defer wait()
+
+	var mu sync.Mutex
+	errs := make([]error, 0)
+
+	addErr := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		errs = append(errs, err)
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for i, dbname := range names {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+
+		if err := checkDatabaseLoop(ctx, len(names)-i); err != nil {
+			addErr(err)
+			break
+		}
+
+		// ctx因其它原因结束时占用的位置可能没有取得
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(dbname string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			// 单个数据库panic时作为该数据库的错误返回，不影响其它数据库和整个exporter
+			err := recoverScrape(func() error {
+				return fn(ctx, dbname, out)
+			})
+			if err != nil {
+				addErr(wrapErr(dbname, err))
+			}
+		}(dbname)
+	}
+
+	wg.Wait()
+
+	return combineErr(errs...)
+}
+
+/**
+* 函数：guardChannel
+* 功能：包装指标通道，ctx结束后丢弃指标而不是阻塞发送方；调用返回的函数等待转发结束
+ */
+func guardChannel(ctx context.Context, ch chan<- prometheus.Metric) (chan<- prometheus.Metric, func()) {
+	in := make(chan prometheus.Metric)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for m := range in {
+			select {
+			case ch <- m:
+			case <-ctx.Done():
+			}
+		}
+	}()
+
+	return in, func() {
+		close(in)
+		<-done
+	}
+}
@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  依赖检查抓取器：部分指标依赖gp_toolkit、session_state模式和gpperfmon数据库，缺失时这些指标不会输出
+ */
+
+const (
+	prerequisitesSql = `
+		SELECT s.name, exists(SELECT 1 from pg_namespace where nspname = s.name)
+		FROM (values ('gp_toolkit'), ('session_state')) s(name)
+		union all
+		SELECT 'gpperfmon', exists(SELECT 1 from pg_database where datname = $1)
+	`
+)
+
+var (
+	extensionInstalledDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "extension_installed"),
+		"Whether the schema or database that some metrics depend on is installed",
+		[]string{"name"}, nil,
+	)
+)
+
+func NewPrerequisitesScraper() Scraper {
+	return prerequisitesScraper{}
+}
+
+type prerequisitesScraper struct{}
+
+func (prerequisitesScraper) Name() string {
+	return "prerequisites_scraper"
+}
+
+func (prerequisitesScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	logger.Debugf("Query Database: %s", prerequisitesSql)
+	rows, err := db.QueryContext(ctx, prerequisitesSql, gpperfmonDatabase())
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var name string
+		var installed bool
+
+		err = rows.Scan(&name, &installed)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		var value float64
+		if installed {
+			value = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(extensionInstalledDesc, prometheus.GaugeValue, value, name)
+	}
+
+	return combineErr(errs...)
+}
@@ -1,10 +1,11 @@
 package collector
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"github.com/prometheus/client_golang/prometheus"
-	logger "github.com/prometheus/common/log"
+	logger "greenplum-exporter/logging"
 )
 
 /**
@@ -17,19 +18,19 @@ const (
 )
 
 var (
-	totalQueriesDesc = prometheus.NewDesc(
+	totalQueriesDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemCluster, "total_queries"),
 		"The total number of queries in Greenplum Database at data collection time",
 		nil, nil,
 	)
 
-	runningQueriesDesc = prometheus.NewDesc(
+	runningQueriesDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemCluster, "running_queries"),
 		"The number of active queries running at data collection time",
 		nil, nil,
 	)
 
-	queuedQueriesDesc = prometheus.NewDesc(
+	queuedQueriesDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemCluster, "queued_queries"),
 		"The number of queries waiting in a resource group or resource queue",
 		nil, nil,
@@ -46,9 +47,9 @@ func (queriesScraper) Name() string {
 	return "queriesScraper"
 }
 
-func (queriesScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric, ver int) error {
-	rows, err := db.Query(queriesSql)
-	logger.Infof("Query Database: %s",queriesSql)
+func (queriesScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	rows, err := db.QueryContext(ctx, queriesSql)
+	logger.Debugf("Query Database: %s",queriesSql)
 
 	if err != nil {
 		return err
@@ -0,0 +1,98 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  运行中查询指纹抓取器：去掉查询中的注释，将字符串、数字常量替换为占位符并合并空白，统计不同查询形状的个数。
+ *  只输出个数，不输出每个指纹，基数固定为1。exporter自身的查询不计入
+ */
+
+var (
+	distinctQueryFingerprintsDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "distinct_running_query_fingerprints"),
+		"Number of distinct normalized query shapes among the currently running queries",
+		nil, nil,
+	)
+
+	fingerprintComments = regexp.MustCompile(`(?s)/\*.*?\*/|--[^\n]*`)
+	// 包括E''、$$...$$形式的字符串，字符串中的''为转义的单引号
+	fingerprintStrings = regexp.MustCompile(`(?s)(?:\b[eE])?'(?:[^']|'')*'|\$\$.*?\$\$`)
+	fingerprintNumbers = regexp.MustCompile(`\b\d+(?:\.\d+)?(?:[eE][-+]?\d+)?\b`)
+	fingerprintInLists = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	fingerprintSpaces  = regexp.MustCompile(`\s+`)
+	// 运算符和标点两侧的空白不影响查询结构
+	fingerprintPunct = regexp.MustCompile(`\s*([=<>!,;()+*/-])\s*`)
+)
+
+func NewQueryFingerprintsScraper() Scraper {
+	return queryFingerprintsScraper{}
+}
+
+type queryFingerprintsScraper struct{}
+
+func (queryFingerprintsScraper) Name() string {
+	return "query_fingerprints_scraper"
+}
+
+func (queryFingerprintsScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	// 与按标签统计活跃查询使用相同的查询
+	querySql := activeQueriesSql_V6
+	if ver < 6 {
+		querySql = activeQueriesSql_V5
+	}
+
+	logger.Debugf("Query Database: %s", querySql)
+	rows, err := db.QueryContext(ctx, querySql)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	fingerprints := make(map[string]struct{})
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var query sql.NullString
+
+		if err = rows.Scan(&query); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if fingerprint := fingerprintQuery(query.String); fingerprint != "" {
+			fingerprints[fingerprint] = struct{}{}
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	ch <- prometheus.MustNewConstMetric(distinctQueryFingerprintsDesc, prometheus.GaugeValue, float64(len(fingerprints)))
+
+	return combineErr(errs...)
+}
+
+/**
+* 函数：fingerprintQuery
+* 功能：规范化查询文本，常量不同但结构相同的查询得到相同的指纹
+ */
+func fingerprintQuery(query string) string {
+	query = fingerprintComments.ReplaceAllString(query, " ")
+	query = fingerprintStrings.ReplaceAllString(query, "?")
+	query = fingerprintNumbers.ReplaceAllString(query, "?")
+	query = fingerprintPunct.ReplaceAllString(query, "$1")
+	query = fingerprintInLists.ReplaceAllString(query, "(?)")
+	query = fingerprintSpaces.ReplaceAllString(query, " ")
+
+	return strings.ToLower(strings.TrimSpace(query))
+}
@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  SQL执行时长抓取器，统计gpperfmon中最近GPDB_QUERY_RUNTIME_WINDOW_SECONDS(默认60秒)内执行完成的查询，
+ *  包括整体的时长分布和每个数据库的平均时长
+ */
+
+const (
+	queryRuntimeWindowEnv = "GPDB_QUERY_RUNTIME_WINDOW_SECONDS"
+
+	queryRuntimeSql_V6 = `
+		SELECT count(*), coalesce(sum(d), 0), avg(d), max(d),
+			percentile_cont(0.5) within group (order by d),
+			percentile_cont(0.9) within group (order by d),
+			percentile_cont(0.99) within group (order by d)
+		FROM (
+			SELECT extract(epoch from tfinish - tstart) d FROM queries_history
+			WHERE tstart is not null AND tfinish >= now() - $1::int * interval '1 second'
+		) t
+	`
+	queryRuntimeSql_V5 = `
+		SELECT count(*), coalesce(sum(d), 0), avg(d), max(d), null, null, null
+		FROM (
+			SELECT extract(epoch from tfinish - tstart) d FROM queries_history
+			WHERE tstart is not null AND tfinish >= now() - $1::int * interval '1 second'
+		) t
+	`
+	// 窗口内没有执行完成的查询的数据库不输出
+	databaseAvgRuntimeSql = `
+		SELECT db, avg(extract(epoch from tfinish - tstart)) FROM queries_history
+		WHERE tstart is not null AND tfinish >= now() - $1::int * interval '1 second'
+		GROUP BY db
+	`
+)
+
+var (
+	queryRuntimeDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "query_runtime_seconds"),
+		"Runtime of the queries completed in the recent window from gpperfmon",
+		nil, nil,
+	)
+
+	queryAvgRuntimeDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "query_avg_runtime_seconds"),
+		"Average runtime of the queries completed in the recent window from gpperfmon",
+		nil, nil,
+	)
+
+	queryMaxRuntimeDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "query_max_runtime_seconds"),
+		"Max runtime of the queries completed in the recent window from gpperfmon",
+		nil, nil,
+	)
+
+	databaseAvgRuntimeDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_avg_query_seconds"),
+		"Average runtime of the queries of each database completed in the recent window from gpperfmon",
+		[]string{"datname"}, nil,
+	)
+)
+
+func NewQueryRuntimeScraper(conns *ConnectionCache) Scraper {
+	return queryRuntimeScraper{windowSeconds: envInt(queryRuntimeWindowEnv, 60), conns: conns}
+}
+
+type queryRuntimeScraper struct {
+	windowSeconds int
+	conns         *ConnectionCache
+}
+
+func (queryRuntimeScraper) Name() string {
+	return "query_runtime_scraper"
+}
+
+func (s queryRuntimeScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	conn, err := openGpperfmon(ctx, s.conns)
+	if err != nil {
+		return err
+	}
+
+	errR := s.scrapeQueryRuntime(ctx, conn, ch, ver)
+	errD := s.scrapeDatabaseAvgRuntime(ctx, conn, ch)
+
+	return combineErr(wrapErr("query_runtime", errR), wrapErr("database_avg_runtime", errD))
+}
+
+func (s queryRuntimeScraper) scrapeQueryRuntime(ctx context.Context, conn *sql.DB, ch chan<- prometheus.Metric, ver int) error {
+	querySql := queryRuntimeSql_V6
+	if ver < 6 {
+		querySql = queryRuntimeSql_V5
+	}
+
+	var count uint64
+	var sum float64
+	var avg, max, p50, p90, p99 sql.NullFloat64
+
+	logger.Debugf("Query Database: %s", querySql)
+	err := conn.QueryRowContext(ctx, querySql, s.windowSeconds).Scan(&count, &sum, &avg, &max, &p50, &p90, &p99)
+	if err != nil {
+		return err
+	}
+
+	if p50.Valid {
+		quantiles := map[float64]float64{0.5: p50.Float64, 0.9: p90.Float64, 0.99: p99.Float64}
+		ch <- prometheus.MustNewConstSummary(queryRuntimeDesc, count, sum, quantiles)
+	}
+
+	// 窗口内没有执行完成的查询时不输出平均值和最大值
+	if avg.Valid {
+		ch <- prometheus.MustNewConstMetric(queryAvgRuntimeDesc, prometheus.GaugeValue, avg.Float64)
+		ch <- prometheus.MustNewConstMetric(queryMaxRuntimeDesc, prometheus.GaugeValue, max.Float64)
+	}
+
+	return nil
+}
+
+func (s queryRuntimeScraper) scrapeDatabaseAvgRuntime(ctx context.Context, conn *sql.DB, ch chan<- prometheus.Metric) error {
+	logger.Debugf("Query Database: %s", databaseAvgRuntimeSql)
+	rows, err := conn.QueryContext(ctx, databaseAvgRuntimeSql, s.windowSeconds)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var datname string
+		var avg float64
+
+		if err = rows.Scan(&datname, &avg); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(databaseAvgRuntimeDesc, prometheus.GaugeValue, avg, datname)
+	}
+
+	return combineErr(errs...)
+}
@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  高slice查询抓取器：Greenplum没有直接提供运行中查询slice数的视图，每个slice在每个segment上对应一个
+ *  QE进程，这里用会话在单个segment上的QE进程数近似其执行计划的slice数，统计超过
+ *  GPDB_HIGH_SLICE_THRESHOLD(默认20)的运行中查询个数。slice过多的计划会占用大量gang和interconnect资源，
+ *  只支持Greenplum 6及以上版本
+ */
+
+const (
+	highSliceThresholdEnv = "GPDB_HIGH_SLICE_THRESHOLD"
+
+	highSliceQueriesSql = `SELECT count(distinct q.sess_id) from
+		(SELECT sess_id, gp_segment_id, count(*) as gangs from gp_dist_random('pg_stat_activity') GROUP BY sess_id, gp_segment_id) q
+		where q.gangs > $1
+		and q.sess_id in (SELECT sess_id from pg_stat_activity where state = 'active' and pid <> pg_backend_pid());`
+)
+
+var (
+	highSliceQueriesDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "active_queries_high_slice_count"),
+		"Number of running queries whose plan uses more slices than GPDB_HIGH_SLICE_THRESHOLD",
+		nil, nil,
+	)
+)
+
+func NewQuerySlicesScraper() Scraper {
+	return querySlicesScraper{threshold: envInt(highSliceThresholdEnv, 20)}
+}
+
+type querySlicesScraper struct {
+	threshold int
+}
+
+func (querySlicesScraper) VersionRange() (min, max int) {
+	return 6, 0
+}
+
+func (querySlicesScraper) Name() string {
+	return "query_slices_scraper"
+}
+
+func (s querySlicesScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	// 运行中查询按state = 'active'识别，Greenplum 5 的pg_stat_activity中没有state字段
+	if ver < 6 {
+		return nil
+	}
+
+	count, err := scrapeScalar(ctx, db, highSliceQueriesSql, s.threshold)
+	if err != nil {
+		if isUndefinedObject(err) {
+			logger.Warnf("skip high slice query metrics, error:%v", err)
+			return nil
+		}
+		return err
+	}
+
+	ch <- prometheus.MustNewConstMetric(highSliceQueriesDesc, prometheus.GaugeValue, count)
+
+	return nil
+}
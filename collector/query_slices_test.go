@@ -0,0 +1,31 @@
+package collector
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestQuerySlicesScraper(t *testing.T) {
+	setEnv(t, highSliceThresholdEnv, "")
+
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta(highSliceQueriesSql)).WithArgs(20).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	expected := `
+# HELP greenplum_server_active_queries_high_slice_count Number of running queries whose plan uses more slices than GPDB_HIGH_SLICE_THRESHOLD
+# TYPE greenplum_server_active_queries_high_slice_count gauge
+greenplum_server_active_queries_high_slice_count 0
+`
+	if err := collectAndCompare(t, NewQuerySlicesScraper(), db, 6, expected); err != nil {
+		t.Errorf("unexpected scrape error: %v", err)
+	}
+
+	// Greenplum 5 不查询，与VersionRange一致
+	db, _ = newMockDB(t)
+	if err := collectAndCompare(t, NewQuerySlicesScraper(), db, 5, ""); err != nil {
+		t.Errorf("unexpected scrape error of version 5: %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  按查询标签统计活跃查询抓取器：从查询的注释中解析GPDB_QUERY_TAG_KEY指定的键(如team=analytics)，
+ *  未设置该环境变量时不抓取。标签值只保留字母、数字、下划线、点和中划线，不同标签值的个数超过
+ *  GPDB_QUERY_TAG_MAX_VALUES(默认20)后其余的计入other，没有标签的查询计入untagged
+ */
+
+const (
+	queryTagKeyEnv       = "GPDB_QUERY_TAG_KEY"
+	queryTagMaxValuesEnv = "GPDB_QUERY_TAG_MAX_VALUES"
+
+	queryTagUntagged = "untagged"
+	queryTagOther    = "other"
+	queryTagMaxLen   = 64
+
+	activeQueriesSql_V6 = `select query from pg_stat_activity where pid <> pg_backend_pid() and state <> 'idle';`
+	activeQueriesSql_V5 = `select current_query from pg_stat_activity where procpid <> pg_backend_pid() and current_query <> '<IDLE>';`
+)
+
+var (
+	activeQueriesByTagDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "active_queries_by_tag"),
+		"Number of active queries grouped by the value of the GPDB_QUERY_TAG_KEY key in their comments",
+		[]string{"tag"}, nil,
+	)
+
+	commentPattern  = regexp.MustCompile(`(?s)/\*(.*?)\*/`)
+	invalidTagChars = regexp.MustCompile(`[^A-Za-z0-9_.\-]`)
+)
+
+func NewQueryTagScraper() Scraper {
+	key := strings.TrimSpace(os.Getenv(queryTagKeyEnv))
+
+	var pattern *regexp.Regexp
+	if key != "" {
+		pattern = regexp.MustCompile(fmt.Sprintf(`(?:^|[\s,;])%s\s*[=:]\s*['"]?([^\s,;'"]+)`, regexp.QuoteMeta(key)))
+	}
+
+	return queryTagScraper{
+		pattern:   pattern,
+		maxValues: envInt(queryTagMaxValuesEnv, 20),
+	}
+}
+
+type queryTagScraper struct {
+	pattern   *regexp.Regexp
+	maxValues int
+}
+
+func (queryTagScraper) Name() string {
+	return "query_tag_scraper"
+}
+
+func (s queryTagScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	if s.pattern == nil {
+		return nil
+	}
+
+	querySql := activeQueriesSql_V6
+	if ver < 6 {
+		querySql = activeQueriesSql_V5
+	}
+
+	logger.Debugf("Query Database: %s", querySql)
+	rows, err := db.QueryContext(ctx, querySql)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	counts := map[string]float64{queryTagUntagged: 0}
+	distinct := 0
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var query sql.NullString
+
+		if err = rows.Scan(&query); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		tag := s.parseTag(query.String)
+		if _, ok := counts[tag]; !ok && tag != queryTagUntagged {
+			if distinct >= s.maxValues {
+				tag = queryTagOther
+			} else {
+				distinct++
+			}
+		}
+
+		counts[tag]++
+	}
+
+	if err = rows.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	for tag, count := range counts {
+		ch <- prometheus.MustNewConstMetric(activeQueriesByTagDesc, prometheus.GaugeValue, count, tag)
+	}
+
+	return combineErr(errs...)
+}
+
+/**
+* 函数：parseTag
+* 功能：从查询的注释中解析标签值并清理非法字符，没有标签时返回untagged
+ */
+func (s queryTagScraper) parseTag(query string) string {
+	for _, comment := range commentPattern.FindAllStringSubmatch(query, -1) {
+		match := s.pattern.FindStringSubmatch(comment[1])
+		if match == nil {
+			continue
+		}
+
+		tag := invalidTagChars.ReplaceAllString(match[1], "_")
+		if len(tag) > queryTagMaxLen {
+			tag = tag[:queryTagMaxLen]
+		}
+
+		if tag != "" {
+			return tag
+		}
+	}
+
+	return queryTagUntagged
+}
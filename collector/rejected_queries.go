@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  资源限制拒绝查询抓取器：Greenplum没有统计因资源限制被拒绝或取消的查询个数的视图，这里通过gp_toolkit
+ *  统计最近GPDB_LOG_REJECTED_QUERIES_WINDOW_MINUTES(默认60，最大1440)分钟内
+ *  master日志中ERROR/FATAL级别的日志，按错误信息归类为内存(memory)、并发/连接数(concurrency)和
+ *  排队超时(queue_timeout)，用户主动取消的查询不计入。与全局死锁一样以Gauge输出时间窗口内的条数
+ */
+
+const (
+	rejectedQueriesWindowEnv = "GPDB_LOG_REJECTED_QUERIES_WINDOW_MINUTES"
+
+	rejectedQueriesSql = `SELECT reason, count(*) from (
+		SELECT case
+			when logmessage ilike '%out of memory%' or logmessage ilike '%vmem%' or logmessage ilike '%vm protect%' then 'memory'
+			when logmessage ilike '%too many clients%' or logmessage ilike '%connection slots are reserved%'
+				or logmessage ilike '%too many connections%' then 'concurrency'
+			when logmessage ilike '%queu%timeout%' or logmessage ilike '%queu%timed out%' then 'queue_timeout'
+			end as reason
+		from gp_toolkit.gp_log_master_concise
+		where logtime > now() - $1::int * interval '1 minute' and logseverity in ('ERROR', 'FATAL')) t
+		where reason is not null GROUP BY reason;`
+)
+
+var (
+	queriesRejectedDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "queries_rejected_recent"),
+		"Number of queries or connections rejected due to memory, concurrency or queuing limits in the master logs within GPDB_LOG_REJECTED_QUERIES_WINDOW_MINUTES",
+		[]string{"reason"}, nil,
+	)
+
+	rejectedReasons = []string{"memory", "concurrency", "queue_timeout"}
+)
+
+func NewRejectedQueriesScraper() Scraper {
+	return rejectedQueriesScraper{windowMinutes: logWindowMinutes(rejectedQueriesWindowEnv)}
+}
+
+type rejectedQueriesScraper struct {
+	windowMinutes int
+}
+
+// 需要读取master上的全部日志文件，耗时较长
+func (rejectedQueriesScraper) Timeout() time.Duration {
+	return time.Second * 10
+}
+
+func (rejectedQueriesScraper) Name() string {
+	return "rejected_queries_scraper"
+}
+
+func (s rejectedQueriesScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	logger.Debugf("Query Database: %s", rejectedQueriesSql)
+	rows, err := db.QueryContext(ctx, rejectedQueriesSql, s.windowMinutes)
+
+	if err != nil {
+		// gp_toolkit未安装时跳过
+		if isUndefinedObject(err) {
+			logger.Warnf("skip rejected queries metrics, error:%v", err)
+			return nil
+		}
+		return err
+	}
+
+	defer rows.Close()
+
+	counts := make(map[string]float64, len(rejectedReasons))
+	for _, reason := range rejectedReasons {
+		counts[reason] = 0
+	}
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var reason string
+		var count float64
+
+		if err = rows.Scan(&reason, &count); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		counts[reason] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	for reason, count := range counts {
+		ch <- prometheus.MustNewConstMetric(queriesRejectedDesc, prometheus.GaugeValue, count, reason)
+	}
+
+	return combineErr(errs...)
+}
@@ -0,0 +1,28 @@
+package collector
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// 没有被拒绝的查询的原因输出0，查询按时间窗口绑定参数
+func TestRejectedQueriesScraper(t *testing.T) {
+	setEnv(t, rejectedQueriesWindowEnv, "")
+
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta(rejectedQueriesSql)).WithArgs(defaultLogWindowMinutes).
+		WillReturnRows(sqlmock.NewRows([]string{"reason", "count"}).AddRow("memory", 3))
+
+	expected := `
+# HELP greenplum_server_queries_rejected_recent Number of queries or connections rejected due to memory, concurrency or queuing limits in the master logs within GPDB_LOG_REJECTED_QUERIES_WINDOW_MINUTES
+# TYPE greenplum_server_queries_rejected_recent gauge
+greenplum_server_queries_rejected_recent{reason="concurrency"} 0
+greenplum_server_queries_rejected_recent{reason="memory"} 3
+greenplum_server_queries_rejected_recent{reason="queue_timeout"} 0
+`
+	if err := collectAndCompare(t, NewRejectedQueriesScraper(), db, 6, expected); err != nil {
+		t.Errorf("unexpected scrape error: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  关系大小抓取器：每个用户数据库中pg_relation_size最大的--relations.top-n(默认20)个表、索引和TOAST表，
+ *  用于定位数据库大小增长的来源。每个关系单独统计，表的大小不含其索引和TOAST表；需要计算每个关系的大小，开销较大，默认不开启
+ */
+
+const (
+	relationSizeSql_V6 = `
+		SELECT n.nspname, c.relname,
+			case c.relkind when 'i' then 'index' when 't' then 'toast' else 'table' end,
+			pg_relation_size(c.oid)
+		FROM pg_class c
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind in ('r', 'i', 't') AND c.relstorage <> 'x'
+			AND n.nspname not in ('pg_catalog', 'information_schema', 'gp_toolkit', 'pg_aoseg', 'pg_bitmapindex')
+		ORDER BY 4 DESC
+		LIMIT $1
+	`
+	// Greenplum 7 的pg_class中没有relstorage字段，外部表的relkind为f，物化视图按表统计
+	relationSizeSql_V7 = `
+		SELECT n.nspname, c.relname,
+			case c.relkind when 'i' then 'index' when 't' then 'toast' else 'table' end,
+			pg_relation_size(c.oid)
+		FROM pg_class c
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind in ('r', 'm', 'i', 't')
+			AND n.nspname not in ('pg_catalog', 'information_schema', 'gp_toolkit', 'pg_aoseg', 'pg_bitmapindex')
+		ORDER BY 4 DESC
+		LIMIT $1
+	`
+)
+
+var relationSizeSqls = versionedSql{5: relationSizeSql_V6, 7: relationSizeSql_V7}
+
+// 启动时由SetRelationsTopN设置
+var relationsTopN = 20
+
+var (
+	relationSizeDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "relation_size_bytes"),
+		"Size in bytes of the largest tables, indexes and toast tables in each database",
+		[]string{"dbname", "schema", "relation", "kind"}, nil,
+	)
+)
+
+/**
+* 函数：SetRelationsTopN
+* 功能：设置每个数据库输出大小的关系个数，需在开始抓取之前调用
+ */
+func SetRelationsTopN(n int) {
+	relationsTopN = n
+}
+
+func NewRelationSizeScraper(conns *ConnectionCache) Scraper {
+	return relationSizeScraper{conns: conns}
+}
+
+type relationSizeScraper struct {
+	conns *ConnectionCache
+}
+
+func (relationSizeScraper) Name() string {
+	return "relation_size_scraper"
+}
+
+func (s relationSizeScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	querySql := relationSizeSqls.forVersion(ver)
+
+	return forEachDatabase(ctx, db, s.conns, ch, func(ctx context.Context, conn *sql.DB, dbname string, ch chan<- prometheus.Metric) error {
+		logger.Debugf("Query Database: %s", querySql)
+		rows, err := conn.QueryContext(ctx, querySql, relationsTopN)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		errs := make([]error, 0)
+
+		for rows.Next() {
+			var schema, relation, kind string
+			var size float64
+
+			if err := rows.Scan(&schema, &relation, &kind, &size); err != nil {
+				errs = append(errs, rowErr("relation_size", err, "dbname", dbname, "schema", schema, "relation", relation))
+				continue
+			}
+
+			ch <- prometheus.MustNewConstMetric(relationSizeDesc, prometheus.GaugeValue, size, dbname, schema, relation, kind)
+		}
+
+		if err = rows.Err(); err != nil {
+			errs = append(errs, err)
+		}
+
+		return combineErr(errs...)
+	})
+}
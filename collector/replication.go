@@ -0,0 +1,240 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  流复制延迟抓取器（Greenplum 6及以上版本），延迟超过GPDB_REPLICATION_LAG_BYTES时告警指标为1；
+ *  以及standby coordinator已接收但尚未回放的WAL字节数、各mirror segment相对primary的复制延迟和mirror的状态。
+ *  没有配置mirror的集群不输出mirror相关指标
+ */
+
+const (
+	replicationLagBytesEnv = "GPDB_REPLICATION_LAG_BYTES"
+
+	replicationLagSql_V6 = `select application_name, pg_xlog_location_diff(pg_current_xlog_location(), replay_location) lag_bytes
+                            from pg_stat_replication;`
+	replicationLagSql_V7 = `select application_name, pg_wal_lsn_diff(pg_current_wal_lsn(), replay_lsn) lag_bytes
+                            from pg_stat_replication;`
+	// 连接到standby时使用本地接收和回放的位置，连接到coordinator时使用standby(gp_walreceiver)上报的位置，没有standby时返回null
+	standbyReplayLagSql_V6 = `select case when pg_is_in_recovery()
+                                  then pg_xlog_location_diff(pg_last_xlog_receive_location(), pg_last_xlog_replay_location())
+                                  else (select pg_xlog_location_diff(flush_location, replay_location) from pg_stat_replication
+                                        where application_name = 'gp_walreceiver' limit 1) end;`
+	standbyReplayLagSql_V7 = `select case when pg_is_in_recovery()
+                                  then pg_wal_lsn_diff(pg_last_wal_receive_lsn(), pg_last_wal_replay_lsn())
+                                  else (select pg_wal_lsn_diff(flush_lsn, replay_lsn) from pg_stat_replication
+                                        where application_name = 'gp_walreceiver' limit 1) end;`
+	// gp_stat_replication汇总各primary segment上的pg_stat_replication，walsender未运行时state为空，hostname为对应mirror所在的主机
+	segmentReplicationLagSql_V6 = `select r.gp_segment_id, c.hostname, coalesce(r.state, 'unknown'), pg_xlog_location_diff(r.sent_location, r.replay_location)
+                                   from gp_stat_replication r join gp_segment_configuration c on c.content = r.gp_segment_id and c.role = 'm'
+                                   where r.gp_segment_id >= 0;`
+	segmentReplicationLagSql_V7 = `select r.gp_segment_id, c.hostname, coalesce(r.state, 'unknown'), pg_wal_lsn_diff(r.sent_lsn, r.replay_lsn)
+                                   from gp_stat_replication r join gp_segment_configuration c on c.content = r.gp_segment_id and c.role = 'm'
+                                   where r.gp_segment_id >= 0;`
+	mirrorUpSql = `select content, hostname, case when status = 'u' then 1 else 0 end from gp_segment_configuration
+                   where role = 'm' and content >= 0;`
+)
+
+var (
+	replicationLagBytesDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "replication_lag_bytes"),
+		"Bytes of WAL not yet replayed by the standby",
+		[]string{"application_name"}, nil,
+	)
+
+	replicationLagOverThresholdDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "replication_lag_over_threshold"),
+		"Whether the replay lag of the standby exceeds GPDB_REPLICATION_LAG_BYTES",
+		[]string{"application_name"}, nil,
+	)
+
+	segmentReplicationLagDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "segment_replication_lag_bytes"),
+		"Bytes of WAL sent by each primary segment but not yet replayed by its mirror",
+		[]string{"content", "hostname", "state"}, nil,
+	)
+
+	mirrorUpDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "segment_mirror_up"),
+		"Whether each mirror segment is marked up in gp_segment_configuration",
+		[]string{"content", "hostname"}, nil,
+	)
+
+	standbyReplayLagDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "standby_replay_lag_bytes"),
+		"Bytes of WAL received by the standby coordinator but not yet replayed",
+		nil, nil,
+	)
+)
+
+func NewReplicationScraper() Scraper {
+	return replicationScraper{lagThreshold: envFloat(replicationLagBytesEnv, 100*1024*1024)}
+}
+
+type replicationScraper struct {
+	lagThreshold float64
+}
+
+func (replicationScraper) VersionRange() (min, max int) {
+	return 6, 0
+}
+
+func (replicationScraper) Name() string {
+	return "replication_scraper"
+}
+
+func (s replicationScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	// Greenplum 5 没有计算WAL位置差值的函数
+	if ver < 6 {
+		return nil
+	}
+
+	errL := s.scrapeReplicationLag(ctx, db, ch, ver)
+	errS := scrapeStandbyReplayLag(ctx, db, ch, ver)
+
+	errM := scrapeSegmentReplicationLag(ctx, db, ch, ver)
+	errU := scrapeMirrorUp(ctx, db, ch)
+
+	return combineErr(
+		wrapErr("replication_lag", errL),
+		wrapErr("standby_replay_lag", errS),
+		wrapErr("segment_replication_lag", errM),
+		wrapErr("segment_mirror_up", errU),
+	)
+}
+
+func (s replicationScraper) scrapeReplicationLag(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	querySql := replicationLagSql_V7
+	if ver < 7 {
+		querySql = replicationLagSql_V6
+	}
+
+	rows, err := db.QueryContext(ctx, querySql)
+	logger.Debugf("Query Database: %s", querySql)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var applicationName string
+		var lag sql.NullFloat64
+
+		err = rows.Scan(&applicationName, &lag)
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		// standby尚未回放任何WAL时replay_location为空
+		if !lag.Valid {
+			continue
+		}
+
+		var overThreshold float64
+		if lag.Float64 > s.lagThreshold {
+			overThreshold = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(replicationLagBytesDesc, prometheus.GaugeValue, lag.Float64, applicationName)
+		ch <- prometheus.MustNewConstMetric(replicationLagOverThresholdDesc, prometheus.GaugeValue, overThreshold, applicationName)
+	}
+
+	return combineErr(errs...)
+}
+
+func scrapeStandbyReplayLag(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	querySql := standbyReplayLagSql_V7
+	if ver < 7 {
+		querySql = standbyReplayLagSql_V6
+	}
+
+	return skipScalarNull(scrapeScalarGauge(ctx, db, ch, standbyReplayLagDesc, querySql))
+}
+
+func scrapeSegmentReplicationLag(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	querySql := segmentReplicationLagSql_V7
+	if ver < 7 {
+		querySql = segmentReplicationLagSql_V6
+	}
+
+	logger.Debugf("Query Database: %s", querySql)
+	rows, err := db.QueryContext(ctx, querySql)
+
+	if err != nil {
+		// 较早的Greenplum 6版本没有gp_stat_replication视图
+		if isUndefinedObject(err) {
+			logger.Warnf("skip segment replication lag metrics, error:%v", err)
+			return nil
+		}
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var content, hostname, state string
+		var lag sql.NullFloat64
+
+		if err = rows.Scan(&content, &hostname, &state, &lag); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		// mirror尚未回放任何WAL时replay_location为空
+		if !lag.Valid {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(segmentReplicationLagDesc, prometheus.GaugeValue, lag.Float64, content, hostname, state)
+	}
+
+	if err = rows.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return combineErr(errs...)
+}
+
+func scrapeMirrorUp(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	logger.Debugf("Query Database: %s", mirrorUpSql)
+	rows, err := db.QueryContext(ctx, mirrorUpSql)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var content, hostname string
+		var up float64
+
+		if err = rows.Scan(&content, &hostname, &up); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(mirrorUpDesc, prometheus.GaugeValue, up, content, hostname)
+	}
+
+	if err = rows.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return combineErr(errs...)
+}
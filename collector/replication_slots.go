@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  复制槽状态抓取器（Greenplum 6及以上版本）
+ */
+
+const (
+	replicationSlotsSql_V6 = `select slot_name, active, pg_xlog_location_diff(pg_current_xlog_location(), restart_lsn) retained_bytes
+                              from pg_replication_slots;`
+	replicationSlotsSql_V7 = `select slot_name, active, pg_wal_lsn_diff(pg_current_wal_lsn(), restart_lsn) retained_bytes
+                              from pg_replication_slots;`
+)
+
+var (
+	replicationSlotActiveDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "replication_slot_active"),
+		"Whether the replication slot is currently being used",
+		[]string{"slot_name"}, nil,
+	)
+
+	replicationSlotRetainedDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "replication_slot_retained_bytes"),
+		"Bytes of WAL retained by the replication slot",
+		[]string{"slot_name"}, nil,
+	)
+)
+
+func NewReplicationSlotsScraper() Scraper {
+	return replicationSlotsScraper{}
+}
+
+type replicationSlotsScraper struct{}
+
+func (replicationSlotsScraper) VersionRange() (min, max int) {
+	return 6, 0
+}
+
+func (replicationSlotsScraper) Name() string {
+	return "replication_slots_scraper"
+}
+
+func (replicationSlotsScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	// Greenplum 5 不支持复制槽
+	if ver < 6 {
+		return nil
+	}
+
+	querySql := replicationSlotsSql_V7
+	if ver < 7 {
+		querySql = replicationSlotsSql_V6
+	}
+
+	rows, err := db.QueryContext(ctx, querySql)
+	logger.Debugf("Query Database: %s", querySql)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var slotName string
+		var active bool
+		var retained sql.NullFloat64
+
+		err = rows.Scan(&slotName, &active, &retained)
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		var activeValue float64
+		if active {
+			activeValue = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(replicationSlotActiveDesc, prometheus.GaugeValue, activeValue, slotName)
+
+		if retained.Valid {
+			ch <- prometheus.MustNewConstMetric(replicationSlotRetainedDesc, prometheus.GaugeValue, retained.Float64, slotName)
+		}
+	}
+
+	return combineErr(errs...)
+}
@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestReplicationScraperErrors(t *testing.T) {
+	setEnv(t, replicationLagBytesEnv, "")
+
+	t.Run("query_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectFailedQueries(mock, replicationLagSql_V7, standbyReplayLagSql_V7, segmentReplicationLagSql_V7, mirrorUpSql)
+
+		err := collectAndCompare(t, NewReplicationScraper(), db, 7, "")
+		expectErrLabels(t, err, "replication_lag", "standby_replay_lag", "segment_replication_lag", "segment_mirror_up")
+	})
+
+	// 出错的行被跳过，其余行照常输出
+	t.Run("scan_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(replicationLagSql_V6)).WillReturnRows(sqlmock.NewRows(
+			[]string{"application_name", "lag_bytes"}).
+			AddRow("gp_walreceiver", "abc").
+			AddRow("standby2", 1024))
+		mock.ExpectQuery(regexp.QuoteMeta(standbyReplayLagSql_V6)).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow("abc"))
+		mock.ExpectQuery(regexp.QuoteMeta(segmentReplicationLagSql_V6)).WillReturnRows(sqlmock.NewRows(
+			[]string{"gp_segment_id", "hostname", "state", "lag"}).AddRow("0", "sdw2", "streaming", "abc"))
+		mock.ExpectQuery(regexp.QuoteMeta(mirrorUpSql)).WillReturnRows(sqlmock.NewRows(
+			[]string{"content", "hostname", "up"}).AddRow("0", "sdw2", "abc"))
+
+		expected := `
+# HELP greenplum_server_replication_lag_bytes Bytes of WAL not yet replayed by the standby
+# TYPE greenplum_server_replication_lag_bytes gauge
+greenplum_server_replication_lag_bytes{application_name="standby2"} 1024
+`
+		err := collectAndCompare(t, NewReplicationScraper(), db, 6, expected, "greenplum_server_replication_lag_bytes",
+			"greenplum_server_standby_replay_lag_bytes", "greenplum_server_segment_replication_lag_bytes", "greenplum_server_segment_mirror_up")
+		expectErrLabels(t, err, "replication_lag", "standby_replay_lag", "segment_replication_lag", "segment_mirror_up")
+	})
+
+	// 没有standby和mirror时不输出，也不报错
+	t.Run("empty", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectEmptyQueries(mock, replicationLagSql_V6)
+		mock.ExpectQuery(regexp.QuoteMeta(standbyReplayLagSql_V6)).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(nil))
+		expectEmptyQueries(mock, segmentReplicationLagSql_V6, mirrorUpSql)
+
+		if err := collectAndCompare(t, NewReplicationScraper(), db, 6, ""); err != nil {
+			t.Errorf("unexpected scrape error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,374 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  资源组(Resource Group)及资源队列(Resource Queue)抓取器：按gp_resource_manager判断集群启用的资源管理方式，
+ *  启用资源组时(Greenplum 6及以上)输出各资源组的并发、排队、CPU和内存使用情况，启用资源队列时输出各资源队列的
+ *  活动语句数、等待语句数和内存使用情况，另一种方式的指标不输出；未启用资源管理或视图没有数据时不输出
+ */
+
+const (
+	// 未给角色指定资源组时，普通角色使用default_group，超级用户使用admin_group
+	defaultResgroupName = "default_group"
+
+	resgroupMemoryUsedSql = `
+		SELECT s.rsgname, max(s.memory_used * 100.0 / nullif(s.memory_used + s.memory_available, 0))
+		FROM gp_toolkit.gp_resgroup_status_per_host s
+			JOIN gp_toolkit.gp_resgroup_config c ON c.groupid = s.groupid
+		WHERE c.memory_limit::int > 0
+		GROUP BY s.rsgname
+	`
+	// cpu为资源组在该主机上的CPU使用百分比，取各主机中的最大值
+	resgroupCpuUsageSql_V6 = `SELECT rsgname, max(cpu) FROM gp_toolkit.gp_resgroup_status_per_host GROUP BY rsgname`
+	// Greenplum 7 的字段改名为cpu_usage
+	resgroupCpuUsageSql_V7 = `SELECT rsgname, max(cpu_usage) FROM gp_toolkit.gp_resgroup_status_per_host GROUP BY rsgname`
+	// total_queue_duration为自集群启动以来的累计排队时长
+	resgroupStatusSql = `
+		SELECT c.groupname, c.concurrency::int, s.num_running, s.num_queueing, extract(epoch from s.total_queue_duration)
+		FROM gp_toolkit.gp_resgroup_config c
+			JOIN gp_toolkit.gp_resgroup_status s ON s.groupid = c.groupid
+	`
+	// 内存上限为-1时表示不限制
+	resqueueStatusSql = `SELECT rsqname, rsqcountvalue, rsqwaiters, rsqmemoryvalue, rsqmemorylimit from gp_toolkit.gp_resqueue_status;`
+	// 单个查询的内存配额按资源组在该segment上的内存除以并发数估算
+	sessionsOverMemoryQuotaSql = `
+		SELECT count(distinct m.sess_id)
+		FROM session_state.session_level_memory_consumption m
+			JOIN pg_stat_activity a ON a.sess_id = m.sess_id
+			JOIN gp_toolkit.gp_resgroup_config c ON c.groupname = a.rsgname
+			JOIN gp_toolkit.gp_resgroup_status_per_segment s ON s.groupid = c.groupid AND s.segment_id = m.segid
+		WHERE c.concurrency::int > 0
+			AND m.vmem_mb > (s.memory_used + s.memory_available) / c.concurrency::int
+	`
+)
+
+var resgroupCpuUsageSqls = versionedSql{6: resgroupCpuUsageSql_V6, 7: resgroupCpuUsageSql_V7}
+
+var (
+	resgroupMemoryUsedDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "resgroup_memory_used_percent"),
+		"Percent of used memory to the memory limit of each resource group on the busiest host",
+		[]string{"rsgname"}, nil,
+	)
+
+	resgroupCpuUsageDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "resgroup_cpu_usage_percent"),
+		"Percent of CPU used by each resource group on the busiest host",
+		[]string{"rsgname"}, nil,
+	)
+
+	resgroupConcurrencyUsedDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "resgroup_concurrency_used"),
+		"Number of running transactions of each resource group",
+		[]string{"rsgname"}, nil,
+	)
+
+	resgroupConcurrencyLimitDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "resgroup_concurrency_limit"),
+		"Concurrency limit of each resource group, not reported for groups with concurrency 0",
+		[]string{"rsgname"}, nil,
+	)
+
+	resgroupQueueLengthDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "resgroup_queue_length"),
+		"Number of transactions currently queued waiting for a slot in each resource group",
+		[]string{"rsgname"}, nil,
+	)
+
+	resgroupQueueDurationDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "resgroup_total_queue_duration_seconds"),
+		"Total seconds transactions have spent queued in each resource group since the cluster started",
+		[]string{"rsgname"}, nil,
+	)
+
+	totalRunningStatementsDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "total_running_statements"),
+		"Number of running transactions summed across all resource groups",
+		nil, nil,
+	)
+
+	totalConcurrencyLimitDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "total_concurrency_limit"),
+		"Concurrency limit summed across all resource groups",
+		nil, nil,
+	)
+
+	defaultResgroupActiveDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "default_resgroup_active"),
+		"Number of running transactions in default_group, which indicates workload of roles not assigned to a resource group",
+		nil, nil,
+	)
+
+	resqueueActiveStatementsDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "resqueue_active_statements"),
+		"Number of active statements of each resource queue",
+		[]string{"rsqname"}, nil,
+	)
+
+	resqueueWaitingStatementsDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "resqueue_waiting_statements"),
+		"Number of statements waiting for a slot in each resource queue",
+		[]string{"rsqname"}, nil,
+	)
+
+	resqueueMemoryUsedDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "resqueue_memory_used_bytes"),
+		"Memory in bytes reserved by the active statements of each resource queue",
+		[]string{"rsqname"}, nil,
+	)
+
+	resqueueMemoryLimitDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "resqueue_memory_limit_bytes"),
+		"Memory limit in bytes of each resource queue, not reported for unlimited queues",
+		[]string{"rsqname"}, nil,
+	)
+
+	sessionsOverMemoryQuotaDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "sessions_over_memory_quota"),
+		"Number of sessions consuming more memory on a segment than the per-query share of their resource group",
+		nil, nil,
+	)
+)
+
+func NewResourceGroupScraper() Scraper {
+	return resourceGroupScraper{}
+}
+
+type resourceGroupScraper struct{}
+
+func (resourceGroupScraper) Name() string {
+	return "resource_group_scraper"
+}
+
+func (resourceGroupScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	manager, err := queryResourceManager(ctx, db, ver)
+	if err != nil {
+		return wrapErr("resource_manager", err)
+	}
+
+	// Greenplum 7 的资源组还可以是group-v2
+	if strings.HasPrefix(manager, "group") {
+		errM := scrapeResgroupMemoryUsed(ctx, db, ch)
+		errU := scrapeResgroupCpuUsage(ctx, db, ch, ver)
+		errC := scrapeResgroupStatus(ctx, db, ch)
+		errS := scrapeSessionsOverMemoryQuota(ctx, db, ch)
+
+		return combineErr(
+			wrapErr("resgroup_memory_used", errM),
+			wrapErr("resgroup_cpu_usage", errU),
+			wrapErr("resgroup_status", errC),
+			wrapErr("sessions_over_memory_quota", errS),
+		)
+	}
+
+	if manager == "queue" {
+		return wrapErr("resqueue_status", scrapeResqueueStatus(ctx, db, ch))
+	}
+
+	// gp_resource_manager为none时没有启用资源管理
+	return nil
+}
+
+func scrapeResgroupMemoryUsed(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	logger.Debugf("Query Database: %s", resgroupMemoryUsedSql)
+	rows, err := db.QueryContext(ctx, resgroupMemoryUsedSql)
+
+	if err != nil {
+		if isUndefinedObject(err) {
+			logger.Warnf("skip resource group memory metrics, error:%v", err)
+			return nil
+		}
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var rsgname string
+		var percent sql.NullFloat64
+
+		err = rows.Scan(&rsgname, &percent)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if !percent.Valid {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(resgroupMemoryUsedDesc, prometheus.GaugeValue, percent.Float64, rsgname)
+	}
+
+	return combineErr(errs...)
+}
+
+func scrapeResgroupCpuUsage(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	querySql := resgroupCpuUsageSqls.forVersion(ver)
+
+	logger.Debugf("Query Database: %s", querySql)
+	rows, err := db.QueryContext(ctx, querySql)
+
+	if err != nil {
+		if isUndefinedObject(err) {
+			logger.Warnf("skip resource group cpu metrics, error:%v", err)
+			return nil
+		}
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var rsgname string
+		var percent sql.NullFloat64
+
+		err = rows.Scan(&rsgname, &percent)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if !percent.Valid {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(resgroupCpuUsageDesc, prometheus.GaugeValue, percent.Float64, rsgname)
+	}
+
+	if err = rows.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return combineErr(errs...)
+}
+
+func scrapeResgroupStatus(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	logger.Debugf("Query Database: %s", resgroupStatusSql)
+	rows, err := db.QueryContext(ctx, resgroupStatusSql)
+
+	if err != nil {
+		if isUndefinedObject(err) {
+			logger.Warnf("skip resource group status metrics, error:%v", err)
+			return nil
+		}
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	// 汇总所有资源组，得到集群级别的并发余量
+	groups := 0
+	var totalUsed, totalLimit float64
+
+	for rows.Next() {
+		var rsgname string
+		var limit, used, queueing float64
+		var queueDuration sql.NullFloat64
+
+		err = rows.Scan(&rsgname, &limit, &used, &queueing, &queueDuration)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(resgroupConcurrencyUsedDesc, prometheus.GaugeValue, used, rsgname)
+		ch <- prometheus.MustNewConstMetric(resgroupQueueLengthDesc, prometheus.GaugeValue, queueing, rsgname)
+
+		if rsgname == defaultResgroupName {
+			ch <- prometheus.MustNewConstMetric(defaultResgroupActiveDesc, prometheus.GaugeValue, used)
+		}
+
+		groups++
+		totalUsed += used
+		totalLimit += limit
+
+		// 并发数为0的资源组不能执行事务，只用于外部组件
+		if limit > 0 {
+			ch <- prometheus.MustNewConstMetric(resgroupConcurrencyLimitDesc, prometheus.GaugeValue, limit, rsgname)
+		}
+
+		if queueDuration.Valid {
+			ch <- prometheus.MustNewConstMetric(resgroupQueueDurationDesc, prometheus.CounterValue, queueDuration.Float64, rsgname)
+		}
+	}
+
+	if groups > 0 {
+		ch <- prometheus.MustNewConstMetric(totalRunningStatementsDesc, prometheus.GaugeValue, totalUsed)
+		ch <- prometheus.MustNewConstMetric(totalConcurrencyLimitDesc, prometheus.GaugeValue, totalLimit)
+	}
+
+	return combineErr(errs...)
+}
+
+func scrapeResqueueStatus(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	logger.Debugf("Query Database: %s", resqueueStatusSql)
+	rows, err := db.QueryContext(ctx, resqueueStatusSql)
+
+	if err != nil {
+		if isUndefinedObject(err) {
+			logger.Warnf("skip resource queue status metrics, error:%v", err)
+			return nil
+		}
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var rsqname string
+		var active, waiting float64
+		var memoryUsed, memoryLimit sql.NullFloat64
+
+		err = rows.Scan(&rsqname, &active, &waiting, &memoryUsed, &memoryLimit)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(resqueueActiveStatementsDesc, prometheus.GaugeValue, active, rsqname)
+		ch <- prometheus.MustNewConstMetric(resqueueWaitingStatementsDesc, prometheus.GaugeValue, waiting, rsqname)
+
+		if memoryUsed.Valid {
+			ch <- prometheus.MustNewConstMetric(resqueueMemoryUsedDesc, prometheus.GaugeValue, memoryUsed.Float64, rsqname)
+		}
+
+		if memoryLimit.Valid && memoryLimit.Float64 > 0 {
+			ch <- prometheus.MustNewConstMetric(resqueueMemoryLimitDesc, prometheus.GaugeValue, memoryLimit.Float64, rsqname)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return combineErr(errs...)
+}
+
+func scrapeSessionsOverMemoryQuota(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	err := scrapeScalarGauge(ctx, db, ch, sessionsOverMemoryQuotaDesc, sessionsOverMemoryQuotaSql)
+
+	// 未安装session_state视图时跳过
+	if isUndefinedObject(err) {
+		logger.Warnf("skip sessions over memory quota metrics, error:%v", err)
+		return nil
+	}
+
+	return skipScalarNull(err)
+}
@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestResourceGroupScraperErrors(t *testing.T) {
+	t.Run("resource_manager_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(resourceManagerSql)).WillReturnError(errors.New("connection reset"))
+
+		err := collectAndCompare(t, NewResourceGroupScraper(), db, 6, "")
+		expectErrLabels(t, err, "resource_manager")
+	})
+
+	t.Run("query_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectResourceManager(mock, "group")
+		expectFailedQueries(mock, resgroupMemoryUsedSql, resgroupCpuUsageSql_V6, resgroupStatusSql, sessionsOverMemoryQuotaSql)
+
+		err := collectAndCompare(t, NewResourceGroupScraper(), db, 6, "")
+		expectErrLabels(t, err, "resgroup_memory_used", "resgroup_cpu_usage", "resgroup_status", "sessions_over_memory_quota")
+	})
+
+	// 出错的行被跳过，其余行照常输出
+	t.Run("scan_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectResourceManager(mock, "group-v2")
+		mock.ExpectQuery(regexp.QuoteMeta(resgroupMemoryUsedSql)).WillReturnRows(sqlmock.NewRows([]string{"rsgname", "percent"}).
+			AddRow("default_group", "abc"))
+		mock.ExpectQuery(regexp.QuoteMeta(resgroupCpuUsageSql_V7)).WillReturnRows(sqlmock.NewRows([]string{"rsgname", "cpu_usage"}).
+			AddRow("default_group", "abc"))
+		mock.ExpectQuery(regexp.QuoteMeta(resgroupStatusSql)).WillReturnRows(sqlmock.NewRows(
+			[]string{"groupname", "concurrency", "num_running", "num_queueing", "total_queue_duration"}).
+			AddRow("admin_group", "abc", 1, 0, nil).
+			AddRow("default_group", 20, 3, 1, nil))
+		mock.ExpectQuery(regexp.QuoteMeta(sessionsOverMemoryQuotaSql)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow("abc"))
+
+		expected := `
+# HELP greenplum_server_resgroup_concurrency_used Number of running transactions of each resource group
+# TYPE greenplum_server_resgroup_concurrency_used gauge
+greenplum_server_resgroup_concurrency_used{rsgname="default_group"} 3
+# HELP greenplum_server_total_running_statements Number of running transactions summed across all resource groups
+# TYPE greenplum_server_total_running_statements gauge
+greenplum_server_total_running_statements 3
+`
+		err := collectAndCompare(t, NewResourceGroupScraper(), db, 7, expected, "greenplum_server_resgroup_concurrency_used",
+			"greenplum_server_total_running_statements", "greenplum_server_resgroup_memory_used_percent", "greenplum_server_resgroup_cpu_usage_percent")
+		expectErrLabels(t, err, "resgroup_memory_used", "resgroup_cpu_usage", "resgroup_status", "sessions_over_memory_quota")
+	})
+
+	t.Run("resqueue_scan_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(resqueueStatusSql)).WillReturnRows(sqlmock.NewRows(
+			[]string{"rsqname", "rsqcountvalue", "rsqwaiters", "rsqmemoryvalue", "rsqmemorylimit"}).
+			AddRow("pg_default", "abc", 0, nil, nil))
+
+		err := collectAndCompare(t, NewResourceGroupScraper(), db, 5, "")
+		expectErrLabels(t, err, "resqueue_status")
+	})
+
+	// 资源组视图没有数据时不输出汇总指标，也不报错
+	t.Run("empty", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectResourceManager(mock, "group")
+		expectEmptyQueries(mock, resgroupMemoryUsedSql, resgroupCpuUsageSql_V6, resgroupStatusSql, sessionsOverMemoryQuotaSql)
+
+		if err := collectAndCompare(t, NewResourceGroupScraper(), db, 6, ""); err != nil {
+			t.Errorf("unexpected scrape error: %v", err)
+		}
+	})
+
+	// 未启用资源管理时不查询
+	t.Run("none", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectResourceManager(mock, "none")
+
+		if err := collectAndCompare(t, NewResourceGroupScraper(), db, 6, ""); err != nil {
+			t.Errorf("unexpected scrape error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  运行中查询的处理倾斜抓取器：数据来源为gpperfmon的queries_now，取CPU耗时最长的运行中查询的skew_rows，
+ *  即各segment处理行数的变异系数(标准差/平均值)，没有运行中的查询时不输出
+ */
+
+const (
+	runningQuerySkewSql = `
+		SELECT skew_rows, ctime::timestamptz FROM queries_now
+		WHERE status = 'start' AND skew_rows is not null
+		ORDER BY cpu_elapsed DESC
+		LIMIT 1
+	`
+)
+
+var (
+	runningQuerySkewDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "running_query_segment_skew"),
+		"Coefficient of variation of rows processed across segments of the running query with the most cpu time from gpperfmon",
+		nil, nil,
+	)
+)
+
+func NewRunningQuerySkewScraper(conns *ConnectionCache) Scraper {
+	return runningQuerySkewScraper{conns: conns}
+}
+
+type runningQuerySkewScraper struct {
+	conns *ConnectionCache
+}
+
+func (runningQuerySkewScraper) Name() string {
+	return "running_query_skew_scraper"
+}
+
+func (s runningQuerySkewScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	conn, err := openGpperfmon(ctx, s.conns)
+	if err != nil {
+		return err
+	}
+
+	var skew float64
+	var sampleTime time.Time
+
+	logger.Debugf("Query Database: %s", runningQuerySkewSql)
+	err = conn.QueryRowContext(ctx, runningQuerySkewSql).Scan(&skew, &sampleTime)
+
+	if err == sql.ErrNoRows {
+		return nil
+	}
+
+	if err != nil {
+		// 不同版本的gpperfmon中queries_now的字段不同
+		if isUndefinedObject(err) {
+			logger.Warnf("skip running query skew metrics, error:%v", err)
+			return nil
+		}
+		return err
+	}
+
+	// 使用gpperfmon的采集时间作为指标的时间戳
+	ch <- prometheus.NewMetricWithTimestamp(sampleTime, prometheus.MustNewConstMetric(runningQuerySkewDesc, prometheus.GaugeValue, skew))
+
+	return nil
+}
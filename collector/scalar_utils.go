@@ -0,0 +1,78 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+// 单值查询没有返回数据行或返回NULL时的哨兵错误，调用方可以选择跳过对应指标
+var errScalarNull = errors.New("scalar query returned no value")
+
+/**
+* 函数：scrapeScalar
+* 功能：执行只返回单行单列的查询，NULL或无数据时返回errScalarNull
+ */
+func scrapeScalar(ctx context.Context, db Queryer, query string, args ...interface{}) (float64, error) {
+	logger.Debugf("Query Database: %s", query)
+
+	var value sql.NullFloat64
+	err := db.QueryRowContext(ctx, query, args...).Scan(&value)
+
+	if err == sql.ErrNoRows {
+		return 0, errScalarNull
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	if !value.Valid {
+		return 0, errScalarNull
+	}
+
+	return value.Float64, nil
+}
+
+/**
+* 函数：scrapeScalarGauge
+* 功能：执行单值查询并以Gauge类型发送指标
+ */
+func scrapeScalarGauge(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, desc *prometheus.Desc, query string, labelValues ...string) error {
+	return emitScalar(ctx, db, ch, desc, prometheus.GaugeValue, query, labelValues...)
+}
+
+/**
+* 函数：scrapeScalarCounter
+* 功能：执行单值查询并以Counter类型发送指标
+ */
+func scrapeScalarCounter(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, desc *prometheus.Desc, query string, labelValues ...string) error {
+	return emitScalar(ctx, db, ch, desc, prometheus.CounterValue, query, labelValues...)
+}
+
+func emitScalar(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, desc *prometheus.Desc, valueType prometheus.ValueType, query string, labelValues ...string) error {
+	value, err := scrapeScalar(ctx, db, query)
+
+	if err != nil {
+		return err
+	}
+
+	ch <- prometheus.MustNewConstMetric(desc, valueType, value, labelValues...)
+
+	return nil
+}
+
+/**
+* 函数：skipScalarNull
+* 功能：忽略单值查询无结果的哨兵错误
+ */
+func skipScalarNull(err error) error {
+	if errors.Is(err, errScalarNull) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,110 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/**
+ *  抓取结果缓存：设置--cache.ttl后，每个抓取器成功抓取的指标在ttl内直接重放，不再查询数据库。
+ *  Collect持有采集器的锁，并发的/metrics请求依次执行，第一个请求抓取后其余请求命中缓存，只查询一次数据库。
+ *  抓取失败的抓取器不缓存，下一次请求时重新抓取，不影响其它抓取器的缓存
+ */
+
+type cachedScrape struct {
+	metrics []prometheus.Metric
+	at      time.Time
+}
+
+type scrapeCache struct {
+	ttl     time.Duration
+	entries map[string]cachedScrape
+}
+
+func newScrapeCache(ttl time.Duration) *scrapeCache {
+	return &scrapeCache{ttl: ttl, entries: make(map[string]cachedScrape)}
+}
+
+/**
+* 函数：get
+* 功能：获取抓取器在ttl内缓存的指标，未开启缓存、没有缓存或已过期时返回false
+ */
+func (c *scrapeCache) get(name string) ([]prometheus.Metric, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+
+	entry, ok := c.entries[name]
+	if !ok || time.Since(entry.at) >= c.ttl {
+		return nil, false
+	}
+
+	return entry.metrics, true
+}
+
+/**
+* 函数：fresh
+* 功能：判断所有抓取器是否都有未过期的缓存，此时无需连接数据库
+ */
+func (c *scrapeCache) fresh(scrapers []Scraper) bool {
+	if c.ttl <= 0 || len(scrapers) == 0 {
+		return false
+	}
+
+	for _, scraper := range scrapers {
+		if _, ok := c.get(scraper.Name()); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+/**
+* 函数：tee
+* 功能：包装指标通道，转发到ch的同时记录发送的指标；调用返回的函数等待转发结束并获取记录的指标，未开启缓存时不记录
+ */
+func (c *scrapeCache) tee(ch chan<- prometheus.Metric) (chan<- prometheus.Metric, func() []prometheus.Metric) {
+	if c.ttl <= 0 {
+		return ch, func() []prometheus.Metric { return nil }
+	}
+
+	in := make(chan prometheus.Metric)
+	done := make(chan struct{})
+
+	metrics := make([]prometheus.Metric, 0)
+
+	go func() {
+		defer close(done)
+
+		for m := range in {
+			metrics = append(metrics, m)
+			ch <- m
+		}
+	}()
+
+	return in, func() []prometheus.Metric {
+		close(in)
+		<-done
+
+		return metrics
+	}
+}
+
+/**
+* 函数：record
+* 功能：抓取成功时缓存抓取器的指标，失败时删除其缓存
+ */
+func (c *scrapeCache) record(name string, metrics []prometheus.Metric, err error) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	if err != nil {
+		delete(c.entries, name)
+		return
+	}
+
+	c.entries[name] = cachedScrape{metrics: metrics, at: time.Now()}
+}
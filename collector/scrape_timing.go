@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+)
+
+/**
+ *  抓取耗时拆分：将每个抓取器的耗时拆分为获取连接的时间和执行查询的时间。获取连接的时间包括等待
+ *  coordinator连接池的时间(sql.DBStats.WaitDuration的增量)和按库建立连接(openDatabase)的时间，
+ *  其余时间视为执行查询的时间
+ */
+
+type connectTimer struct {
+	mu sync.Mutex
+
+	elapsed time.Duration
+}
+
+// 抓取器按顺序执行，openDatabase的耗时累计到当前正在执行的抓取器；按库并发建立连接时耗时叠加，由stop限制为不超过总耗时
+var scrapeConnectTimer = &connectTimer{}
+
+func (t *connectTimer) add(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.elapsed += d
+}
+
+/**
+* 函数：take
+* 功能：返回累计的建立连接耗时并清零
+ */
+func (t *connectTimer) take() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	elapsed := t.elapsed
+	t.elapsed = 0
+
+	return elapsed
+}
+
+type scrapeTiming struct {
+	db        *sql.DB
+	start     time.Time
+	waitStart time.Duration
+}
+
+/**
+* 函数：startScrapeTiming
+* 功能：在抓取器执行前记录开始时间和连接池的累计等待时间
+ */
+func startScrapeTiming(db *sql.DB) scrapeTiming {
+	scrapeConnectTimer.take()
+
+	return scrapeTiming{db: db, start: time.Now(), waitStart: db.Stats().WaitDuration}
+}
+
+/**
+* 函数：stop
+* 功能：返回抓取器获取连接和执行查询的耗时(秒)
+ */
+func (t scrapeTiming) stop() (connect, query float64) {
+	total := time.Since(t.start)
+	connectElapsed := t.db.Stats().WaitDuration - t.waitStart + scrapeConnectTimer.take()
+
+	if connectElapsed > total {
+		connectElapsed = total
+	}
+
+	return connectElapsed.Seconds(), (total - connectElapsed).Seconds()
+}
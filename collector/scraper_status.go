@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// 抓取器支持的Greenplum主版本范围，未实现该接口的抓取器视为支持所有版本
+type VersionRanger interface {
+	// 支持的最低和最高主版本号，0表示不限制
+	VersionRange() (min, max int)
+}
+
+// 抓取器的状态信息，用于/scrapers接口
+type ScraperStatus struct {
+	Name        string     `json:"name"`
+	Enabled     bool       `json:"enabled"`
+	MinVersion  int        `json:"min_version,omitempty"`
+	MaxVersion  int        `json:"max_version,omitempty"`
+	LastScrape  *time.Time `json:"last_scrape,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+type scrapeResult struct {
+	lastScrape  time.Time
+	lastSuccess time.Time
+	lastError   string
+}
+
+type scraperStatuses struct {
+	mu      sync.Mutex
+	results map[string]*scrapeResult
+}
+
+func newScraperStatuses() *scraperStatuses {
+	return &scraperStatuses{results: make(map[string]*scrapeResult)}
+}
+
+func (s *scraperStatuses) record(name string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, ok := s.results[name]
+	if !ok {
+		result = &scrapeResult{}
+		s.results[name] = result
+	}
+
+	result.lastScrape = time.Now()
+	if err != nil {
+		result.lastError = err.Error()
+	} else {
+		result.lastSuccess = result.lastScrape
+		result.lastError = ""
+	}
+}
+
+/**
+* 函数：ScraperStatus
+* 功能：获取所有抓取器(包括未启用的)的状态，按名称排序
+ */
+func (c *GreenPlumCollector) ScraperStatus(scrapers map[Scraper]bool) []ScraperStatus {
+	c.statuses.mu.Lock()
+	defer c.statuses.mu.Unlock()
+
+	list := make([]ScraperStatus, 0, len(scrapers))
+	for scraper, enabled := range scrapers {
+		status := ScraperStatus{Name: scraper.Name(), Enabled: enabled}
+
+		if ranger, ok := scraper.(VersionRanger); ok {
+			status.MinVersion, status.MaxVersion = ranger.VersionRange()
+		}
+
+		if result, ok := c.statuses.results[scraper.Name()]; ok {
+			lastScrape := result.lastScrape
+			status.LastScrape = &lastScrape
+
+			if !result.lastSuccess.IsZero() {
+				lastSuccess := result.lastSuccess
+				status.LastSuccess = &lastSuccess
+			}
+
+			status.LastError = result.lastError
+		}
+
+		list = append(list, status)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list
+}
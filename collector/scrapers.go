@@ -1,8 +1,10 @@
 package collector
 
 import (
+	"context"
 	"database/sql"
 	"github.com/prometheus/client_golang/prometheus"
+	"time"
 )
 
 // 抓取器Scraper接口定义
@@ -15,5 +17,30 @@ type Scraper interface {
 	Name() string
 
 	// 从数据库连接中获取数据信息，并发送到数据类型为prometheus metric的通道里.
-	Scrape(db *sql.DB, ch chan<- prometheus.Metric, ver int) error
+	// ctx带有该抓取器的超时时间(--scrape.timeout)，所有查询都应使用ctx.
+	Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error
+}
+
+// 抓取器执行查询使用的coordinator连接，*sql.DB、*sql.Conn和*sql.Tx都实现了该接口
+// 开启一致性快照时为快照所在的事务，见snapshot.go
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// 执行较慢查询(如读取日志文件)的抓取器可指定自身的超时时间
+// 实际超时时间取该值与--scrape.timeout中的较大者
+type Timeouter interface {
+
+	// 抓取器需要的最短超时时间
+	Timeout() time.Duration
+}
+
+// 支持重新加载文件配置的抓取器，收到SIGHUP信号时调用
+// 只重新加载基于文件的配置，基于环境变量的配置需要重启生效
+type Reloader interface {
+
+	// 重新加载配置，返回错误时保留原有配置
+	Reload() error
 }
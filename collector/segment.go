@@ -4,7 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"github.com/prometheus/client_golang/prometheus"
-	logger "github.com/prometheus/common/log"
+	logger "greenplum-exporter/logging"
+	"os"
 	"time"
 )
 
@@ -14,68 +15,243 @@ import (
  */
 
 const (
+	expectedPrimarySegmentsEnv = "GPDB_EXPECTED_PRIMARY_SEGMENTS"
+
 	segmentConfigSql_V6       = `select dbid,content,role,preferred_role,mode,status,port,hostname,address,datadir from gp_segment_configuration;`
 	segmentConfigSql_V5       = `select dbid,content,role,preferred_role,mode,status,port,hostname,address,null as datadir from gp_segment_configuration;`
 
 	segmentDiskFreeSizeSql = `SELECT dfhostname as segment_hostname,sum(dfspace)/count(dfspace)/(1024*1024) as segment_disk_free_gb from gp_toolkit.gp_disk_free GROUP BY dfhostname;`
+	segmentUpTimeSql       = `SELECT gp_segment_id, extract(epoch from now() - pg_postmaster_start_time()) from gp_dist_random('gp_id');`
+	unbalancedHostsSql     = `SELECT count(*) from (
+			SELECT hostname from gp_segment_configuration where content >= 0 GROUP BY hostname
+			HAVING sum(case when role='p' then 1 else 0 end) > sum(case when preferred_role='p' then 1 else 0 end)
+		) t;`
+	coordinatorDiskFreeSql = `SELECT dfhostname, dfdevice, dfspace from gp_toolkit.gp_disk_free where dfsegment=-1;`
+	// clock_timestamp()在各segment上分别计算，结果包含分发查询的延迟(通常为毫秒级)
+	segmentClockSkewSql = `SELECT max(t) - min(t) from (
+			SELECT extract(epoch from clock_timestamp()) t from gp_dist_random('gp_id')
+			union all
+			SELECT extract(epoch from clock_timestamp())
+		) c;`
+	// Greenplum 5 重新同步中的mode为r(change tracking表示mirror已宕机，不算恢复中)；
+	// Greenplum 6及以上没有单独的重新同步状态，mirror已启动但尚未同步(mode为n)即为恢复中
+	recoveringSegmentsSql_V6 = `SELECT count(*) from gp_segment_configuration where content >= 0 and role = 'm' and status = 'u' and mode = 'n';`
+	recoveringSegmentsSql_V5 = `SELECT count(*) from gp_segment_configuration where content >= 0 and role = 'm' and status = 'u' and mode = 'r';`
+	primarySegmentsUpSql = `SELECT count(*) from gp_segment_configuration where content >= 0 and role = 'p' and status = 'u';`
+	// 数据目录所在文件系统报告可用空间为0时，segment已无法写入
+	readonlySegmentsSql = `SELECT count(distinct dfsegment) from gp_toolkit.gp_disk_free where dfsegment >= 0 and dfspace = 0;`
+	// Greenplum 5 的gp_segment_configuration中没有datadir字段
+	invalidSegmentConfigSql_V6 = `SELECT count(*) from gp_segment_configuration
+		where coalesce(hostname, '') = '' or coalesce(address, '') = '' or coalesce(datadir, '') = '';`
+	invalidSegmentConfigSql_V5 = `SELECT count(*) from gp_segment_configuration
+		where coalesce(hostname, '') = '' or coalesce(address, '') = '';`
+	// FTS没有公开最近一次探测的时间，使用其在gp_configuration_history中记录的最近一次状态变更时间
+	ftsLastChangeSql = `SELECT extract(epoch from now() - max("time")) from gp_configuration_history;`
+	// 没有mirror的集群不存在冗余，返回null不输出
+	redundancyPercentSql = `SELECT case when max(members) < 2 then null
+			else sum(case when up >= 2 then 1 else 0 end) * 100.0 / count(*) end
+		from (SELECT content, count(*) members, sum(case when status = 'u' then 1 else 0 end) up
+			from gp_segment_configuration where content >= 0 GROUP BY content) t;`
+	colocatedPairsSql = `SELECT count(*) from gp_segment_configuration p join gp_segment_configuration m
+		on m.content = p.content and m.role = 'm' and m.hostname = p.hostname
+		where p.content >= 0 and p.role = 'p';`
 )
 
 var (
-	statusDesc = prometheus.NewDesc(
+	statusDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemNode, "segment_status"),
 		"UP(1) if the segment is running, DOWN(0) if the segment has failed or is unreachable",
-		[]string{"hostname", "address", "dbid", "content", "preferred_role", "port", "data_dir"}, nil,
+		[]string{"hostname", "address", "dbid", "content", "preferred_role", "port", "data_dir", "rack", "dc"}, nil,
 	)
 
-	roleDesc = prometheus.NewDesc(
+	roleDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemNode, "segment_role"),
 		"The segment's current role, either primary or mirror",
-		[]string{"hostname", "address", "dbid", "content", "preferred_role", "port", "data_dir"}, nil,
+		[]string{"hostname", "address", "dbid", "content", "preferred_role", "port", "data_dir", "rack", "dc"}, nil,
 	)
 
-	modeDesc = prometheus.NewDesc(
+	modeDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemNode, "segment_mode"),
 		"The replication status for the segment",
-		[]string{"hostname", "address", "dbid", "content", "preferred_role", "port", "data_dir"}, nil,
+		[]string{"hostname", "address", "dbid", "content", "preferred_role", "port", "data_dir", "rack", "dc"}, nil,
+	)
+
+	segmentDatadirInfoDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_datadir_info"),
+		"Data directory of each segment, always 1, not available on GreenPlum 5",
+		[]string{"dbid", "hostname", "datadir"}, nil,
+	)
+
+	segmentDiskHoursToFullDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_disk_hours_to_full"),
+		"Estimated hours until the disk of the segment host is full at the fill rate since the previous scrape",
+		[]string{"hostname", "rack", "dc"}, nil,
 	)
 
-	segmentDiskFreeSizeDesc = prometheus.NewDesc(
+	segmentDiskFreeSizeDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemNode, "segment_disk_free_mb_size"), //指标的名称
 		"Total MB size of each segment node free size of disk in the file system",     //帮助信息，显示在指标的上面作为注释
-		[]string{"hostname"},                                                          //定义的label名称数组
+		[]string{"hostname", "rack", "dc"},                                            //定义的label名称数组
 		nil,                                                                           //定义的Labels
 	)
+
+	segmentUpTimeDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_uptime_seconds"),
+		"Seconds since the postmaster of each primary segment was started",
+		[]string{"gp_segment_id"},
+		nil,
+	)
+
+	unbalancedHostsDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "unbalanced_hosts"),
+		"Number of hosts running more primary segments than their preferred share, a rebalance is needed if not 0",
+		nil,
+		nil,
+	)
+
+	coordinatorDiskFreeDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "coordinator_disk_free_kb"),
+		"Total KB size of free disk space on the coordinator(master) host",
+		[]string{"hostname", "device"},
+		nil,
+	)
+
+	expectedPrimarySegmentsDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "segments_expected"),
+		"Expected number of primary segments configured by GPDB_EXPECTED_PRIMARY_SEGMENTS",
+		nil,
+		nil,
+	)
+
+	primarySegmentsUpDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "primary_segments_up"),
+		"Number of primary segments that are up in gp_segment_configuration",
+		nil,
+		nil,
+	)
+
+	missingSegmentsDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "segments_missing"),
+		"Expected primary segments minus the primary segments that are up",
+		nil,
+		nil,
+	)
+
+	segmentClockSkewDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "max_segment_clock_skew_seconds"),
+		"Max difference of clock_timestamp() between the master and all primary segments, including the dispatch latency",
+		nil,
+		nil,
+	)
+
+	recoveringSegmentsDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "segments_recovering"),
+		"Number of mirror segments that are up and resynchronizing with their primary",
+		nil,
+		nil,
+	)
+
+	readonlySegmentsDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "segments_readonly"),
+		"Number of segments whose data directory filesystem reports no free space in gp_toolkit.gp_disk_free, a heuristic for read-only or failing disks",
+		nil,
+		nil,
+	)
+
+	invalidSegmentConfigDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "segments_invalid_config"),
+		"Number of gp_segment_configuration rows with a NULL or empty hostname, address or datadir",
+		nil,
+		nil,
+	)
+
+	ftsLastChangeDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "fts_last_change_seconds"),
+		"Seconds since the last segment state change recorded by FTS in gp_configuration_history",
+		nil,
+		nil,
+	)
+
+	redundancyPercentDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "redundancy_percent"),
+		"Percent of content ids whose primary and mirror segments are both up, not reported for mirrorless clusters",
+		nil,
+		nil,
+	)
+
+	colocatedPairsDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "colocated_primary_mirror_pairs"),
+		"Number of content ids whose primary and mirror segments are on the same host",
+		nil,
+		nil,
+	)
 )
 
 func NewSegmentScraper() Scraper {
-	return segmentScraper{}
+	return segmentScraper{
+		hostLabels:        newHostLabels(os.Getenv(hostLabelsEnv)),
+		diskFreeSql:       newOverridableSql("SEGMENT_DISK_FREE", segmentDiskFreeSizeSql, 2),
+		expectedPrimaries: envInt(expectedPrimarySegmentsEnv, 0),
+		diskTrend:         newDiskTrend(),
+	}
 }
 
-type segmentScraper struct{}
+type segmentScraper struct {
+	hostLabels        *hostLabels
+	diskFreeSql       overridableSql
+	expectedPrimaries int
+	diskTrend         *diskTrend
+}
 
 func (segmentScraper) Name() string {
 	return "segment_scraper"
 }
 
-func (segmentScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric, ver int) error {
-	errU := scrapeSegmentConfig(db, ch, ver)
-	errC := scrapeSegmentDiskFree(db, ch)
-
-	return combineErr(errC, errU)
+func (s segmentScraper) Reload() error {
+	return s.hostLabels.reload()
 }
 
-func scrapeSegmentConfig(db *sql.DB, ch chan<- prometheus.Metric, ver int) error {
-	ctx := context.Background()
-	ctx, cancel := context.WithTimeout(ctx, time.Second*2)
-
-	defer cancel()
+func (s segmentScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	s.hostLabels.refresh()
+
+	errU := scrapeSegmentConfig(ctx, db, ch, ver, s.hostLabels)
+	errC := scrapeSegmentDiskFree(ctx, db, ch, s.diskFreeSql, s.hostLabels, s.diskTrend)
+	errM := scrapeCoordinatorDiskFree(ctx, db, ch)
+	errT := scrapeSegmentUpTime(ctx, db, ch)
+	errB := scrapeUnbalancedHosts(ctx, db, ch)
+	errR := scrapeReadonlySegments(ctx, db, ch)
+	errP := scrapePrimarySegmentsUp(ctx, db, ch, s.expectedPrimaries)
+	errK := scrapeSegmentClockSkew(ctx, db, ch)
+	errV := scrapeRecoveringSegments(ctx, db, ch, ver)
+	errL := scrapeColocatedPairs(ctx, db, ch)
+	errI := scrapeInvalidSegmentConfig(ctx, db, ch, ver)
+	errF := scrapeFtsLastChange(ctx, db, ch)
+	errD := scrapeRedundancyPercent(ctx, db, ch)
+
+	return combineErr(
+		wrapErr("segment_disk_free", errC),
+		wrapErr("segment_config", errU),
+		wrapErr("coordinator_disk_free", errM),
+		wrapErr("segment_uptime", errT),
+		wrapErr("unbalanced_hosts", errB),
+		wrapErr("readonly_segments", errR),
+		wrapErr("primary_segments_up", errP),
+		wrapErr("segment_clock_skew", errK),
+		wrapErr("recovering_segments", errV),
+		wrapErr("colocated_primary_mirror_pairs", errL),
+		wrapErr("segments_invalid_config", errI),
+		wrapErr("fts_last_change", errF),
+		wrapErr("redundancy_percent", errD),
+	)
+}
 
+func scrapeSegmentConfig(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int, hostLabels *hostLabels) error {
 	querySql:=segmentConfigSql_V6
 	if ver < 6{
 		querySql=segmentConfigSql_V5;
 	}
 
-	logger.Infof("Query Database: %s", querySql)
+	logger.Debugf("Query Database: %s", querySql)
 	rows, err := db.QueryContext(ctx, querySql)
 
 	if err != nil {
@@ -97,22 +273,24 @@ func scrapeSegmentConfig(db *sql.DB, ch chan<- prometheus.Metric, ver int) error
 			continue
 		}
 
-		ch <- prometheus.MustNewConstMetric(statusDesc, prometheus.GaugeValue, getStatus(status), hostname, address, dbID, content, preferredRole, port, rp.String)
-		ch <- prometheus.MustNewConstMetric(roleDesc, prometheus.GaugeValue, getRole(role), hostname, address, dbID, content, preferredRole, port, rp.String)
-		ch <- prometheus.MustNewConstMetric(modeDesc, prometheus.GaugeValue, getMode(mode), hostname, address, dbID, content, preferredRole, port, rp.String)
+		rack, dc := hostLabels.lookup(hostname)
+
+		ch <- prometheus.MustNewConstMetric(statusDesc, prometheus.GaugeValue, getStatus(status), hostname, address, dbID, content, preferredRole, port, rp.String, rack, dc)
+		ch <- prometheus.MustNewConstMetric(roleDesc, prometheus.GaugeValue, getRole(role), hostname, address, dbID, content, preferredRole, port, rp.String, rack, dc)
+		ch <- prometheus.MustNewConstMetric(modeDesc, prometheus.GaugeValue, getMode(mode), hostname, address, dbID, content, preferredRole, port, rp.String, rack, dc)
+
+		// Greenplum 5 的gp_segment_configuration中没有datadir字段
+		if rp.Valid {
+			ch <- prometheus.MustNewConstMetric(segmentDatadirInfoDesc, prometheus.GaugeValue, 1, dbID, hostname, rp.String)
+		}
 	}
 
 	return combineErr(errs...)
 }
 
-func scrapeSegmentDiskFree(db *sql.DB, ch chan<- prometheus.Metric) error {
-	ctx := context.Background()
-	ctx, cancel := context.WithTimeout(ctx, time.Second*2)
-
-	defer cancel()
-
-	logger.Infof("Query Database: %s", segmentDiskFreeSizeSql)
-	rows, err := db.QueryContext(ctx, segmentDiskFreeSizeSql)
+func scrapeSegmentDiskFree(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, diskFreeSql overridableSql, hostLabels *hostLabels, diskTrend *diskTrend) error {
+	logger.Debugf("Query Database: %s", diskFreeSql.query)
+	rows, err := db.QueryContext(ctx, diskFreeSql.query)
 
 	if err != nil {
 		return err
@@ -120,6 +298,12 @@ func scrapeSegmentDiskFree(db *sql.DB, ch chan<- prometheus.Metric) error {
 
 	defer rows.Close()
 
+	if err = diskFreeSql.checkColumns(rows); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
 	errs := make([]error, 0)
 
 	for rows.Next() {
@@ -133,8 +317,147 @@ func scrapeSegmentDiskFree(db *sql.DB, ch chan<- prometheus.Metric) error {
 			continue
 		}
 
-		ch <- prometheus.MustNewConstMetric(segmentDiskFreeSizeDesc, prometheus.GaugeValue, mbSize, hostName)
+		rack, dc := hostLabels.lookup(hostName)
+
+		ch <- prometheus.MustNewConstMetric(segmentDiskFreeSizeDesc, prometheus.GaugeValue, mbSize, hostName, rack, dc)
+
+		if hours, ok := diskTrend.hoursToFull(hostName, mbSize, now); ok {
+			ch <- prometheus.MustNewConstMetric(segmentDiskHoursToFullDesc, prometheus.GaugeValue, hours, hostName, rack, dc)
+		}
+	}
+
+	return combineErr(errs...)
+}
+
+func scrapeCoordinatorDiskFree(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	logger.Debugf("Query Database: %s", coordinatorDiskFreeSql)
+	rows, err := db.QueryContext(ctx, coordinatorDiskFreeSql)
+
+	if err != nil {
+		// gp_toolkit未安装时跳过
+		if isUndefinedObject(err) {
+			logger.Warnf("skip coordinator disk free metrics, error:%v", err)
+			return nil
+		}
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var hostName, device string
+		var kbSize float64
+
+		err := rows.Scan(&hostName, &device, &kbSize)
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(coordinatorDiskFreeDesc, prometheus.GaugeValue, kbSize, hostName, device)
+	}
+
+	return combineErr(errs...)
+}
+
+func scrapeSegmentUpTime(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	logger.Debugf("Query Database: %s", segmentUpTimeSql)
+	rows, err := db.QueryContext(ctx, segmentUpTimeSql)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var segmentID string
+		var upTime float64
+
+		err := rows.Scan(&segmentID, &upTime)
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(segmentUpTimeDesc, prometheus.GaugeValue, upTime, segmentID)
 	}
 
 	return combineErr(errs...)
 }
+
+func scrapeUnbalancedHosts(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	return skipScalarNull(scrapeScalarGauge(ctx, db, ch, unbalancedHostsDesc, unbalancedHostsSql))
+}
+
+func scrapeReadonlySegments(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	err := scrapeScalarGauge(ctx, db, ch, readonlySegmentsDesc, readonlySegmentsSql)
+
+	// gp_toolkit未安装时跳过
+	if isUndefinedObject(err) {
+		logger.Warnf("skip readonly segments metrics, error:%v", err)
+		return nil
+	}
+
+	return skipScalarNull(err)
+}
+
+func scrapePrimarySegmentsUp(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, expectedPrimaries int) error {
+	up, err := scrapeScalar(ctx, db, primarySegmentsUpSql)
+	if err != nil {
+		return skipScalarNull(err)
+	}
+
+	ch <- prometheus.MustNewConstMetric(primarySegmentsUpDesc, prometheus.GaugeValue, up)
+
+	// 未配置GPDB_EXPECTED_PRIMARY_SEGMENTS时不检查缺失的segment
+	if expectedPrimaries <= 0 {
+		return nil
+	}
+
+	ch <- prometheus.MustNewConstMetric(expectedPrimarySegmentsDesc, prometheus.GaugeValue, float64(expectedPrimaries))
+	ch <- prometheus.MustNewConstMetric(missingSegmentsDesc, prometheus.GaugeValue, float64(expectedPrimaries)-up)
+
+	return nil
+}
+
+func scrapeSegmentClockSkew(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	return skipScalarNull(scrapeScalarGauge(ctx, db, ch, segmentClockSkewDesc, segmentClockSkewSql))
+}
+
+func scrapeRecoveringSegments(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	querySql := recoveringSegmentsSql_V6
+	if ver < 6 {
+		querySql = recoveringSegmentsSql_V5
+	}
+
+	return skipScalarNull(scrapeScalarGauge(ctx, db, ch, recoveringSegmentsDesc, querySql))
+}
+
+func scrapeColocatedPairs(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	return skipScalarNull(scrapeScalarGauge(ctx, db, ch, colocatedPairsDesc, colocatedPairsSql))
+}
+
+func scrapeInvalidSegmentConfig(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	querySql := invalidSegmentConfigSql_V6
+	if ver < 6 {
+		querySql = invalidSegmentConfigSql_V5
+	}
+
+	return skipScalarNull(scrapeScalarGauge(ctx, db, ch, invalidSegmentConfigDesc, querySql))
+}
+
+func scrapeFtsLastChange(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	// 集群初始化后没有发生过状态变更时不输出
+	return skipScalarNull(scrapeScalarGauge(ctx, db, ch, ftsLastChangeDesc, ftsLastChangeSql))
+}
+
+func scrapeRedundancyPercent(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	return skipScalarNull(scrapeScalarGauge(ctx, db, ch, redundancyPercentDesc, redundancyPercentSql))
+}
@@ -0,0 +1,98 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  segment活跃进程倾斜抓取器：统计每个primary segment上正在执行的QE进程数，输出最大值与平均值的比值，
+ *  比值明显大于1说明当前某个segment承担了不成比例的工作。exporter自身查询的QE进程不计入
+ */
+
+const (
+	primarySegmentCountSql = `SELECT count(*) from gp_segment_configuration where role = 'p' and content >= 0;`
+
+	segmentActiveBackendsSql_V6 = `SELECT gp_segment_id, count(*) from gp_dist_random('pg_stat_activity')
+		where state = 'active' and sess_id <> current_setting('gp_session_id')::int GROUP BY gp_segment_id;`
+	segmentActiveBackendsSql_V5 = `SELECT gp_segment_id, count(*) from gp_dist_random('pg_stat_activity')
+		where current_query <> '<IDLE>' and sess_id <> current_setting('gp_session_id')::int GROUP BY gp_segment_id;`
+)
+
+var (
+	segmentBackendSkewDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "segment_backend_skew_ratio"),
+		"Ratio of the active backends on the busiest primary segment to the average of all primary segments, 1 if there is no activity",
+		nil, nil,
+	)
+)
+
+func NewSegmentBackendsScraper() Scraper {
+	return segmentBackendsScraper{}
+}
+
+type segmentBackendsScraper struct{}
+
+func (segmentBackendsScraper) Name() string {
+	return "segment_backends_scraper"
+}
+
+func (segmentBackendsScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	segments, err := scrapeScalar(ctx, db, primarySegmentCountSql)
+	if err != nil {
+		return skipScalarNull(err)
+	}
+
+	querySql := segmentActiveBackendsSql_V6
+	if ver < 6 {
+		querySql = segmentActiveBackendsSql_V5
+	}
+
+	logger.Debugf("Query Database: %s", querySql)
+	rows, err := db.QueryContext(ctx, querySql)
+
+	if err != nil {
+		if isUndefinedObject(err) {
+			logger.Warnf("skip segment backend skew metrics, error:%v", err)
+			return nil
+		}
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	var max, total float64
+
+	for rows.Next() {
+		var segID int
+		var count float64
+
+		if err = rows.Scan(&segID, &count); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		total += count
+		if count > max {
+			max = count
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	// 没有活跃进程时视为均衡；没有活跃进程的segment不会出现在结果中，按primary segment总数求平均值
+	ratio := 1.0
+	if total > 0 && segments > 0 {
+		ratio = max / (total / segments)
+	}
+
+	ch <- prometheus.MustNewConstMetric(segmentBackendSkewDesc, prometheus.GaugeValue, ratio)
+
+	return combineErr(errs...)
+}
@@ -0,0 +1,171 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  segment磁盘空间抓取器：通过gp_toolkit.gp_disk_free获取coordinator及每个primary segment数据目录所在文件系统的剩余空间，
+ *  关联gp_segment_configuration输出主机名、content、角色和数据目录。gp_disk_free不提供文件系统的总大小，
+ *  剩余空间百分比使用gpperfmon数据库diskspace_now中相同主机、相同设备的总大小计算，没有gpperfmon数据库时不输出
+ */
+
+const (
+	// gp_disk_free的dfspace单位为KB
+	segmentDiskSql_V6 = `SELECT c.hostname, c.content, c.role, c.datadir, f.dfdevice, f.dfspace * 1024
+		from gp_toolkit.gp_disk_free f join gp_segment_configuration c on c.content = f.dfsegment and c.role = 'p';`
+	// Greenplum 5 的gp_segment_configuration中没有datadir字段，数据目录记录在pg_system文件空间中
+	segmentDiskSql_V5 = `SELECT c.hostname, c.content, c.role, e.fselocation, f.dfdevice, f.dfspace * 1024
+		from gp_toolkit.gp_disk_free f join gp_segment_configuration c on c.content = f.dfsegment and c.role = 'p'
+		join pg_filespace_entry e on e.fsedbid = c.dbid
+		join pg_filespace s on s.oid = e.fsefsoid and s.fsname = 'pg_system';`
+	gpperfmonExistsSql = `SELECT count(*) from pg_database where datname = $1;`
+	diskspaceTotalSql  = `SELECT hostname, filesystem, total_bytes from diskspace_now;`
+)
+
+var (
+	segmentDiskFreeBytesDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_disk_free_bytes"),
+		"Free bytes of the filesystem holding the data directory of each coordinator and primary segment",
+		[]string{"hostname", "content", "role", "datadir"}, nil,
+	)
+
+	segmentDiskFreePercentDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_disk_free_percent"),
+		"Percent of free space of the filesystem holding the data directory, requires the gpperfmon database",
+		[]string{"hostname", "content", "role", "datadir"}, nil,
+	)
+)
+
+func NewSegmentDiskScraper(conns *ConnectionCache) Scraper {
+	return segmentDiskScraper{conns: conns}
+}
+
+type segmentDiskScraper struct {
+	conns *ConnectionCache
+}
+
+func (segmentDiskScraper) Name() string {
+	return "segment_disk_scraper"
+}
+
+type segmentDiskFree struct {
+	hostname, content, role, datadir, device string
+	free                                     float64
+}
+
+func (s segmentDiskScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	disks, err := querySegmentDiskFree(ctx, db, ver)
+	if err != nil {
+		if isUndefinedObject(err) {
+			return fmt.Errorf("gp_toolkit.gp_disk_free is not available, check that the gp_toolkit schema is installed: %w", err)
+		}
+		return err
+	}
+
+	for _, d := range disks {
+		ch <- prometheus.MustNewConstMetric(segmentDiskFreeBytesDesc, prometheus.GaugeValue, d.free, d.hostname, d.content, d.role, d.datadir)
+	}
+
+	totals, err := s.queryDiskspaceTotal(ctx, db)
+	if err != nil {
+		if isUndefinedObject(err) {
+			logger.Warnf("skip segment disk free percent metrics, error:%v", err)
+			return nil
+		}
+		return wrapErr("segment_disk_free_percent", err)
+	}
+
+	for _, d := range disks {
+		total := totals[d.hostname+"/"+d.device]
+		if total <= 0 {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(segmentDiskFreePercentDesc, prometheus.GaugeValue, d.free*100/total, d.hostname, d.content, d.role, d.datadir)
+	}
+
+	return nil
+}
+
+func querySegmentDiskFree(ctx context.Context, db Queryer, ver int) ([]segmentDiskFree, error) {
+	querySql := segmentDiskSql_V6
+	if ver < 6 {
+		querySql = segmentDiskSql_V5
+	}
+
+	logger.Debugf("Query Database: %s", querySql)
+	rows, err := db.QueryContext(ctx, querySql)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	disks := make([]segmentDiskFree, 0)
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var d segmentDiskFree
+		var datadir sql.NullString
+
+		if err = rows.Scan(&d.hostname, &d.content, &d.role, &datadir, &d.device, &d.free); err != nil {
+			errs = append(errs, fmt.Errorf("unexpected row from gp_toolkit.gp_disk_free: %w", err))
+			continue
+		}
+
+		d.datadir = datadir.String
+		disks = append(disks, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return disks, combineErr(errs...)
+}
+
+/**
+* 函数：queryDiskspaceTotal
+* 功能：从gpperfmon的diskspace_now获取各主机各设备的文件系统总大小，键为"主机名/设备"，没有gpperfmon数据库时返回空
+ */
+func (s segmentDiskScraper) queryDiskspaceTotal(ctx context.Context, db Queryer) (map[string]float64, error) {
+	totals := make(map[string]float64)
+
+	// 先在coordinator上确认gpperfmon数据库存在，避免每次抓取都尝试连接不存在的数据库
+	exists, err := scrapeScalar(ctx, db, gpperfmonExistsSql, gpperfmonDatabase())
+	if err != nil || exists == 0 {
+		return totals, err
+	}
+
+	conn, err := openGpperfmon(ctx, s.conns)
+	if err != nil {
+		return totals, err
+	}
+
+	logger.Debugf("Query Database: %s", diskspaceTotalSql)
+	rows, err := conn.QueryContext(ctx, diskspaceTotalSql)
+	if err != nil {
+		return totals, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var hostname, filesystem string
+		var total float64
+
+		if err = rows.Scan(&hostname, &filesystem, &total); err != nil {
+			return totals, err
+		}
+
+		totals[hostname+"/"+filesystem] = total
+	}
+
+	return totals, rows.Err()
+}
@@ -0,0 +1,107 @@
+package collector
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  segment磁盘错误抓取器：通过gp_toolkit.gp_log_system统计最近GPDB_LOG_DISK_ERROR_WINDOW_MINUTES
+ *  (默认60，最大1440)分钟内每个主机日志中磁盘/IO错误的条数，磁盘硬件错误通常是segment即将故障的前兆
+ */
+
+const (
+	diskErrorWindowEnv = "GPDB_LOG_DISK_ERROR_WINDOW_MINUTES"
+
+	// 读取日志的抓取器统计时间窗口的默认值和最大值(分钟)
+	defaultLogWindowMinutes = 60
+	maxLogWindowMinutes     = 24 * 60
+
+	segmentDiskErrorsSql = `SELECT loghost, count(*) from gp_toolkit.gp_log_system
+		where logtime > now() - $1::int * interval '1 minute'
+		and (logmessage ilike '%input/output error%'
+			or logmessage ilike '%could not read block%'
+			or logmessage ilike '%could not write block%'
+			or logmessage ilike '%could not fsync%'
+			or logmessage ilike '%read-only file system%')
+		GROUP BY loghost;`
+)
+
+var (
+	segmentDiskErrorsDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemNode, "segment_disk_errors_recent"),
+		"Number of disk or I/O error log lines of each host within GPDB_LOG_DISK_ERROR_WINDOW_MINUTES",
+		[]string{"hostname"}, nil,
+	)
+)
+
+func NewSegmentDiskErrorsScraper() Scraper {
+	return segmentDiskErrorsScraper{windowMinutes: logWindowMinutes(diskErrorWindowEnv)}
+}
+
+/**
+* 函数：logWindowMinutes
+* 功能：读取日志统计时间窗口(分钟)，未设置或不大于0时使用默认值60，超过1440时使用1440
+ */
+func logWindowMinutes(env string) int {
+	window := envInt(env, defaultLogWindowMinutes)
+	if window <= 0 {
+		logger.Warnf("invalid value %d for %s, use default %d", window, env, defaultLogWindowMinutes)
+		return defaultLogWindowMinutes
+	}
+
+	if window > maxLogWindowMinutes {
+		logger.Warnf("value %d for %s exceeds the maximum, use %d", window, env, maxLogWindowMinutes)
+		return maxLogWindowMinutes
+	}
+
+	return window
+}
+
+type segmentDiskErrorsScraper struct {
+	windowMinutes int
+}
+
+// 需要读取所有segment上的日志文件，耗时较长
+func (segmentDiskErrorsScraper) Timeout() time.Duration {
+	return time.Second * 10
+}
+
+func (segmentDiskErrorsScraper) Name() string {
+	return "segment_disk_errors_scraper"
+}
+
+func (s segmentDiskErrorsScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	logger.Debugf("Query Database: %s", segmentDiskErrorsSql)
+	rows, err := db.QueryContext(ctx, segmentDiskErrorsSql, s.windowMinutes)
+
+	if err != nil {
+		// gp_toolkit未安装时跳过
+		if isUndefinedObject(err) {
+			logger.Warnf("skip segment disk error metrics, error:%v", err)
+			return nil
+		}
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var hostname string
+		var count float64
+
+		if err = rows.Scan(&hostname, &count); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(segmentDiskErrorsDesc, prometheus.GaugeValue, count, hostname)
+	}
+
+	return combineErr(errs...)
+}
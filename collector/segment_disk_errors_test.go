@@ -0,0 +1,26 @@
+package collector
+
+import "testing"
+
+func TestLogWindowMinutes(t *testing.T) {
+	for value, expected := range map[string]int{
+		"":      defaultLogWindowMinutes,
+		"abc":   defaultLogWindowMinutes,
+		"0":     defaultLogWindowMinutes,
+		"-5":    defaultLogWindowMinutes,
+		"30":    30,
+		"1440":  1440,
+		"10000": maxLogWindowMinutes,
+	} {
+		setEnv(t, diskErrorWindowEnv, value)
+
+		if window := logWindowMinutes(diskErrorWindowEnv); window != expected {
+			t.Errorf("value %q: expected %d, got %d", value, expected, window)
+		}
+	}
+
+	setEnv(t, diskErrorWindowEnv, "-1")
+	if s := NewSegmentDiskErrorsScraper().(segmentDiskErrorsScraper); s.windowMinutes != defaultLogWindowMinutes {
+		t.Errorf("expected the default window for a negative value, got %d", s.windowMinutes)
+	}
+}
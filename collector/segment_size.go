@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  segment数据量抓取器：通过gp_dist_random在每个primary segment上执行pg_database_size，
+ *  统计数据量最大的segment及其与平均值的比值，用于发现物理倾斜导致的单个segment磁盘写满。
+ *  需要遍历所有数据库的数据目录，数据库较多时开销较大，默认不开启
+ */
+
+const (
+	segmentSizeSql = `SELECT gp_segment_id, sum(pg_database_size(datname)) from gp_dist_random('pg_database') GROUP BY gp_segment_id;`
+)
+
+var (
+	maxSegmentSizeDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "max_segment_size_bytes"),
+		"Total size of all databases on the largest primary segment",
+		nil, nil,
+	)
+
+	segmentSizeSkewDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "segment_size_skew_ratio"),
+		"Ratio of the largest primary segment data size to the average of all primary segments",
+		nil, nil,
+	)
+)
+
+func NewSegmentSizeScraper() Scraper {
+	return segmentSizeScraper{}
+}
+
+type segmentSizeScraper struct{}
+
+func (segmentSizeScraper) Name() string {
+	return "segment_size_scraper"
+}
+
+func (segmentSizeScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	logger.Debugf("Query Database: %s", segmentSizeSql)
+	rows, err := db.QueryContext(ctx, segmentSizeSql)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	segments := 0
+	var max, total float64
+
+	for rows.Next() {
+		var segID int
+		var size float64
+
+		if err = rows.Scan(&segID, &size); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		segments++
+		total += size
+		if size > max {
+			max = size
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if segments > 0 {
+		ch <- prometheus.MustNewConstMetric(maxSegmentSizeDesc, prometheus.GaugeValue, max)
+
+		if avg := total / float64(segments); avg > 0 {
+			ch <- prometheus.MustNewConstMetric(segmentSizeSkewDesc, prometheus.GaugeValue, max/avg)
+		}
+	}
+
+	return combineErr(errs...)
+}
@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSegmentScraperErrors(t *testing.T) {
+	setEnv(t, sqlOverrideEnvPrefix+"SEGMENT_DISK_FREE", "")
+
+	t.Run("query_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectFailedQueries(mock, segmentConfigSql_V6, segmentDiskFreeSizeSql, coordinatorDiskFreeSql, segmentUpTimeSql,
+			unbalancedHostsSql, readonlySegmentsSql, primarySegmentsUpSql, segmentClockSkewSql, recoveringSegmentsSql_V6,
+			colocatedPairsSql, invalidSegmentConfigSql_V6, ftsLastChangeSql, redundancyPercentSql)
+
+		err := collectAndCompare(t, NewSegmentScraper(), db, 6, "")
+		expectErrLabels(t, err, "segment_disk_free", "segment_config", "coordinator_disk_free", "segment_uptime",
+			"unbalanced_hosts", "readonly_segments", "primary_segments_up", "segment_clock_skew", "recovering_segments",
+			"colocated_primary_mirror_pairs", "segments_invalid_config", "fts_last_change", "redundancy_percent")
+	})
+
+	// 出错的行被跳过，其余行照常输出
+	t.Run("scan_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(segmentConfigSql_V5)).WillReturnRows(sqlmock.NewRows(
+			[]string{"dbid", "content", "role", "preferred_role", "mode", "status", "port", "hostname", "address", "datadir"}).
+			AddRow("2", "0", "p", "p", "s", "u", "6000", nil, "sdw1", nil).
+			AddRow("3", "1", "p", "p", "s", "u", "6001", "sdw2", "sdw2", nil))
+		mock.ExpectQuery(regexp.QuoteMeta(segmentDiskFreeSizeSql)).WillReturnRows(sqlmock.NewRows(
+			[]string{"segment_hostname", "segment_disk_free_gb"}).AddRow("sdw1", "abc"))
+		mock.ExpectQuery(regexp.QuoteMeta(coordinatorDiskFreeSql)).WillReturnRows(sqlmock.NewRows(
+			[]string{"dfhostname", "dfdevice", "dfspace"}))
+		mock.ExpectQuery(regexp.QuoteMeta(segmentUpTimeSql)).WillReturnRows(sqlmock.NewRows(
+			[]string{"gp_segment_id", "uptime"}))
+		mock.ExpectQuery(regexp.QuoteMeta(unbalancedHostsSql)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow("abc"))
+		expectEmptyQueries(mock, readonlySegmentsSql, primarySegmentsUpSql, segmentClockSkewSql, recoveringSegmentsSql_V5,
+			colocatedPairsSql, invalidSegmentConfigSql_V5, ftsLastChangeSql, redundancyPercentSql)
+
+		expected := `
+# HELP greenplum_node_segment_status UP(1) if the segment is running, DOWN(0) if the segment has failed or is unreachable
+# TYPE greenplum_node_segment_status gauge
+greenplum_node_segment_status{address="sdw2",content="1",data_dir="",dbid="3",dc="",hostname="sdw2",port="6001",preferred_role="p",rack=""} 1
+`
+		err := collectAndCompare(t, NewSegmentScraper(), db, 5, expected, "greenplum_node_segment_status")
+		expectErrLabels(t, err, "segment_config", "segment_disk_free", "unbalanced_hosts")
+	})
+
+	// 没有数据行或单值查询返回NULL时不输出，也不报错
+	t.Run("empty", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectEmptyQueries(mock, segmentConfigSql_V6)
+		mock.ExpectQuery(regexp.QuoteMeta(segmentDiskFreeSizeSql)).WillReturnRows(sqlmock.NewRows(
+			[]string{"segment_hostname", "segment_disk_free_gb"}))
+		expectEmptyQueries(mock, coordinatorDiskFreeSql, segmentUpTimeSql, unbalancedHostsSql, readonlySegmentsSql)
+		mock.ExpectQuery(regexp.QuoteMeta(primarySegmentsUpSql)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(nil))
+		expectEmptyQueries(mock, segmentClockSkewSql, recoveringSegmentsSql_V6, colocatedPairsSql, invalidSegmentConfigSql_V6,
+			ftsLastChangeSql, redundancyPercentSql)
+
+		if err := collectAndCompare(t, NewSegmentScraper(), db, 6, ""); err != nil {
+			t.Errorf("unexpected scrape error: %v", err)
+		}
+	})
+}
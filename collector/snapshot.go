@@ -0,0 +1,100 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  一致性快照：设置GPDB_CONSISTENT_SNAPSHOT=true后，所有抓取器在coordinator连接上的查询都在同一个
+ *  只读的REPEATABLE READ事务中执行，各指标反映同一时刻的数据。快照期间从连接池中固定一个连接并在其上开启事务，
+ *  抓取器通过该事务查询，与连接池的大小无关；连接断开时事务中的查询返回错误，不会在其它连接上继续执行。
+ *  每个抓取器在保存点中执行，出错(包括被跳过的错误)后回滚到保存点，不影响后续的抓取器。按库循环的抓取器使用各自的连接，不在快照范围内
+ */
+
+const (
+	consistentSnapshotEnv = "GPDB_CONSISTENT_SNAPSHOT"
+
+	savepointSql         = `SAVEPOINT greenplum_exporter;`
+	rollbackSavepointSql = `ROLLBACK TO SAVEPOINT greenplum_exporter;`
+	releaseSavepointSql  = `RELEASE SAVEPOINT greenplum_exporter;`
+
+	snapshotSqlTimeout = time.Second * 2
+)
+
+type snapshot struct {
+	conn *sql.Conn
+	tx   *sql.Tx
+}
+
+/**
+* 函数：beginSnapshot
+* 功能：从coordinator连接池中固定一个连接，并在其上开启只读的REPEATABLE READ事务
+ */
+func beginSnapshot(db *sql.DB) (*snapshot, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotSqlTimeout)
+
+	defer cancel()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 事务在整个快照期间有效，不能使用带超时的上下文，否则超时后事务被回滚
+	logger.Debugf("Query Database: BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY")
+	tx, err := conn.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return &snapshot{conn: conn, tx: tx}, nil
+}
+
+/**
+* 函数：run
+* 功能：在保存点中以快照事务执行抓取器，结束后回滚到保存点，抓取器的错误不会使整个事务失效
+ */
+func (s *snapshot) run(scrape func(db Queryer) error) error {
+	if err := s.exec(savepointSql); err != nil {
+		return err
+	}
+
+	err := scrape(s.tx)
+
+	if errR := s.exec(rollbackSavepointSql); errR != nil {
+		logger.Errorf("rollback to snapshot savepoint failed, error:%v", errR)
+	} else if errR = s.exec(releaseSavepointSql); errR != nil {
+		logger.Errorf("release snapshot savepoint failed, error:%v", errR)
+	}
+
+	return err
+}
+
+/**
+* 函数：end
+* 功能：结束一致性快照事务并将固定的连接归还连接池
+ */
+func (s *snapshot) end() {
+	logger.Debugf("Query Database: COMMIT")
+	if err := s.tx.Commit(); err != nil {
+		logger.Errorf("end consistent snapshot failed, error:%v", err)
+	}
+
+	_ = s.conn.Close()
+}
+
+func (s *snapshot) exec(query string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotSqlTimeout)
+
+	defer cancel()
+
+	logger.Debugf("Query Database: %s", query)
+	_, err := s.tx.ExecContext(ctx, query)
+
+	return err
+}
@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  SQL覆盖：部分Greenplum分支版本修改了视图或gp_toolkit函数，
+ *  可以通过环境变量GPDB_SQL_<NAME>替换抓取器内置的SQL，未设置时使用内置SQL
+ */
+
+const (
+	sqlOverrideEnvPrefix = "GPDB_SQL_"
+)
+
+// 支持覆盖的SQL，新增覆盖时需同时更新README中的列表
+var sqlOverrideNames = []string{
+	"DATABASE_SIZE",
+	"SEGMENT_DISK_FREE",
+}
+
+/**
+* 函数：CheckSqlOverrides
+* 功能：启动时检查设置的GPDB_SQL_<NAME>环境变量，不支持覆盖的名称不会生效，记录警告
+ */
+func CheckSqlOverrides() {
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, sqlOverrideEnvPrefix) {
+			continue
+		}
+
+		env := strings.SplitN(kv, "=", 2)[0]
+		if !isSqlOverrideName(strings.TrimPrefix(env, sqlOverrideEnvPrefix)) {
+			logger.Warnf("%s is ignored, only %s%s can be overridden", env, sqlOverrideEnvPrefix, strings.Join(sqlOverrideNames, ", "+sqlOverrideEnvPrefix))
+		}
+	}
+}
+
+func isSqlOverrideName(name string) bool {
+	for _, known := range sqlOverrideNames {
+		if name == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+type overridableSql struct {
+	env     string
+	query   string
+	columns int
+}
+
+/**
+* 函数：newOverridableSql
+* 功能：读取环境变量GPDB_SQL_<name>，columns为查询结果应有的列数
+ */
+func newOverridableSql(name string, builtin string, columns int) overridableSql {
+	env := sqlOverrideEnvPrefix + name
+
+	query := builtin
+	if value := os.Getenv(env); value != "" {
+		logger.Infof("use sql from %s instead of the builtin sql", env)
+		query = value
+	}
+
+	return overridableSql{env: env, query: query, columns: columns}
+}
+
+/**
+* 函数：checkColumns
+* 功能：检查查询结果的列数是否符合预期，覆盖的SQL不符合时返回明确的错误
+ */
+func (o overridableSql) checkColumns(rows *sql.Rows) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	if len(columns) != o.columns {
+		return fmt.Errorf("sql %q returns %d columns, expected %d, check the value of %s", o.query, len(columns), o.columns, o.env)
+	}
+
+	return nil
+}
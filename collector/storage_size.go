@@ -0,0 +1,131 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  按存储类型(heap、ao、aoco、external)统计每个用户数据库的大小，以及各表访问方法的表数量
+ */
+
+const (
+	storageSizeSql_V7 = `
+		SELECT case when c.relkind = 'f' then 'external'
+				when a.amname = 'ao_row' then 'ao'
+				when a.amname = 'ao_column' then 'aoco'
+				else 'heap' end storage_type,
+			sum(case when c.relkind = 'f' then 0 else pg_total_relation_size(c.oid) end), count(*)
+		FROM pg_class c
+		LEFT JOIN pg_am a on a.oid = c.relam
+		JOIN pg_namespace n on n.oid = c.relnamespace
+		WHERE c.relkind in ('r', 'm', 'f')
+			and n.nspname not in ('pg_catalog', 'information_schema', 'gp_toolkit')
+		GROUP BY 1;`
+	storageSizeSql_V6 = `
+		SELECT case c.relstorage when 'x' then 'external' when 'a' then 'ao' when 'c' then 'aoco' else 'heap' end storage_type,
+			sum(case when c.relstorage = 'x' then 0 else pg_total_relation_size(c.oid) end), count(*)
+		FROM pg_class c
+		JOIN pg_namespace n on n.oid = c.relnamespace
+		WHERE c.relkind in ('r', 'm')
+			and n.nspname not in ('pg_catalog', 'information_schema', 'gp_toolkit')
+		GROUP BY 1;`
+	storageSizeSql_V5 = `
+		SELECT case c.relstorage when 'x' then 'external' when 'a' then 'ao' when 'c' then 'aoco' else 'heap' end storage_type,
+			sum(case when c.relstorage = 'x' then 0 else pg_total_relation_size(c.oid) end), count(*)
+		FROM pg_class c
+		JOIN pg_namespace n on n.oid = c.relnamespace
+		WHERE c.relkind = 'r'
+			and n.nspname not in ('pg_catalog', 'information_schema', 'gp_toolkit')
+		GROUP BY 1;`
+)
+
+var (
+	storageTypes = []string{"heap", "ao", "aoco", "external"}
+
+	databaseSizeByStorageDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_size_by_storage_bytes"),
+		"Total size of the user tables in each database by storage type, external tables are always 0",
+		[]string{"dbname", "storage_type"}, nil,
+	)
+
+	// 存储类型对应的表访问方法名称(与Greenplum 7的pg_am一致)，外部表不属于表访问方法
+	storageAccessMethods = map[string]string{
+		"heap": "heap",
+		"ao":   "ao_row",
+		"aoco": "ao_column",
+	}
+
+	tablesByAccessMethodDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "tables_by_access_method"),
+		"Number of user tables in each database by table access method",
+		[]string{"dbname", "access_method"}, nil,
+	)
+)
+
+func NewStorageSizeScraper(conns *ConnectionCache) Scraper {
+	return storageSizeScraper{conns: conns}
+}
+
+type storageSizeScraper struct {
+	conns *ConnectionCache
+}
+
+func (storageSizeScraper) Name() string {
+	return "storage_size_scraper"
+}
+
+func (s storageSizeScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	// Greenplum 7 去掉了relstorage字段，改用表访问方法(pg_am)和外部表(foreign table)
+	querySql := storageSizeSql_V7
+	if ver < 6 {
+		querySql = storageSizeSql_V5
+	} else if ver < 7 {
+		querySql = storageSizeSql_V6
+	}
+
+	return forEachDatabase(ctx, db, s.conns, ch, func(ctx context.Context, conn *sql.DB, dbname string, ch chan<- prometheus.Metric) error {
+		logger.Debugf("Query Database: %s", querySql)
+		rows, err := conn.QueryContext(ctx, querySql)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		sizes := make(map[string]float64, len(storageTypes))
+		counts := make(map[string]float64, len(storageTypes))
+		errs := make([]error, 0)
+
+		for rows.Next() {
+			var storageType string
+			var size, count float64
+
+			if err := rows.Scan(&storageType, &size, &count); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			sizes[storageType] = size
+			counts[storageType] = count
+		}
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		// 数据库中没有某种存储类型的表时输出0，方便按存储类型汇总
+		for _, storageType := range storageTypes {
+			ch <- prometheus.MustNewConstMetric(databaseSizeByStorageDesc, prometheus.GaugeValue, sizes[storageType], dbname, storageType)
+
+			if accessMethod, ok := storageAccessMethods[storageType]; ok {
+				ch <- prometheus.MustNewConstMetric(tablesByAccessMethodDesc, prometheus.GaugeValue, counts[storageType], dbname, accessMethod)
+			}
+		}
+
+		return combineErr(errs...)
+	})
+}
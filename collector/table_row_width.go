@@ -0,0 +1,80 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  表平均行宽抓取器：按relpages取每个用户数据库中最大的GPDB_TABLE_ROW_WIDTH_TOP_N(默认10)张表，
+ *  用表大小除以reltuples估算平均行宽，未ANALYZE过(reltuples为0)的表跳过
+ */
+
+const (
+	tableRowWidthTopNEnv = "GPDB_TABLE_ROW_WIDTH_TOP_N"
+
+	tableRowWidthSql = `
+		SELECT current_database(), schema_name, table_name, pg_relation_size(oid) / reltuples
+		FROM (
+			SELECT c.oid, n.nspname schema_name, c.relname table_name, c.reltuples
+			FROM pg_class c
+				JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE c.relkind = 'r' AND c.reltuples > 0
+				AND n.nspname not in ('gp_toolkit', 'information_schema', 'pg_catalog')
+			ORDER BY c.relpages DESC
+			LIMIT $1
+		) t
+	`
+)
+
+var (
+	tableAvgRowBytesDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "table_avg_row_bytes"),
+		"Average row width in bytes estimated from size and reltuples of the largest tables in each database",
+		[]string{"dbname", "schema", "table"}, nil,
+	)
+)
+
+func NewTableRowWidthScraper(conns *ConnectionCache) Scraper {
+	return tableRowWidthScraper{topN: envInt(tableRowWidthTopNEnv, 10), conns: conns}
+}
+
+type tableRowWidthScraper struct {
+	topN  int
+	conns *ConnectionCache
+}
+
+func (tableRowWidthScraper) Name() string {
+	return "table_row_width_scraper"
+}
+
+func (s tableRowWidthScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	return forEachDatabase(ctx, db, s.conns, ch, func(ctx context.Context, conn *sql.DB, dbname string, ch chan<- prometheus.Metric) error {
+		logger.Debugf("Query Database: %s", tableRowWidthSql)
+		rows, err := conn.QueryContext(ctx, tableRowWidthSql, s.topN)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		errs := make([]error, 0)
+
+		for rows.Next() {
+			var dbname, schema, table string
+			var rowBytes float64
+
+			if err := rows.Scan(&dbname, &schema, &table, &rowBytes); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			ch <- prometheus.MustNewConstMetric(tableAvgRowBytesDesc, prometheus.GaugeValue, rowBytes, dbname, schema, table)
+		}
+
+		return combineErr(errs...)
+	})
+}
@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"hash/fnv"
+)
+
+/**
+ *  表抽样：表数量非常多时按GPDB_TABLE_SAMPLE_FRACTION(默认1，即不抽样)的比例只输出部分表的指标，
+ *  按库名、模式名和表名的哈希值抽样，每次抓取抽中的表相同，抽样后的指标只是近似值
+ */
+
+const (
+	tableSampleFractionEnv = "GPDB_TABLE_SAMPLE_FRACTION"
+
+	tableSampleBuckets = 10000
+)
+
+type tableSampler struct {
+	fraction float64
+}
+
+func newTableSampler() tableSampler {
+	fraction := envFloat(tableSampleFractionEnv, 1)
+	if fraction > 1 {
+		fraction = 1
+	}
+
+	return tableSampler{fraction: fraction}
+}
+
+/**
+* 函数：sampled
+* 功能：判断表是否被抽中
+ */
+func (t tableSampler) sampled(dbname, schema, table string) bool {
+	if t.fraction >= 1 {
+		return true
+	}
+
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(dbname + "." + schema + "." + table))
+
+	return float64(hash.Sum32()%tableSampleBuckets) < t.fraction*tableSampleBuckets
+}
@@ -0,0 +1,83 @@
+package collector
+
+import (
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+/**
+ *  表数量统计的模式过滤：--tables.excluded-schemas中的模式不计入表数量，与默认排除的系统模式一起生效；
+ *  设置了--tables.included-schemas时只统计其中的模式，并忽略排除列表。模式名以数组参数绑定到查询中，不拼接SQL
+ */
+
+const (
+	tableCountSql_Excluded = `SELECT count(*) as total from information_schema.tables
+		where table_schema not in ('gp_toolkit','information_schema','pg_catalog') and table_schema <> all($1::text[]);`
+	tableCountSql_Included = `SELECT count(*) as total from information_schema.tables
+		where table_schema = any($1::text[]);`
+	schemaTableCountSql_Excluded = `SELECT table_schema, count(*) from information_schema.tables
+		where table_schema not in ('gp_toolkit','information_schema','pg_catalog') and table_schema <> all($1::text[]) GROUP BY table_schema;`
+	schemaTableCountSql_Included = `SELECT table_schema, count(*) from information_schema.tables
+		where table_schema = any($1::text[]) GROUP BY table_schema;`
+)
+
+type tableSchemaFilter struct {
+	included []string
+	excluded []string
+}
+
+// 启动时由SetTableSchemas设置，默认只排除系统模式
+var tableSchemas tableSchemaFilter
+
+/**
+* 函数：SetTableSchemas
+* 功能：设置逗号分隔的统计表数量时包含和排除的模式，需在开始抓取之前调用
+ */
+func SetTableSchemas(included, excluded string) {
+	tableSchemas = tableSchemaFilter{
+		included: splitSchemas(included),
+		excluded: splitSchemas(excluded),
+	}
+}
+
+/**
+* 函数：splitSchemas
+* 功能：按逗号拆分模式名并去掉首尾空白，模式名按原样与information_schema.tables中的table_schema比较，区分大小写
+ */
+func splitSchemas(list string) []string {
+	schemas := make([]string, 0)
+
+	for _, schema := range strings.Split(list, ",") {
+		schema = strings.TrimSpace(schema)
+		if schema != "" {
+			schemas = append(schemas, schema)
+		}
+	}
+
+	return schemas
+}
+
+/**
+* 函数：tableCount
+* 功能：获取统计表数量的查询语句及其参数
+ */
+func (f tableSchemaFilter) tableCount() (string, interface{}) {
+	if len(f.included) > 0 {
+		return tableCountSql_Included, pq.Array(f.included)
+	}
+
+	return tableCountSql_Excluded, pq.Array(f.excluded)
+}
+
+/**
+* 函数：schemaTableCount
+* 功能：获取按模式统计表数量的查询语句及其参数
+ */
+func (f tableSchemaFilter) schemaTableCount() (string, interface{}) {
+	if len(f.included) > 0 {
+		return schemaTableCountSql_Included, pq.Array(f.included)
+	}
+
+	return schemaTableCountSql_Excluded, pq.Array(f.excluded)
+}
@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func setTableSchemas(t *testing.T, included, excluded string) {
+	origin := tableSchemas
+	SetTableSchemas(included, excluded)
+
+	t.Cleanup(func() {
+		tableSchemas = origin
+	})
+}
+
+func TestTableSchemaFilter(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		included string
+		excluded string
+		sql      string
+		schemas  driver.Value
+	}{
+		{name: "empty", sql: tableCountSql_Excluded, schemas: "{}"},
+		{name: "blank", excluded: " , ,", sql: tableCountSql_Excluded, schemas: "{}"},
+		{name: "single_excluded", excluded: "etl_stage", sql: tableCountSql_Excluded, schemas: `{"etl_stage"}`},
+		{name: "multiple_excluded", excluded: " etl_stage , tmp ", sql: tableCountSql_Excluded, schemas: `{"etl_stage","tmp"}`},
+		{name: "single_included", included: "public", sql: tableCountSql_Included, schemas: `{"public"}`},
+		// 设置了包含列表时忽略排除列表
+		{name: "included_precedence", included: "public", excluded: "etl_stage", sql: tableCountSql_Included, schemas: `{"public"}`},
+		// 模式名作为数组元素转义，不会拼接到SQL中
+		{
+			name:     "special_characters",
+			excluded: `o'brien, "Stage", a\b, x); drop table t; --`,
+			sql:      tableCountSql_Excluded,
+			schemas:  `{"o'brien","\"Stage\"","a\\b","x); drop table t; --"}`,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			setTableSchemas(t, c.included, c.excluded)
+
+			query, schemas := tableSchemas.tableCount()
+			if query != c.sql {
+				t.Errorf("unexpected table count sql %s", query)
+			}
+
+			value, err := schemas.(driver.Valuer).Value()
+			if err != nil {
+				t.Fatalf("encode schemas failed: %v", err)
+			}
+
+			// 空列表按{}传入而不是NULL，使<> all($1)对所有模式成立
+			if value != c.schemas {
+				t.Errorf("expected schemas %v, got %v", c.schemas, value)
+			}
+
+			schemaQuery, schemaArg := tableSchemas.schemaTableCount()
+			if strings.Contains(query, "etl_stage") || strings.Contains(schemaQuery, "etl_stage") {
+				t.Error("schema names must not be concatenated into the sql")
+			}
+			if schemaValue, _ := schemaArg.(driver.Valuer).Value(); schemaValue != value {
+				t.Errorf("schema table count binds %v, table count binds %v", schemaValue, value)
+			}
+		})
+	}
+}
+
+// 模式列表以参数绑定到查询中
+func TestQueryTablesCountBindsSchemas(t *testing.T) {
+	setTableSchemas(t, "", `etl_stage,o'brien`)
+
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta(tableCountSql_Excluded)).WithArgs(`{"etl_stage","o'brien"}`).
+		WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow(3))
+
+	expected := `
+# HELP greenplum_node_database_table_total_count Total table count of each database name in the file system
+# TYPE greenplum_node_database_table_total_count gauge
+greenplum_node_database_table_total_count{dbname="sales"} 3
+`
+	scrape := scrapeFunc(func(ctx context.Context, _ Queryer, ch chan<- prometheus.Metric, ver int) error {
+		return queryTablesCount(ctx, db, ch, "sales")
+	})
+	if err := collectAndCompare(t, scrape, nil, 6, expected); err != nil {
+		t.Errorf("unexpected scrape error: %v", err)
+	}
+}
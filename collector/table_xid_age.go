@@ -0,0 +1,76 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  表事务ID年龄抓取器：每个用户数据库中age(relfrozenxid)最大的GPDB_TABLE_XID_AGE_TOP_N(默认10)张表，
+ *  用于定位导致数据库事务ID回卷风险的表；AO表的relfrozenxid无效，不统计
+ */
+
+const (
+	tableXidAgeTopNEnv = "GPDB_TABLE_XID_AGE_TOP_N"
+
+	tableXidAgeSql = `
+		SELECT current_database(), n.nspname, c.relname, age(c.relfrozenxid)
+		FROM pg_class c
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind in ('r', 'm', 't') AND c.relfrozenxid <> '0'::xid
+		ORDER BY age(c.relfrozenxid) DESC
+		LIMIT $1
+	`
+)
+
+var (
+	tableXidAgeDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "table_xid_age"),
+		"Age of relfrozenxid of the oldest tables in each database",
+		[]string{"dbname", "schema", "table"}, nil,
+	)
+)
+
+func NewTableXidAgeScraper(conns *ConnectionCache) Scraper {
+	return tableXidAgeScraper{topN: envInt(tableXidAgeTopNEnv, 10), conns: conns}
+}
+
+type tableXidAgeScraper struct {
+	topN  int
+	conns *ConnectionCache
+}
+
+func (tableXidAgeScraper) Name() string {
+	return "table_xid_age_scraper"
+}
+
+func (s tableXidAgeScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	return forEachDatabase(ctx, db, s.conns, ch, func(ctx context.Context, conn *sql.DB, dbname string, ch chan<- prometheus.Metric) error {
+		logger.Debugf("Query Database: %s", tableXidAgeSql)
+		rows, err := conn.QueryContext(ctx, tableXidAgeSql, s.topN)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		errs := make([]error, 0)
+
+		for rows.Next() {
+			var dbname, schema, table string
+			var age float64
+
+			if err := rows.Scan(&dbname, &schema, &table, &age); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			ch <- prometheus.MustNewConstMetric(tableXidAgeDesc, prometheus.GaugeValue, age, dbname, schema, table)
+		}
+
+		return combineErr(errs...)
+	})
+}
@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/**
+ *  临时模式抓取器：统计每个用户数据库中pg_temp_*、pg_toast_temp_*模式的数量，会话异常退出后可能残留
+ */
+
+const (
+	tempSchemasSql = `SELECT count(*) from pg_namespace where nspname ~ '^pg_(toast_)?temp_[0-9]+$';`
+)
+
+var (
+	tempSchemasDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "temp_schemas"),
+		"Number of temporary schemas(pg_temp_* and pg_toast_temp_*) in each database",
+		[]string{"dbname"}, nil,
+	)
+)
+
+func NewTempSchemasScraper(conns *ConnectionCache) Scraper {
+	return tempSchemasScraper{conns: conns}
+}
+
+type tempSchemasScraper struct {
+	conns *ConnectionCache
+}
+
+func (tempSchemasScraper) Name() string {
+	return "temp_schemas_scraper"
+}
+
+func (s tempSchemasScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	return forEachDatabase(ctx, db, s.conns, ch, func(ctx context.Context, conn *sql.DB, dbname string, ch chan<- prometheus.Metric) error {
+		return skipScalarNull(scrapeScalarGauge(ctx, conn, ch, tempSchemasDesc, tempSchemasSql, dbname))
+	})
+}
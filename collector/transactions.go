@@ -0,0 +1,156 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  事务抓取器：事务速率(TPS)根据两次抓取间xact_commit+xact_rollback的差值计算，以及最老的xmin年龄、
+ *  每个数据库中事务时长超过GPDB_LONG_TRANSACTION_SECONDS(默认300秒)的连接数
+ */
+
+const (
+	longTransactionSecondsEnv = "GPDB_LONG_TRANSACTION_SECONDS"
+
+	totalTransactionsSql   = `select sum(xact_commit + xact_rollback) from pg_stat_database;`
+	longTransactionsSql_V6 = `select datname, sum(case when xact_start < now() - $1::int * interval '1 second' then 1 else 0 end)
+                              from pg_stat_activity where pid <> pg_backend_pid() and datname is not null group by datname;`
+	longTransactionsSql_V5 = `select datname, sum(case when xact_start < now() - $1::int * interval '1 second' then 1 else 0 end)
+                              from pg_stat_activity where procpid <> pg_backend_pid() and datname is not null group by datname;`
+	oldestXminAgeSql_V6 = `select coalesce(max(greatest(age(backend_xmin), age(backend_xid))), 0) from pg_stat_activity where pid <> pg_backend_pid();`
+	// Greenplum不允许用户执行PREPARE TRANSACTION，coordinator上的预提交事务均为两阶段提交中的分布式事务，
+	// 正常情况下只存在很短的时间，超过1分钟仍未完成的视为待人工处理的悬挂事务
+	indoubtTransactionsSql = `select count(*) from pg_prepared_xacts where prepared < now() - interval '1 minute';`
+)
+
+var (
+	transactionsPerSecondDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "transactions_per_second"),
+		"Transactions per second committed or rolled back since the previous scrape",
+		nil, nil,
+	)
+
+	oldestXminAgeDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "oldest_xmin_age"),
+		"Age in transactions of the oldest xmin or xid held by any backend, 0 if none is held",
+		nil, nil,
+	)
+
+	longTransactionsDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_long_transactions"),
+		"Number of backends in each database whose transaction started longer than GPDB_LONG_TRANSACTION_SECONDS ago",
+		[]string{"datname"}, nil,
+	)
+
+	indoubtTransactionsDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemCluster, "indoubt_transactions"),
+		"Number of distributed transactions prepared on the coordinator more than one minute ago and not yet resolved",
+		nil, nil,
+	)
+)
+
+func NewTransactionsScraper() Scraper {
+	return &transactionsScraper{longTransactionSeconds: envInt(longTransactionSecondsEnv, 300)}
+}
+
+type transactionsScraper struct {
+	mu sync.Mutex
+
+	longTransactionSeconds int
+
+	lastTotal float64
+	lastTime  time.Time
+}
+
+func (*transactionsScraper) Name() string {
+	return "transactions_scraper"
+}
+
+func (s *transactionsScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	errT := s.scrapeTransactionsPerSecond(ctx, db, ch)
+	errX := scrapeOldestXminAge(ctx, db, ch, ver)
+	errL := scrapeLongTransactions(ctx, db, ch, ver, s.longTransactionSeconds)
+	errI := scrapeIndoubtTransactions(ctx, db, ch)
+
+	return combineErr(
+		wrapErr("transactions_per_second", errT),
+		wrapErr("oldest_xmin_age", errX),
+		wrapErr("long_transactions", errL),
+		wrapErr("indoubt_transactions", errI),
+	)
+}
+
+func (s *transactionsScraper) scrapeTransactionsPerSecond(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	total, err := scrapeScalar(ctx, db, totalTransactionsSql)
+	if err != nil {
+		return skipScalarNull(err)
+	}
+
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 首次抓取或计数器被重置时只记录样本，不发送指标
+	if !s.lastTime.IsZero() && total >= s.lastTotal {
+		elapsed := now.Sub(s.lastTime).Seconds()
+		if elapsed > 0 {
+			ch <- prometheus.MustNewConstMetric(transactionsPerSecondDesc, prometheus.GaugeValue, (total-s.lastTotal)/elapsed)
+		}
+	}
+
+	s.lastTotal = total
+	s.lastTime = now
+
+	return nil
+}
+
+func scrapeOldestXminAge(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	// Greenplum 5 的pg_stat_activity中没有backend_xmin、backend_xid字段
+	if ver < 6 {
+		return nil
+	}
+
+	return skipScalarNull(scrapeScalarGauge(ctx, db, ch, oldestXminAgeDesc, oldestXminAgeSql_V6))
+}
+
+func scrapeIndoubtTransactions(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	return skipScalarNull(scrapeScalarGauge(ctx, db, ch, indoubtTransactionsDesc, indoubtTransactionsSql))
+}
+
+func scrapeLongTransactions(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int, longTransactionSeconds int) error {
+	querySql := longTransactionsSql_V6
+	if ver < 6 {
+		querySql = longTransactionsSql_V5
+	}
+
+	logger.Debugf("Query Database: %s", querySql)
+	rows, err := db.QueryContext(ctx, querySql, longTransactionSeconds)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var datname string
+		var count float64
+
+		err = rows.Scan(&datname, &count)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(longTransactionsDesc, prometheus.GaugeValue, count, datname)
+	}
+
+	return combineErr(errs...)
+}
@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestTransactionsScraperErrors(t *testing.T) {
+	setEnv(t, longTransactionSecondsEnv, "")
+
+	t.Run("query_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectFailedQueries(mock, totalTransactionsSql, oldestXminAgeSql_V6, longTransactionsSql_V6, indoubtTransactionsSql)
+
+		err := collectAndCompare(t, NewTransactionsScraper(), db, 6, "")
+		expectErrLabels(t, err, "transactions_per_second", "oldest_xmin_age", "long_transactions", "indoubt_transactions")
+	})
+
+	// 出错的行被跳过，其余行照常输出
+	t.Run("scan_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(totalTransactionsSql)).WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow("abc"))
+		mock.ExpectQuery(regexp.QuoteMeta(longTransactionsSql_V5)).WithArgs(300).WillReturnRows(sqlmock.NewRows(
+			[]string{"datname", "count"}).
+			AddRow("sales", "abc").
+			AddRow("orders", 2))
+		mock.ExpectQuery(regexp.QuoteMeta(indoubtTransactionsSql)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow("abc"))
+
+		expected := `
+# HELP greenplum_server_database_long_transactions Number of backends in each database whose transaction started longer than GPDB_LONG_TRANSACTION_SECONDS ago
+# TYPE greenplum_server_database_long_transactions gauge
+greenplum_server_database_long_transactions{datname="orders"} 2
+`
+		err := collectAndCompare(t, NewTransactionsScraper(), db, 5, expected)
+		expectErrLabels(t, err, "transactions_per_second", "long_transactions", "indoubt_transactions")
+	})
+
+	// 首次抓取只记录样本，没有数据行或返回NULL时不输出，也不报错
+	t.Run("empty", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(totalTransactionsSql)).WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(nil))
+		expectEmptyQueries(mock, oldestXminAgeSql_V6, longTransactionsSql_V6, indoubtTransactionsSql)
+
+		if err := collectAndCompare(t, NewTransactionsScraper(), db, 6, ""); err != nil {
+			t.Errorf("unexpected scrape error: %v", err)
+		}
+	})
+}
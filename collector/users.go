@@ -1,9 +1,9 @@
 package collector
 
 import (
-	"database/sql"
+	"context"
 	"github.com/prometheus/client_golang/prometheus"
-	logger "github.com/prometheus/common/log"
+	logger "greenplum-exporter/logging"
 )
 
 /**
@@ -12,22 +12,38 @@ import (
 
 const (
 	usersSql = `SELECT usename from pg_catalog.pg_user;`
+	// 包括不能登录的角色(组)
+	roleCountsSql = `SELECT count(*), sum(case when rolsuper then 1 else 0 end) from pg_catalog.pg_roles;`
 )
 
 var (
-	usersCountDesc = prometheus.NewDesc(
+	usersCountDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemServer, "users_total_count"),
 		"Total user account number for current greenplum database",
 		nil,
 		nil,
 	)
 
-	usersNameDesc = prometheus.NewDesc(
+	usersNameDesc = newDesc(
 		prometheus.BuildFQName(namespace, subSystemServer, "users_name_list"),
 		"Each user account name for current greenplum database",
 		[]string{"username"},
 		nil,
 	)
+
+	rolesTotalDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "roles_total"),
+		"Total number of roles including those that cannot login",
+		nil,
+		nil,
+	)
+
+	superuserRolesDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "superuser_roles"),
+		"Number of roles with the superuser attribute",
+		nil,
+		nil,
+	)
 )
 
 func NewUsersScraper() Scraper {
@@ -40,9 +56,16 @@ func (usersScraper) Name() string {
 	return "users_scraper"
 }
 
-func (usersScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric, ver int) error {
-	rows, err := db.Query(usersSql)
-	logger.Infof("Query Database: %s", usersSql)
+func (usersScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	errU := scrapeUsers(ctx, db, ch)
+	errR := scrapeRoleCounts(ctx, db, ch)
+
+	return combineErr(wrapErr("users", errU), wrapErr("role_counts", errR))
+}
+
+func scrapeUsers(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	rows, err := db.QueryContext(ctx, usersSql)
+	logger.Debugf("Query Database: %s", usersSql)
 
 	if err != nil {
 		return err
@@ -71,3 +94,17 @@ func (usersScraper) Scrape(db *sql.DB, ch chan<- prometheus.Metric, ver int) err
 
 	return combineErr(errs...)
 }
+
+func scrapeRoleCounts(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	logger.Debugf("Query Database: %s", roleCountsSql)
+
+	var total, superusers float64
+	if err := db.QueryRowContext(ctx, roleCountsSql).Scan(&total, &superusers); err != nil {
+		return err
+	}
+
+	ch <- prometheus.MustNewConstMetric(rolesTotalDesc, prometheus.GaugeValue, total)
+	ch <- prometheus.MustNewConstMetric(superuserRolesDesc, prometheus.GaugeValue, superusers)
+
+	return nil
+}
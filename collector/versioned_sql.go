@@ -0,0 +1,50 @@
+package collector
+
+import (
+	"sort"
+	"sync"
+
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  按Greenplum主版本选择查询语句：键为引入该语句的最低主版本，选择不高于当前版本的最高版本对应的语句。
+ *  当前版本低于所有已知版本或高于maxSupportedVersion时记录一次警告，并尝试最新版本的语句
+ */
+
+const maxSupportedVersion = 7
+
+type versionedSql map[int]string
+
+// 已警告过的版本，避免每次抓取重复记录
+var warnedVersions sync.Map
+
+/**
+* 函数：forVersion
+* 功能：获取指定主版本对应的查询语句
+ */
+func (v versionedSql) forVersion(ver int) string {
+	versions := make([]int, 0, len(v))
+	for version := range v {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+	if len(versions) == 0 {
+		return ""
+	}
+
+	if ver <= maxSupportedVersion {
+		for _, version := range versions {
+			if version <= ver {
+				return v[version]
+			}
+		}
+	}
+
+	if _, warned := warnedVersions.LoadOrStore(ver, true); !warned {
+		logger.Warnf("unsupported greenplum version %d, use the queries of version %d", ver, versions[0])
+	}
+
+	return v[versions[0]]
+}
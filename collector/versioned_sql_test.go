@@ -0,0 +1,46 @@
+package collector
+
+import "testing"
+
+func TestVersionedSqlForVersion(t *testing.T) {
+	sqls := versionedSql{5: "sql of 5", 6: "sql of 6", 7: "sql of 7"}
+
+	for _, c := range []struct {
+		ver      int
+		expected string
+	}{
+		{5, "sql of 5"},
+		{6, "sql of 6"},
+		{7, "sql of 7"},
+		// 低于所有已知版本或高于maxSupportedVersion时使用最新版本的语句
+		{4, "sql of 7"},
+		{8, "sql of 7"},
+	} {
+		if query := sqls.forVersion(c.ver); query != c.expected {
+			t.Errorf("version %d: expected %q, got %q", c.ver, c.expected, query)
+		}
+	}
+}
+
+// 键为引入该语句的最低版本，中间版本使用不高于它的最高版本的语句
+func TestVersionedSqlForVersionGap(t *testing.T) {
+	sqls := versionedSql{5: "sql of 5", 7: "sql of 7"}
+
+	for ver, expected := range map[int]string{5: "sql of 5", 6: "sql of 5", 7: "sql of 7"} {
+		if query := sqls.forVersion(ver); query != expected {
+			t.Errorf("version %d: expected %q, got %q", ver, expected, query)
+		}
+	}
+
+	if query := (versionedSql{}).forVersion(6); query != "" {
+		t.Errorf("expected empty sql without any version, got %q", query)
+	}
+}
+
+func TestSkewTableSqlsForVersion(t *testing.T) {
+	for ver, expected := range map[int]string{5: skewTableSql_V6, 6: skewTableSql_V6, 7: skewTableSql_V7, 8: skewTableSql_V7} {
+		if query := skewTableSqls.forVersion(ver); query != expected {
+			t.Errorf("version %d: unexpected skew table sql %q", ver, query)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  vmem使用率抓取器：Greenplum没有直接提供各实例vmem使用量的视图，这里用session_state中各会话在每个实例上的
+ *  vmem_mb之和作为已使用量，与gp_toolkit.gp_param_setting中各实例的gp_vmem_protect_limit比较。
+ *  coordinator内存耗尽会导致整个集群不可用，需要提前告警；未安装session_state或gp_toolkit时跳过
+ */
+
+const (
+	vmemProtectLimitSql = `SELECT paramsegment, paramvalue::float8 from gp_toolkit.gp_param_setting('gp_vmem_protect_limit');`
+	vmemUsedSql         = `SELECT segid, sum(vmem_mb) from session_state.session_level_memory_consumption GROUP BY segid;`
+)
+
+var (
+	coordinatorVmemUsedDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "coordinator_vmem_used_percent"),
+		"Percent of gp_vmem_protect_limit used by the sessions on the coordinator",
+		nil, nil,
+	)
+
+	maxSegmentVmemUsedDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "max_segment_vmem_used_percent"),
+		"Percent of gp_vmem_protect_limit used by the sessions on the busiest segment",
+		nil, nil,
+	)
+)
+
+func NewVmemScraper() Scraper {
+	return vmemScraper{}
+}
+
+type vmemScraper struct{}
+
+func (vmemScraper) Name() string {
+	return "vmem_scraper"
+}
+
+func (vmemScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	limits, err := queryVmemBySegment(ctx, db, vmemProtectLimitSql)
+	if err == nil {
+		var used map[int]float64
+		if used, err = queryVmemBySegment(ctx, db, vmemUsedSql); err == nil {
+			emitVmemUsedPercent(ch, limits, used)
+			return nil
+		}
+	}
+
+	if isUndefinedObject(err) {
+		logger.Warnf("skip vmem metrics, error:%v", err)
+		return nil
+	}
+
+	return err
+}
+
+func queryVmemBySegment(ctx context.Context, db Queryer, query string) (map[int]float64, error) {
+	logger.Debugf("Query Database: %s", query)
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	values := make(map[int]float64)
+	for rows.Next() {
+		var segID int
+		var value sql.NullFloat64
+
+		if err = rows.Scan(&segID, &value); err != nil {
+			return nil, err
+		}
+
+		values[segID] = value.Float64
+	}
+
+	return values, rows.Err()
+}
+
+/**
+* 函数：emitVmemUsedPercent
+* 功能：计算coordinator(segid为-1)及使用率最高的segment的vmem使用百分比，没有会话的实例使用量为0
+ */
+func emitVmemUsedPercent(ch chan<- prometheus.Metric, limits, used map[int]float64) {
+	maxPercent := -1.0
+
+	for segID, limit := range limits {
+		if limit <= 0 {
+			continue
+		}
+
+		percent := used[segID] * 100 / limit
+
+		if segID < 0 {
+			ch <- prometheus.MustNewConstMetric(coordinatorVmemUsedDesc, prometheus.GaugeValue, percent)
+			continue
+		}
+
+		if percent > maxPercent {
+			maxPercent = percent
+		}
+	}
+
+	if maxPercent >= 0 {
+		ch <- prometheus.MustNewConstMetric(maxSegmentVmemUsedDesc, prometheus.GaugeValue, maxPercent)
+	}
+}
@@ -0,0 +1,110 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  WAL抓取器：将coordinator当前的WAL位置换算为字节数，通过rate()计算WAL生成速率；
+ *  同时统计coordinator上WAL目录(pg_xlog/pg_wal)的大小，区分WAL堆积和数据增长导致的磁盘占用
+ */
+
+const (
+	walBytesSql_V7 = `SELECT pg_wal_lsn_diff(pg_current_wal_lsn(), '0/0');`
+	walBytesSql_V6 = `SELECT pg_xlog_location_diff(pg_current_xlog_location(), '0/0');`
+	// Greenplum 5 没有pg_xlog_location_diff，取文本位置后换算
+	walLocationSql_V5 = `SELECT pg_current_xlog_location();`
+
+	// PostgreSQL 9.3之前每个逻辑日志文件的最后一个段不使用，逻辑日志文件大小为0xFF000000字节
+	xlogFileSize_V5 = 0xFF000000
+
+	// pg_ls_waldir需要超级用户或pg_monitor角色，pg_ls_dir/pg_stat_file需要超级用户
+	walDirectorySizeSql_V7 = `SELECT coalesce(sum(size), 0) from pg_ls_waldir();`
+	walDirectorySizeSql_V6 = `SELECT coalesce(sum((pg_stat_file('pg_xlog/' || f)).size), 0) from pg_ls_dir('pg_xlog') f where f ~ '^[0-9A-F]{24}';`
+)
+
+var (
+	walBytesDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "wal_bytes_total"),
+		"Current WAL location of the coordinator converted to bytes",
+		nil, nil,
+	)
+
+	walDirectoryBytesDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "wal_directory_bytes"),
+		"Total bytes of WAL segment files in the pg_xlog or pg_wal directory of the coordinator",
+		nil, nil,
+	)
+)
+
+func NewWalScraper() Scraper {
+	return walScraper{}
+}
+
+type walScraper struct{}
+
+func (walScraper) Name() string {
+	return "wal_scraper"
+}
+
+func (walScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	errB := scrapeWalBytes(ctx, db, ch, ver)
+	errD := scrapeWalDirectorySize(ctx, db, ch, ver)
+
+	return combineErr(wrapErr("wal_bytes", errB), wrapErr("wal_directory_size", errD))
+}
+
+func scrapeWalBytes(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	if ver < 6 {
+		var location string
+
+		logger.Debugf("Query Database: %s", walLocationSql_V5)
+		if err := db.QueryRowContext(ctx, walLocationSql_V5).Scan(&location); err != nil {
+			return err
+		}
+
+		bytes, err := parseXlogLocation(location)
+		if err != nil {
+			return err
+		}
+
+		ch <- prometheus.MustNewConstMetric(walBytesDesc, prometheus.CounterValue, bytes)
+
+		return nil
+	}
+
+	querySql := walBytesSql_V7
+	if ver < 7 {
+		querySql = walBytesSql_V6
+	}
+
+	return scrapeScalarCounter(ctx, db, ch, walBytesDesc, querySql)
+}
+
+func scrapeWalDirectorySize(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	// Greenplum 5与6的WAL目录均为pg_xlog
+	querySql := walDirectorySizeSql_V7
+	if ver < 7 {
+		querySql = walDirectorySizeSql_V6
+	}
+
+	return skipScalarNull(scrapeScalarGauge(ctx, db, ch, walDirectoryBytesDesc, querySql))
+}
+
+/**
+* 函数：parseXlogLocation
+* 功能：将Greenplum 5的"逻辑日志文件号/偏移量"格式的WAL位置换算为字节数
+ */
+func parseXlogLocation(location string) (float64, error) {
+	var logid, offset uint64
+
+	if _, err := fmt.Sscanf(location, "%X/%X", &logid, &offset); err != nil {
+		return 0, fmt.Errorf("invalid xlog location %q: %w", location, err)
+	}
+
+	return float64(logid*xlogFileSize_V5 + offset), nil
+}
@@ -0,0 +1,57 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+/**
+ *  宽表(列数超过阈值的表)抓取器，阈值通过环境变量GPDB_WIDE_TABLE_COLUMNS配置
+ */
+
+const (
+	wideTablesColumnsEnv = "GPDB_WIDE_TABLE_COLUMNS"
+
+	wideTablesSql = `SELECT count(*) from (
+			SELECT table_schema, table_name from information_schema.columns
+			where table_schema not in ('gp_toolkit','information_schema','pg_catalog')
+			GROUP BY table_schema, table_name
+			HAVING count(*) > $1
+		) t;`
+)
+
+var (
+	wideTablesDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "wide_tables_count"),
+		"Number of tables whose column count exceeds the configured limit in each database",
+		[]string{"dbname"}, nil,
+	)
+)
+
+func NewWideTablesScraper(conns *ConnectionCache) Scraper {
+	return wideTablesScraper{maxColumns: envInt(wideTablesColumnsEnv, 1000), conns: conns}
+}
+
+type wideTablesScraper struct {
+	maxColumns int
+	conns      *ConnectionCache
+}
+
+func (wideTablesScraper) Name() string {
+	return "wide_tables_scraper"
+}
+
+func (s wideTablesScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	return forEachDatabase(ctx, db, s.conns, ch, func(ctx context.Context, conn *sql.DB, dbname string, ch chan<- prometheus.Metric) error {
+		count, err := scrapeScalar(ctx, conn, wideTablesSql, s.maxColumns)
+		if err != nil {
+			return skipScalarNull(err)
+		}
+
+		ch <- prometheus.MustNewConstMetric(wideTablesDesc, prometheus.GaugeValue, count, dbname)
+
+		return nil
+	})
+}
@@ -0,0 +1,141 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  工作文件(溢出到磁盘的临时文件)抓取器
+ */
+
+const (
+	workfilePerSegmentSql = `select segid, size from gp_toolkit.gp_workfile_usage_per_segment;`
+	// 同一个查询在各segment上各有一行，按会话和命令编号去重
+	queriesSpillingSql = `select count(*) from (select distinct sess_id, command_cnt from gp_toolkit.gp_workfile_usage_per_query) t;`
+	// temp_tablespaces为逗号分隔的表空间名，可能带引号；pg_tablespace_size会汇总所有segment上的大小
+	tempTablespaceSizeSql = `select spcname, pg_tablespace_size(oid) from pg_tablespace
+		where spcname = any(string_to_array(translate(current_setting('temp_tablespaces'), ' "', ''), ','))
+		and spcname not in ('pg_default', 'pg_global');`
+)
+
+var (
+	segmentTempBytesDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "segment_temp_bytes"),
+		"Total bytes of workfiles currently spilled to disk on each segment",
+		[]string{"gp_segment_id"}, nil,
+	)
+
+	queriesSpillingDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "queries_currently_spilling"),
+		"Number of running queries that currently have workfiles spilled to disk",
+		nil, nil,
+	)
+
+	tempTablespaceSizeDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "temp_tablespace_size_bytes"),
+		"Total bytes size of each dedicated temp tablespace configured in temp_tablespaces across all segments",
+		[]string{"tablespace"}, nil,
+	)
+)
+
+func NewWorkfileScraper() Scraper {
+	return workfileScraper{}
+}
+
+type workfileScraper struct{}
+
+func (workfileScraper) Name() string {
+	return "workfile_scraper"
+}
+
+func (workfileScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	errS := scrapeWorkfilePerSegment(ctx, db, ch)
+	errQ := scrapeQueriesSpilling(ctx, db, ch)
+	errT := scrapeTempTablespaceSize(ctx, db, ch, ver)
+
+	return combineErr(wrapErr("workfile_per_segment", errS), wrapErr("queries_spilling", errQ), wrapErr("temp_tablespace_size", errT))
+}
+
+func scrapeWorkfilePerSegment(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	logger.Debugf("Query Database: %s", workfilePerSegmentSql)
+	rows, err := db.QueryContext(ctx, workfilePerSegmentSql)
+
+	if err != nil {
+		if isUndefinedObject(err) {
+			logger.Warnf("skip workfile metrics, error:%v", err)
+			return nil
+		}
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var segID int
+		var size sql.NullFloat64
+
+		err = rows.Scan(&segID, &size)
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(segmentTempBytesDesc, prometheus.GaugeValue, size.Float64, strconv.Itoa(segID))
+	}
+
+	return combineErr(errs...)
+}
+
+func scrapeQueriesSpilling(ctx context.Context, db Queryer, ch chan<- prometheus.Metric) error {
+	err := scrapeScalarGauge(ctx, db, ch, queriesSpillingDesc, queriesSpillingSql)
+
+	if isUndefinedObject(err) {
+		logger.Warnf("skip queries spilling metrics, error:%v", err)
+		return nil
+	}
+
+	return skipScalarNull(err)
+}
+
+/**
+* 函数：scrapeTempTablespaceSize
+* 功能：统计temp_tablespaces中各专用临时表空间的大小，未配置时不输出；Greenplum 5使用filespace存放临时文件，不支持
+ */
+func scrapeTempTablespaceSize(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	if ver < 6 {
+		return nil
+	}
+
+	logger.Debugf("Query Database: %s", tempTablespaceSizeSql)
+	rows, err := db.QueryContext(ctx, tempTablespaceSizeSql)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	errs := make([]error, 0)
+
+	for rows.Next() {
+		var name string
+		var size float64
+
+		if err = rows.Scan(&name, &size); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(tempTablespaceSizeDesc, prometheus.GaugeValue, size, name)
+	}
+
+	return combineErr(errs...)
+}
@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestWorkfileScraperErrors(t *testing.T) {
+	t.Run("query_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectFailedQueries(mock, workfilePerSegmentSql, queriesSpillingSql, tempTablespaceSizeSql)
+
+		err := collectAndCompare(t, NewWorkfileScraper(), db, 6, "")
+		expectErrLabels(t, err, "workfile_per_segment", "queries_spilling", "temp_tablespace_size")
+	})
+
+	// 出错的行被跳过，其余行照常输出
+	t.Run("scan_error", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		mock.ExpectQuery(regexp.QuoteMeta(workfilePerSegmentSql)).WillReturnRows(sqlmock.NewRows([]string{"segid", "size"}).
+			AddRow("abc", 1024).
+			AddRow(1, 2048))
+		mock.ExpectQuery(regexp.QuoteMeta(queriesSpillingSql)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow("abc"))
+		mock.ExpectQuery(regexp.QuoteMeta(tempTablespaceSizeSql)).WillReturnRows(sqlmock.NewRows([]string{"spcname", "size"}).
+			AddRow("temp_ts", nil))
+
+		expected := `
+# HELP greenplum_server_segment_temp_bytes Total bytes of workfiles currently spilled to disk on each segment
+# TYPE greenplum_server_segment_temp_bytes gauge
+greenplum_server_segment_temp_bytes{gp_segment_id="1"} 2048
+`
+		err := collectAndCompare(t, NewWorkfileScraper(), db, 6, expected)
+		expectErrLabels(t, err, "workfile_per_segment", "queries_spilling", "temp_tablespace_size")
+	})
+
+	// 没有溢出的工作文件时不输出，未安装gp_toolkit时跳过
+	t.Run("empty", func(t *testing.T) {
+		db, mock := newMockDB(t)
+		expectEmptyQueries(mock, workfilePerSegmentSql)
+		mock.ExpectQuery(regexp.QuoteMeta(queriesSpillingSql)).WillReturnError(&pq.Error{Code: "42P01"})
+
+		if err := collectAndCompare(t, NewWorkfileScraper(), db, 5, ""); err != nil {
+			t.Errorf("unexpected scrape error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	logger "greenplum-exporter/logging"
+)
+
+/**
+ *  事务ID回卷抓取器：在coordinator连接上查询每个数据库的age(datfrozenxid)，取coordinator与各segment中的最大值，
+ *  并计算其占autovacuum_freeze_max_age的百分比。年龄过大时集群会拒绝新事务甚至停止服务，需要提前告警并执行VACUUM FREEZE
+ */
+
+const (
+	// gp_dist_random在每个segment上计算age，各segment的事务ID相互独立
+	databaseXidAgeSql = `SELECT datname, max(xid_age) from (
+			SELECT datname, age(datfrozenxid) xid_age from pg_database
+			union all
+			SELECT datname, age(datfrozenxid) xid_age from gp_dist_random('pg_database')
+		) t GROUP BY datname;`
+	freezeMaxAgeSql = `select setting::float8 from pg_settings where name = 'autovacuum_freeze_max_age';`
+)
+
+var (
+	databaseXidAgeDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_xid_age"),
+		"Maximum age of datfrozenxid of each database on the coordinator and all segments",
+		[]string{"dbname"}, nil,
+	)
+
+	databaseXidAgePercentDesc = newDesc(
+		prometheus.BuildFQName(namespace, subSystemServer, "database_xid_age_percent_towards_wraparound"),
+		"Percent of autovacuum_freeze_max_age reached by the datfrozenxid age of each database",
+		[]string{"dbname"}, nil,
+	)
+)
+
+func NewXidWraparoundScraper() Scraper {
+	return xidWraparoundScraper{}
+}
+
+type xidWraparoundScraper struct{}
+
+func (xidWraparoundScraper) Name() string {
+	return "xid_wraparound_scraper"
+}
+
+func (xidWraparoundScraper) Scrape(ctx context.Context, db Queryer, ch chan<- prometheus.Metric, ver int) error {
+	// 获取失败时只输出年龄，不输出百分比
+	freezeMaxAge, errF := scrapeScalar(ctx, db, freezeMaxAgeSql)
+
+	logger.Debugf("Query Database: %s", databaseXidAgeSql)
+	rows, err := db.QueryContext(ctx, databaseXidAgeSql)
+	if err != nil {
+		return combineErr(err, wrapErr("autovacuum_freeze_max_age", skipScalarNull(errF)))
+	}
+
+	defer rows.Close()
+
+	errs := []error{wrapErr("autovacuum_freeze_max_age", skipScalarNull(errF))}
+
+	for rows.Next() {
+		var dbname string
+		var age float64
+
+		if err = rows.Scan(&dbname, &age); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(databaseXidAgeDesc, prometheus.GaugeValue, age, dbname)
+
+		if errF == nil && freezeMaxAge > 0 {
+			ch <- prometheus.MustNewConstMetric(databaseXidAgePercentDesc, prometheus.GaugeValue, age*100/freezeMaxAge, dbname)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return combineErr(errs...)
+}
@@ -0,0 +1,48 @@
+package collector
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestXidWraparoundScraper(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta(freezeMaxAgeSql)).WillReturnRows(sqlmock.NewRows([]string{"setting"}).AddRow(200000000))
+	mock.ExpectQuery(regexp.QuoteMeta(databaseXidAgeSql)).WillReturnRows(sqlmock.NewRows([]string{"datname", "max"}).
+		AddRow("postgres", 50000000).
+		AddRow("sales", 150000000))
+
+	expected := `
+# HELP greenplum_server_database_xid_age Maximum age of datfrozenxid of each database on the coordinator and all segments
+# TYPE greenplum_server_database_xid_age gauge
+greenplum_server_database_xid_age{dbname="postgres"} 5e+07
+greenplum_server_database_xid_age{dbname="sales"} 1.5e+08
+# HELP greenplum_server_database_xid_age_percent_towards_wraparound Percent of autovacuum_freeze_max_age reached by the datfrozenxid age of each database
+# TYPE greenplum_server_database_xid_age_percent_towards_wraparound gauge
+greenplum_server_database_xid_age_percent_towards_wraparound{dbname="postgres"} 25
+greenplum_server_database_xid_age_percent_towards_wraparound{dbname="sales"} 75
+`
+	if err := collectAndCompare(t, NewXidWraparoundScraper(), db, 6, expected); err != nil {
+		t.Errorf("unexpected scrape error: %v", err)
+	}
+}
+
+// 获取autovacuum_freeze_max_age失败时仍输出年龄，不输出百分比
+func TestXidWraparoundScraperWithoutFreezeMaxAge(t *testing.T) {
+	db, mock := newMockDB(t)
+	mock.ExpectQuery(regexp.QuoteMeta(freezeMaxAgeSql)).WillReturnError(errors.New("permission denied"))
+	mock.ExpectQuery(regexp.QuoteMeta(databaseXidAgeSql)).WillReturnRows(sqlmock.NewRows([]string{"datname", "max"}).
+		AddRow("sales", 150000000))
+
+	expected := `
+# HELP greenplum_server_database_xid_age Maximum age of datfrozenxid of each database on the coordinator and all segments
+# TYPE greenplum_server_database_xid_age gauge
+greenplum_server_database_xid_age{dbname="sales"} 1.5e+08
+`
+	if err := collectAndCompare(t, NewXidWraparoundScraper(), db, 6, expected); err == nil {
+		t.Error("expected the autovacuum_freeze_max_age error")
+	}
+}
@@ -3,6 +3,8 @@ module greenplum-exporter
 go 1.14
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.0
+	github.com/go-kit/kit v0.9.0
 	github.com/lib/pq v1.7.1
 	github.com/prometheus/client_golang v1.7.1
 	github.com/prometheus/common v0.10.0
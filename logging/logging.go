@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+/**
+ *  日志：基于go-kit/log输出带级别、时间和调用位置的结构化日志(logfmt或json格式)，
+ *  替代已废弃的prometheus/common/log。通过--log.level和--log.format设置级别和格式，默认为info和logfmt
+ */
+
+var (
+	levelFlag  = "info"
+	formatFlag = "logfmt"
+
+	logger = newLogger(levelFlag, formatFlag)
+)
+
+/**
+* 函数：AddFlags
+* 功能：注册--log.level和--log.format参数
+ */
+func AddFlags(app *kingpin.Application) {
+	app.Flag("log.level", "Only log messages with the given severity or above. One of: [debug, info, warn, error]").
+		Default(levelFlag).EnumVar(&levelFlag, "debug", "info", "warn", "error")
+	app.Flag("log.format", "Output format of log messages. One of: [logfmt, json]").
+		Default(formatFlag).EnumVar(&formatFlag, "logfmt", "json")
+}
+
+/**
+* 函数：Setup
+* 功能：按解析后的参数重新创建日志输出，需在kingpin.Parse之后、输出日志之前调用
+ */
+func Setup() {
+	logger = newLogger(levelFlag, formatFlag)
+}
+
+func newLogger(lvl, format string) log.Logger {
+	var l log.Logger
+	if format == "json" {
+		l = log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+	} else {
+		l = log.NewLogfmtLogger(log.NewSyncWriter(os.Stderr))
+	}
+
+	var option level.Option
+	switch lvl {
+	case "debug":
+		option = level.AllowDebug()
+	case "warn":
+		option = level.AllowWarn()
+	case "error":
+		option = level.AllowError()
+	default:
+		option = level.AllowInfo()
+	}
+
+	// 调用位置跳过本包的封装函数
+	return log.With(level.NewFilter(l, option), "ts", log.DefaultTimestampUTC, "caller", log.Caller(4))
+}
+
+func Debugf(format string, args ...interface{}) {
+	_ = level.Debug(logger).Log("msg", fmt.Sprintf(format, args...))
+}
+
+func Info(msg string) {
+	_ = level.Info(logger).Log("msg", msg)
+}
+
+func Infof(format string, args ...interface{}) {
+	_ = level.Info(logger).Log("msg", fmt.Sprintf(format, args...))
+}
+
+func Warn(msg string) {
+	_ = level.Warn(logger).Log("msg", msg)
+}
+
+func Warnf(format string, args ...interface{}) {
+	_ = level.Warn(logger).Log("msg", fmt.Sprintf(format, args...))
+}
+
+func Error(msg string) {
+	_ = level.Error(logger).Log("msg", msg)
+}
+
+func Errorf(format string, args ...interface{}) {
+	_ = level.Error(logger).Log("msg", fmt.Sprintf(format, args...))
+}
+
+// 记录错误后退出进程
+func Fatalf(format string, args ...interface{}) {
+	_ = level.Error(logger).Log("msg", fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
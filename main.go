@@ -1,13 +1,27 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"greenplum-exporter/collector"
 
 	"github.com/prometheus/client_golang/prometheus"
-	logger "github.com/prometheus/common/log"
+	logger "greenplum-exporter/logging"
+	"github.com/prometheus/common/version"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"gopkg.in/alecthomas/kingpin.v2"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 )
 
 /**
@@ -20,49 +34,301 @@ var (
 	listenAddress         = kingpin.Flag("web.listen-address", "web endpoint").Default("0.0.0.0:9297").String()
 	metricPath            = kingpin.Flag("web.telemetry-path", "Path under which to expose metrics.").Default("/metrics").String()
 	disableDefaultMetrics = kingpin.Flag("disableDefaultMetrics", "do not report default metrics(go metrics and process metrics)").Default("true").Bool()
+	enableGpperfmon       = kingpin.Flag("gpperfmon", "enable scrapers based on the gpperfmon database").Default("false").Bool()
+	enableLogScrapers     = kingpin.Flag("logs", "enable scrapers based on the server logs read through gp_toolkit").Default("false").Bool()
+	collectorInclude      = kingpin.Flag("collector.include", "comma-separated names of scrapers to enable in addition to the defaults, e.g. segment_size_scraper").Envar("GPDB_COLLECTOR_INCLUDE").String()
+	collectorExclude      = kingpin.Flag("collector.exclude", "comma-separated names of scrapers to disable, e.g. database_size_scraper").Envar("GPDB_COLLECTOR_EXCLUDE").String()
+	scrapeTimeout         = kingpin.Flag("scrape.timeout", "timeout of each scraper, scrapers reading server logs use at least 10s").Default("10s").Envar("GPDB_SCRAPE_TIMEOUT").Duration()
+	cacheTTL              = kingpin.Flag("cache.ttl", "serve the metrics of each successful scraper from cache within this duration, 0 to disable").Default("0s").Envar("GPDB_CACHE_TTL").Duration()
+	tablesExcludedSchemas = kingpin.Flag("tables.excluded-schemas", "comma-separated schemas not counted in the table count metrics, in addition to the system schemas").Envar("GPDB_TABLES_EXCLUDED_SCHEMAS").String()
+	tablesIncludedSchemas = kingpin.Flag("tables.included-schemas", "comma-separated schemas to count in the table count metrics, takes precedence over tables.excluded-schemas").Envar("GPDB_TABLES_INCLUDED_SCHEMAS").String()
+	relationsTopN         = kingpin.Flag("relations.top-n", "number of the largest relations reported by relation_size_scraper in each database").Default("20").Envar("GPDB_RELATIONS_TOP_N").Int()
+	shutdownTimeout       = kingpin.Flag("web.shutdown-timeout", "time to wait for in-flight scrapes on SIGTERM or SIGINT before closing the database connections").Default("30s").Envar("GPDB_SHUTDOWN_TIMEOUT").Duration()
+	tlsCertFile           = kingpin.Flag("web.tls-cert-file", "server certificate file, enable HTTPS when set").Envar("GPDB_EXPORTER_TLS_CERT_FILE").String()
+	tlsKeyFile            = kingpin.Flag("web.tls-key-file", "server private key file").Envar("GPDB_EXPORTER_TLS_KEY_FILE").String()
+	tlsClientCAFile       = kingpin.Flag("web.tls-client-ca-file", "CA file to verify client certificates, enable mTLS when set").Envar("GPDB_EXPORTER_TLS_CLIENT_CA_FILE").String()
 )
 
+// 按库连接的抓取器共用的连接缓存，连接在多次抓取之间复用
+var connectionCache = collector.NewConnectionCache()
+
 var scrapers = map[collector.Scraper]bool{
-	collector.NewLocksScraper():         true,
-	collector.NewClusterStateScraper():  true,
-	collector.NewDatabaseSizeScraper():  true,
-	collector.NewConnectionsScraper():   true,
-	collector.NewMaxConnScraper():       true,
-	collector.NewSegmentScraper():       true,
-	collector.NewConnDetailScraper():    true,
-	collector.NewUsersScraper():         true,
-	collector.NewBgWriterStateScraper(): true,
-
-	collector.NewSystemScraper():        false,
-	collector.NewQueryScraper():         false,
-	collector.NewDynamicMemoryScraper(): false,
-	collector.NewDiskScraper():          false,
+	collector.NewLocksScraper():                       true,
+	collector.NewClusterStateScraper():                true,
+	collector.NewDatabaseSizeScraper(connectionCache): true,
+	collector.NewConnectionsScraper():                 true,
+	collector.NewMaxConnScraper():                     true,
+	collector.NewSegmentScraper():                     true,
+	collector.NewConnDetailScraper():                  true,
+	collector.NewUsersScraper():                       true,
+	collector.NewBgWriterStateScraper():               true,
+	collector.NewReplicationSlotsScraper():            true,
+	collector.NewTransactionsScraper():                true,
+	collector.NewWorkfileScraper():                    true,
+	collector.NewResourceGroupScraper():               true,
+	collector.NewMaintenanceScraper():                 true,
+	collector.NewReplicationScraper():                 true,
+	collector.NewAdmissionScraper():                   true,
+	collector.NewPrerequisitesScraper():               true,
+	collector.NewAutovacuumScraper():                  true,
+	collector.NewWalScraper():                         true,
+	collector.NewCatalogCheckScraper():                true,
+	collector.NewQueryTagScraper():                    true,
+	collector.NewVmemScraper():                        true,
+	collector.NewQuerySlicesScraper():                 true,
+	collector.NewMatviewsScraper(connectionCache):     true,
+	collector.NewSegmentBackendsScraper():             true,
+	collector.NewSegmentDiskScraper(connectionCache):  true,
+	collector.NewActivityScraper():                    true,
+	collector.NewXidWraparoundScraper():               true,
+
+	collector.NewSystemScraper():                        false,
+	collector.NewQueryScraper():                         false,
+	collector.NewDynamicMemoryScraper():                 false,
+	collector.NewDiskScraper():                          false,
+	collector.NewWideTablesScraper(connectionCache):     false,
+	collector.NewAOTablesScraper(connectionCache):       false,
+	collector.NewIndexBloatScraper(connectionCache):     false,
+	collector.NewStorageSizeScraper(connectionCache):    false,
+	collector.NewTempSchemasScraper(connectionCache):    false,
+	collector.NewBackendMemoryScraper():                 false,
+	collector.NewTableRowWidthScraper(connectionCache):  false,
+	collector.NewTableXidAgeScraper(connectionCache):    false,
+	collector.NewInvalidIndexesScraper(connectionCache): false,
+	collector.NewSegmentSizeScraper():                   false,
+	collector.NewLargeTablesScraper(connectionCache):    false,
+	collector.NewRelationSizeScraper(connectionCache):   false,
+}
+
+// 依赖gpperfmon数据库的抓取器，通过--gpperfmon开启
+var gpperfmonScrapers = []collector.Scraper{
+	collector.NewQueryRuntimeScraper(connectionCache),
+	collector.NewHostDiskIOScraper(connectionCache),
+	collector.NewRowsLoadedScraper(connectionCache),
+	collector.NewGpperfmonStatusScraper(connectionCache),
+	collector.NewRunningQuerySkewScraper(connectionCache),
+}
+
+// 读取服务器日志的抓取器，需要扫描日志文件，通过--logs开启
+var logScrapers = []collector.Scraper{
+	collector.NewGlobalDeadlockScraper(),
+	collector.NewSegmentDiskErrorsScraper(),
+	collector.NewRejectedQueriesScraper(),
 }
 
 var gathers prometheus.Gatherers
 
+// 未通过ldflags注入版本号时使用的默认版本
+const defaultVersion = "1.1.1"
+
 func main() {
-	kingpin.Version("1.1.1")
+	if version.Version == "" {
+		version.Version = defaultVersion
+	}
+
+	kingpin.Version(version.Print("greenplum_exporter"))
 	kingpin.HelpFlag.Short('h')
 
 	logger.AddFlags(kingpin.CommandLine)
 	kingpin.Parse()
+	logger.Setup()
+
+	collector.SetTableSchemas(*tablesIncludedSchemas, *tablesExcludedSchemas)
+	collector.SetRelationsTopN(*relationsTopN)
+	collector.CheckSqlOverrides()
 
-	metricsHandleFunc := newHandler(*disableDefaultMetrics, scrapers)
+	// 未开启的gpperfmon和日志抓取器也加入列表(标记为未启用)，/scrapers接口中可以看到所有抓取器
+	for _, scraper := range gpperfmonScrapers {
+		scrapers[scraper] = *enableGpperfmon
+	}
+
+	for _, scraper := range logScrapers {
+		scrapers[scraper] = *enableLogScrapers
+	}
+
+	// 名称错误时启动失败，避免误以为抓取器已关闭
+	if err := filterScrapers(scrapers, *collectorInclude, *collectorExclude); err != nil {
+		logger.Fatalf("invalid scraper filter, error:%v", err)
+	}
+
+	greenPlumCollector := newCollector(scrapers)
+
+	go reloadOnSighup(greenPlumCollector)
+
+	metricsHandleFunc := newHandler(*disableDefaultMetrics, greenPlumCollector)
 
 	mux := http.NewServeMux()
 
 	mux.HandleFunc(*metricPath, metricsHandleFunc)
+	mux.HandleFunc("/scrapers", newScrapersHandler(greenPlumCollector, scrapers))
+
+	server := &http.Server{Addr: *listenAddress, Handler: mux}
+
+	shutdown := make(chan struct{})
+	go shutdownOnSignal(server, greenPlumCollector, *shutdownTimeout, shutdown)
+
+	if *tlsCertFile == "" {
+		logger.Warnf("Greenplum exporter is starting and will listening on : %s", *listenAddress)
+
+		waitShutdown(server.ListenAndServe(), shutdown)
+		return
+	}
+
+	// 证书文件缺失或无法读取时启动失败
+	tlsConfig, err := newTLSConfig(*tlsCertFile, *tlsKeyFile, *tlsClientCAFile)
+	if err != nil {
+		logger.Fatalf("load TLS config failed, error:%v", err)
+	}
 
-	logger.Warnf("Greenplum exporter is starting and will listening on : %s", *listenAddress)
+	server.TLSConfig = tlsConfig
 
-	logger.Error(http.ListenAndServe(*listenAddress, mux).Error())
+	logger.Warnf("Greenplum exporter is starting and will listening on : %s with TLS", *listenAddress)
+
+	waitShutdown(server.ListenAndServeTLS("", ""), shutdown)
 }
 
-func newHandler(disableDefaultMetrics bool, scrapers map[collector.Scraper]bool) http.HandlerFunc {
+/**
+ * 函数：waitShutdown
+ * 功能：服务因收到退出信号而关闭时等待连接关闭完成，其它错误直接记录并退出
+ */
+func waitShutdown(err error, shutdown <-chan struct{}) {
+	if !errors.Is(err, http.ErrServerClosed) {
+		logger.Error(err.Error())
+		return
+	}
 
-	registry := prometheus.NewRegistry()
+	<-shutdown
+}
+
+/**
+ * 函数：shutdownOnSignal
+ * 功能：收到SIGTERM或SIGINT信号时停止接受新的请求，最多等待timeout让正在进行的抓取结束，然后关闭所有数据库连接，
+ *       避免进程被终止后在coordinator上遗留后端进程。超时后不再等待抓取结束，仍在使用的连接在进程退出时断开
+ */
+func shutdownOnSignal(server *http.Server, greenPlumCollector *collector.GreenPlumCollector, timeout time.Duration, shutdown chan<- struct{}) {
+	defer close(shutdown)
+
+	term := make(chan os.Signal, 1)
+	signal.Notify(term, syscall.SIGTERM, os.Interrupt)
+
+	sig := <-term
+	logger.Infof("received %s, shutting down", sig)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Warnf("in-flight scrapes not finished within %s, close connections anyway, error:%v", timeout, err)
+		connectionCache.Close()
+		return
+	}
+
+	greenPlumCollector.Close()
+	connectionCache.Close()
+
+	logger.Info("database connections closed")
+}
+
+/**
+ * 函数：newTLSConfig
+ * 功能：加载服务端证书，设置了客户端CA时要求并验证客户端证书(mTLS)
+ */
+func newTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	if keyFile == "" {
+		return nil, errors.New("web.tls-key-file is required when web.tls-cert-file is set")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if clientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	pem, err := ioutil.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in client CA file %s", clientCAFile)
+	}
+
+	tlsConfig.ClientCAs = clientCAs
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+
+	return tlsConfig, nil
+}
+
+/**
+ * 函数：reloadOnSighup
+ * 功能：收到SIGHUP信号时重新加载基于文件的配置
+ */
+func reloadOnSighup(greenPlumCollector *collector.GreenPlumCollector) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	for range hup {
+		logger.Infof("received SIGHUP, reloading config")
+		greenPlumCollector.Reload()
+	}
+}
+
+/**
+ * 函数：filterScrapers
+ * 功能：按抓取器名称开启--collector.include中的抓取器，关闭--collector.exclude中的抓取器，名称不存在时返回错误并列出所有可用名称
+ */
+func filterScrapers(scrapers map[collector.Scraper]bool, include, exclude string) error {
+	known := make(map[string]collector.Scraper)
+
+	for scraper := range scrapers {
+		known[scraper.Name()] = scraper
+	}
+
+	for _, scraper := range append(append([]collector.Scraper{}, gpperfmonScrapers...), logScrapers...) {
+		known[scraper.Name()] = scraper
+	}
+
+	apply := func(list string, enable bool) error {
+		for _, name := range strings.Split(list, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+
+			scraper, ok := known[name]
+			if !ok {
+				names := make([]string, 0, len(known))
+				for n := range known {
+					names = append(names, n)
+				}
+				sort.Strings(names)
+
+				return fmt.Errorf("unknown scraper %q, valid names: %s", name, strings.Join(names, ","))
+			}
+
+			scrapers[scraper] = enable
+		}
+
+		return nil
+	}
+
+	if err := apply(include, true); err != nil {
+		return err
+	}
+
+	return apply(exclude, false)
+}
+
+func newCollector(scrapers map[collector.Scraper]bool) *collector.GreenPlumCollector {
 	enabledScrapers := make([]collector.Scraper, 0, 16)
 
 	for scraper, enable := range scrapers {
@@ -71,7 +337,20 @@ func newHandler(disableDefaultMetrics bool, scrapers map[collector.Scraper]bool)
 		}
 	}
 
-	greenPlumCollector := collector.NewCollector(enabledScrapers)
+	names := make([]string, 0, len(enabledScrapers))
+	for _, scraper := range enabledScrapers {
+		names = append(names, scraper.Name())
+	}
+	sort.Strings(names)
+
+	logger.Infof("enabled scrapers: %s", strings.Join(names, ","))
+
+	return collector.NewCollector(enabledScrapers, *scrapeTimeout, *cacheTTL)
+}
+
+func newHandler(disableDefaultMetrics bool, greenPlumCollector *collector.GreenPlumCollector) http.HandlerFunc {
+
+	registry := prometheus.NewRegistry()
 
 	registry.MustRegister(greenPlumCollector)
 
@@ -87,3 +366,17 @@ func newHandler(disableDefaultMetrics bool, scrapers map[collector.Scraper]bool)
 
 	return handler.ServeHTTP
 }
+
+/**
+ * 函数：newScrapersHandler
+ * 功能：以JSON格式列出所有抓取器及其状态
+ */
+func newScrapersHandler(greenPlumCollector *collector.GreenPlumCollector, scrapers map[collector.Scraper]bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(greenPlumCollector.ScraperStatus(scrapers)); err != nil {
+			logger.Errorf("encode scrapers status failed, error:%v", err)
+		}
+	}
+}
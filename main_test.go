@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/prometheus/client_golang/prometheus"
+	"greenplum-exporter/collector"
+)
+
+// 不连接数据库即可返回的抓取器(未配置时不输出指标)
+var configGatedScrapers = map[string]bool{
+	"query_tag_scraper":     true,
+	"catalog_check_scraper": true,
+}
+
+/**
+* 函数：TestScraperVersionRanges
+* 功能：在各Greenplum主版本上执行所有抓取器，不支持该版本而直接返回(不执行任何查询)的抓取器必须实现VersionRanger，
+*      /scrapers接口据此报告支持的版本范围；声明了版本范围的抓取器在范围外不能执行查询
+ */
+func TestScraperVersionRanges(t *testing.T) {
+	all := make([]collector.Scraper, 0, len(scrapers))
+	for scraper := range scrapers {
+		all = append(all, scraper)
+	}
+	all = append(append(all, gpperfmonScrapers...), logScrapers...)
+
+	for _, scraper := range all {
+		if configGatedScrapers[scraper.Name()] {
+			continue
+		}
+
+		min, max := 0, 0
+		if ranger, ok := scraper.(collector.VersionRanger); ok {
+			min, max = ranger.VersionRange()
+		}
+
+		for _, ver := range []int{5, 6, 7} {
+			supported := (min == 0 || ver >= min) && (max == 0 || ver <= max)
+
+			queried, err := scrapeWithoutExpectations(scraper, ver)
+			if err != nil {
+				t.Fatalf("create sqlmock failed: %v", err)
+			}
+
+			if supported && !queried {
+				t.Errorf("%s returns without any query on version %d, implement VersionRange", scraper.Name(), ver)
+			}
+			if !supported && queried {
+				t.Errorf("%s queries on version %d outside its version range [%d, %d]", scraper.Name(), ver, min, max)
+			}
+		}
+	}
+}
+
+// 在没有任何预期查询的sqlmock上执行抓取器，返回是否执行了查询
+func scrapeWithoutExpectations(scraper collector.Scraper, ver int) (bool, error) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		return false, err
+	}
+
+	defer db.Close()
+
+	ch := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range ch {
+		}
+	}()
+
+	// sqlmock对没有预期的查询返回错误，抓取器返回错误即说明执行了查询
+	err = scraper.Scrape(context.Background(), db, ch, ver)
+	close(ch)
+	<-done
+
+	return err != nil, nil
+}